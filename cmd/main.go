@@ -10,9 +10,13 @@ import (
 	"telekilogram/internal/config"
 	"telekilogram/internal/database"
 	"telekilogram/internal/feed"
+	"telekilogram/internal/metrics"
 	"telekilogram/internal/scheduler"
 	"telekilogram/internal/summarizer"
 	"time"
+
+	// The runtime image has no system zoneinfo, and user time zones need it.
+	_ "time/tzdata"
 )
 
 func main() {
@@ -37,10 +41,53 @@ func main() {
 	log.InfoContext(ctx, "DB is initialized",
 		"dbPath", cfg.DBPath)
 
-	summarizer := initOpenAISummarizer(ctx, cfg.OpenAIAPIKey, cfg.OpenAI, log)
-	fetcher := feed.NewFetcher(db, summarizer, cfg.Feed, cfg.Telegram, log)
+	m := metrics.New()
+
+	if cfg.Metrics.ListenAddr != "" {
+		metricsServer := metrics.NewServer(cfg.Metrics.ListenAddr, m, log)
+		if err = metricsServer.Start(ctx); err != nil {
+			log.ErrorContext(ctx, "Failed to start metrics server",
+				"error", err,
+				"listenAddr", cfg.Metrics.ListenAddr)
+
+			return
+		}
+		defer func() {
+			if stopErr := metricsServer.Stop(); stopErr != nil {
+				log.ErrorContext(ctx, "Failed to stop metrics server",
+					"error", stopErr)
+			}
+		}()
+		log.InfoContext(ctx, "Metrics server is started",
+			"listenAddr", cfg.Metrics.ListenAddr,
+			"path", metrics.Path)
+	}
+
+	summarizer := initOpenAISummarizer(ctx, cfg.OpenAIAPIKey, cfg.OpenAI, cfg.Summarizer, m, log)
+	fetcher := feed.NewFetcher(db, summarizer, m, cfg.Feed, cfg.Telegram, log)
+
+	if warmed, warmErr := fetcher.WarmSummaryCache(ctx); warmErr != nil {
+		log.ErrorContext(ctx, "Failed to warm summary cache",
+			"error", warmErr)
+	} else {
+		log.InfoContext(ctx, "Summary cache is warmed",
+			"entries", warmed)
+	}
 
-	botInst, err := bot.New(cfg.Token, db, fetcher, cfg.AllowedUsers, cfg.Bot, cfg.RateLimiter, log)
+	overviewSummarizer := initDigestOverviewSummarizer(ctx, cfg, m, log)
+
+	botInst, err := bot.New(
+		cfg.Token,
+		db,
+		fetcher,
+		overviewSummarizer,
+		m,
+		cfg.AllowedUsers,
+		cfg.Bot,
+		cfg.DigestOverview,
+		cfg.RateLimiter,
+		log,
+	)
 	if err != nil {
 		log.ErrorContext(ctx, "Failed to initialize bot",
 			"error", err,
@@ -51,7 +98,7 @@ func main() {
 	log.InfoContext(ctx, "Bot is initialized",
 		"allowedUsersCount", len(cfg.AllowedUsers))
 
-	sched := scheduler.New(ctx, botInst, fetcher, cfg.Scheduler, log)
+	sched := scheduler.New(ctx, botInst, fetcher, m, cfg.Scheduler, log)
 
 	if err = sched.Start(); err != nil {
 		log.ErrorContext(ctx, "Failed to start scheduler",
@@ -64,13 +111,19 @@ func main() {
 	defer sched.Stop()
 	log.InfoContext(ctx, "Scheduler is started",
 		"spec", scheduler.HourlyDigestSpec,
+		"realtimeSpec", scheduler.RealtimeFeedsSpec(cfg.Scheduler.RealtimePollInterval),
 		"timezone", time.FixedZone(scheduler.Timezone, scheduler.TimezoneOffsetSeconds).String())
 
-	go func() {
-		botInst.Start(ctx)
-	}()
+	if err = botInst.Start(ctx); err != nil {
+		log.ErrorContext(ctx, "Failed to start bot",
+			"error", err,
+			"mode", cfg.Bot.Mode)
+
+		return
+	}
 	log.InfoContext(ctx, "Bot is started",
 		"allowedUsersCount", len(cfg.AllowedUsers),
+		"mode", cfg.Bot.Mode,
 		"startupSeconds", time.Since(start).Seconds())
 
 	c := make(chan os.Signal, 1)
@@ -89,30 +142,74 @@ func main() {
 		"uptimeSeconds", time.Since(start).Seconds())
 }
 
+// initOpenAISummarizer picks the summary provider from SUMMARIZER_PROVIDER and
+// returns nil, so the fallback is used, when it can't be created.
 func initOpenAISummarizer(
 	ctx context.Context,
 	apiKey string,
 	cfg config.OpenAIConfig,
+	summarizerCfg config.SummarizerConfig,
+	m *metrics.Metrics,
 	log *slog.Logger,
 ) summarizer.Summarizer {
-	if apiKey == "" {
-		log.WarnContext(ctx, "OPENAI_API_KEY is missing so fallback will be used",
-			"envVar", "OPENAI_API_KEY")
+	var (
+		s   summarizer.Summarizer
+		err error
+	)
+
+	switch summarizerCfg.Provider {
+	case summarizer.ProviderOpenAI:
+		if apiKey == "" {
+			log.WarnContext(ctx, "OPENAI_API_KEY is missing so fallback will be used",
+				"envVar", "OPENAI_API_KEY")
+
+			return nil
+		}
+
+		s, err = summarizer.NewOpenAISummarizer(apiKey, cfg, summarizerCfg, m)
+	case summarizer.ProviderOpenAICompatible:
+		s, err = summarizer.NewOpenAICompatibleSummarizer(apiKey, cfg, summarizerCfg, m)
+	case summarizer.ProviderOllama:
+		s, err = summarizer.NewOllamaSummarizer(cfg, summarizerCfg, m)
+	default:
+		log.ErrorContext(ctx, "Unknown summarizer provider so fallback will be used",
+			"provider", summarizerCfg.Provider,
+			"envVar", "SUMMARIZER_PROVIDER")
 
 		return nil
 	}
 
-	s, err := summarizer.NewOpenAISummarizer(apiKey, cfg)
 	if err != nil {
-		log.ErrorContext(ctx, "Failed to create OpenAI summarizer so fallback will be used",
+		log.ErrorContext(ctx, "Failed to create summarizer so fallback will be used",
 			"error", err,
-			"envVar", "OPENAI_API_KEY")
+			"provider", summarizerCfg.Provider)
 
 		return nil
 	}
 
-	log.InfoContext(ctx, "OpenAI summarizer is initialized",
-		"provider", "openai")
+	log.InfoContext(ctx, "Summarizer is initialized",
+		"provider", summarizerCfg.Provider,
+		"baseURL", summarizerCfg.BaseURL)
 
 	return s
 }
+
+// initDigestOverviewSummarizer creates a summarizer with the DIGEST_OVERVIEW_
+// prompt and output token limits, returning nil when overviews are disabled.
+func initDigestOverviewSummarizer(
+	ctx context.Context,
+	cfg config.Config,
+	m *metrics.Metrics,
+	log *slog.Logger,
+) summarizer.Summarizer {
+	if !cfg.DigestOverview.Enabled {
+		return nil
+	}
+
+	openAICfg := cfg.OpenAI
+	openAICfg.SystemPrompt = cfg.DigestOverview.SystemPrompt
+	openAICfg.BaseMaxOutputTokens = cfg.DigestOverview.BaseMaxOutputTokens
+	openAICfg.LimitMaxOutputTokens = cfg.DigestOverview.LimitMaxOutputTokens
+
+	return initOpenAISummarizer(ctx, cfg.OpenAIAPIKey, openAICfg, cfg.Summarizer, m, log)
+}
@@ -5,65 +5,83 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"slices"
 	"strings"
 	"telekilogram/internal/config"
 	"telekilogram/internal/database"
+	"telekilogram/internal/domain"
 	"telekilogram/internal/feed"
+	"telekilogram/internal/metrics"
 	"telekilogram/internal/ratelimiter"
+	"telekilogram/internal/summarizer"
 
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
 )
 
 type Bot struct {
-	api         *bot.Bot
-	rateLimiter *ratelimiter.RateLimiter
-	db          *database.Database
-	fetcher     *feed.Fetcher
+	api           *bot.Bot
+	rateLimiter   *ratelimiter.RateLimiter
+	webhookServer *http.Server
+	db            *database.Database
+	fetcher       *feed.Fetcher
+	feedChoices   *pendingStore[domain.FeedChoice]
+	filterDrafts  *pendingStore[domain.FilterRule]
+	feedRenames   *pendingStore[feedRename]
 
 	allowedUsers []int64
 
-	returnKeyboard                    [][]models.InlineKeyboardButton
-	settingsAutoDigestHourUTCKeyboard [][]models.InlineKeyboardButton
-	menuKeyboard                      [][]models.InlineKeyboardButton
+	returnKeyboard [][]models.InlineKeyboardButton
+	menuKeyboard   [][]models.InlineKeyboardButton
 
-	cfg config.BotConfig
-	log *slog.Logger
+	overviewSummarizer summarizer.Summarizer
+
+	cfg         config.BotConfig
+	overviewCfg config.DigestOverviewConfig
+	log         *slog.Logger
 }
 
 func New(
 	token string,
 	db *database.Database,
 	fetcher *feed.Fetcher,
+	overviewSummarizer summarizer.Summarizer,
+	m *metrics.Metrics,
 	allowedUsers []int64,
 	cfg config.BotConfig,
+	overviewCfg config.DigestOverviewConfig,
 	rateLimiterCfg config.RateLimiterConfig,
 	log *slog.Logger,
 ) (*Bot, error) {
 	token = strings.TrimSpace(token)
-	allowedUpdates := bot.AllowedUpdates{
-		models.AllowedUpdateMessage,
-		models.AllowedUpdateCallbackQuery,
+
+	if err := validateModeConfig(cfg); err != nil {
+		return nil, fmt.Errorf("validate mode config: %w", err)
 	}
 
 	b := &Bot{
-		db:      db,
-		fetcher: fetcher,
+		db:           db,
+		fetcher:      fetcher,
+		feedChoices:  newPendingStore[domain.FeedChoice](pendingTTL),
+		filterDrafts: newPendingStore[domain.FilterRule](pendingTTL),
+		feedRenames:  newPendingStore[feedRename](pendingTTL),
 
 		allowedUsers: allowedUsers,
 
-		returnKeyboard:                    getReturnKeyboard(),
-		settingsAutoDigestHourUTCKeyboard: getSettingsAutoDigestHourUTCKeyboard(),
-		menuKeyboard:                      getMenuKeyboard(),
+		returnKeyboard: getReturnKeyboard(),
+		menuKeyboard:   getMenuKeyboard(),
+
+		overviewSummarizer: overviewSummarizer,
 
-		cfg: cfg,
-		log: log,
+		cfg:         cfg,
+		overviewCfg: overviewCfg,
+		log:         log,
 	}
 
 	api, err := bot.New(
 		token,
-		bot.WithAllowedUpdates(allowedUpdates),
+		bot.WithAllowedUpdates(allowedUpdates()),
 		bot.WithErrorsHandler(func(err error) {
 			log.Error("Telegram runtime error", "error", err)
 		}),
@@ -77,21 +95,39 @@ func New(
 	}
 
 	b.api = api
-	b.rateLimiter = ratelimiter.New(api, rateLimiterCfg, log)
+	b.rateLimiter = ratelimiter.New(api, rateLimiterCfg, m, log)
 
 	return b, nil
 }
 
-func (b *Bot) Start(ctx context.Context) {
-	b.api.Start(ctx)
+// Start receives updates with long polling or a webhook, depending on BOT_MODE,
+// until ctx is done. It doesn't block.
+func (b *Bot) Start(ctx context.Context) error {
+	if b.cfg.Mode == ModeWebhook {
+		return b.startWebhook(ctx)
+	}
+
+	return b.startPolling(ctx)
 }
 
 func (b *Bot) Stop() {
+	if err := b.stopWebhook(); err != nil {
+		b.log.Error("Failed to stop webhook",
+			"error", err)
+	}
+
 	if b.rateLimiter != nil {
 		b.rateLimiter.Stop()
 	}
 }
 
+func allowedUpdates() bot.AllowedUpdates {
+	return bot.AllowedUpdates{
+		models.AllowedUpdateMessage,
+		models.AllowedUpdateCallbackQuery,
+	}
+}
+
 func (b *Bot) handleUpdate(ctx context.Context, update *models.Update) {
 	updateCtx, cancel := context.WithTimeout(ctx, b.cfg.UpdateProcessingTimeout)
 	defer cancel()
@@ -119,6 +155,13 @@ func (b *Bot) handleUpdate(ctx context.Context, update *models.Update) {
 			return
 		}
 
+		// A user who blocked the bot gets digests again after writing to it.
+		if err := b.db.MarkUserActive(updateCtx, userID); err != nil {
+			b.log.ErrorContext(updateCtx, "Failed to mark user active",
+				"error", err,
+				"userID", userID)
+		}
+
 		if err := b.handleMessage(updateCtx, update.Message); err != nil {
 			b.log.ErrorContext(updateCtx, "Failed to handle message",
 				"error", err,
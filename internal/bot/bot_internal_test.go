@@ -1,12 +1,30 @@
 package bot
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"telekilogram/internal/config"
+	"telekilogram/internal/database"
 	"telekilogram/internal/domain"
+	"telekilogram/internal/ratelimiter"
+	"telekilogram/internal/summarizer"
 	"testing"
+	"time"
 	"unicode/utf8"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
 )
 
 func botWithIssueURL(url string) *Bot {
@@ -318,7 +336,7 @@ func TestFormatPostsAsMessagesRespectsLimit(t *testing.T) {
 		})
 	}
 
-	messages := b.formatPostsAsMessages(t.Context(), posts)
+	messages := b.formatPostsAsMessages(t.Context(), posts, nil)
 	if len(messages) < 2 {
 		t.Fatalf("expected multiple digest messages, got %d", len(messages))
 	}
@@ -329,3 +347,1300 @@ func TestFormatPostsAsMessagesRespectsLimit(t *testing.T) {
 		}
 	}
 }
+
+func TestFormatPostsAsMessagesLinksHiddenPosts(t *testing.T) {
+	b := &Bot{log: slog.Default()}
+
+	post := func(feedID int64, id string) domain.Post {
+		return domain.Post{
+			FeedID:    feedID,
+			FeedTitle: "Feed",
+			FeedURL:   "https://t.me/s/channel" + strconv.FormatInt(feedID, 10),
+			Title:     "Post " + id,
+			URL:       "https://t.me/channel/" + id,
+		}
+	}
+
+	posts := []domain.Post{post(1, "1"), post(2, "2")}
+	hidden := []domain.Post{post(1, "3"), post(1, "4")}
+
+	messages := b.formatPostsAsMessages(t.Context(), posts, hidden)
+	if len(messages) != 1 {
+		t.Fatalf("expected one digest message, got %d", len(messages))
+	}
+
+	want := "– […and 2 more](https://t.me/s/channel1)\n\n📌"
+	if !strings.Contains(messages[0], want) {
+		t.Fatalf("expected hidden posts link %q after the first feed, got %q", want, messages[0])
+	}
+
+	if strings.Count(messages[0], "more]") != 1 {
+		t.Fatalf("expected a hidden posts link for the first feed only, got %q", messages[0])
+	}
+}
+
+func TestFormatPostsAsMessagesOrdersFeedsByPosition(t *testing.T) {
+	b := &Bot{log: slog.Default()}
+
+	posts := []domain.Post{
+		{FeedID: 1, FeedPosition: 2, FeedTitle: "First added", URL: "https://example.com/a/1"},
+		{FeedID: 2, FeedPosition: 1, FeedTitle: "Moved up", URL: "https://example.com/b/1"},
+	}
+
+	messages := b.formatPostsAsMessages(t.Context(), posts, nil)
+	if len(messages) != 1 {
+		t.Fatalf("expected one digest message, got %d", len(messages))
+	}
+
+	if strings.Index(messages[0], "Moved up") > strings.Index(messages[0], "First added") {
+		t.Fatalf("expected feed groups ordered by position, got %q", messages[0])
+	}
+}
+
+type stubOverviewSummarizer struct {
+	overview string
+	err      error
+	input    string
+}
+
+func (s *stubOverviewSummarizer) Summarize(_ context.Context, input summarizer.Input) (string, error) {
+	s.input = input.Text
+	return s.overview, s.err
+}
+
+func digestOverviewTestPosts(count int) []domain.Post {
+	posts := make([]domain.Post, 0, count)
+	for i := range count {
+		posts = append(posts, domain.Post{
+			FeedID:    int64(i%2 + 1),
+			FeedTitle: "Feed",
+			FeedURL:   "https://example.com/feed",
+			Title:     "Post",
+			URL:       "https://example.com/posts/" + strings.Repeat("1", i+1),
+			Summary:   "Summary",
+		})
+	}
+	return posts
+}
+
+func TestFormatPostsAsMessagesPrependsDigestOverview(t *testing.T) {
+	stub := &stubOverviewSummarizer{overview: "Tech: things happened."}
+	b := &Bot{
+		overviewSummarizer: stub,
+		overviewCfg:        config.DigestOverviewConfig{MinPosts: 2, MaxChars: 100},
+		log:                slog.Default(),
+	}
+
+	messages := b.formatPostsAsMessages(t.Context(), digestOverviewTestPosts(3), nil)
+	if len(messages) != 1 {
+		t.Fatalf("expected one message, got %d", len(messages))
+	}
+
+	want := "📰 *New posts*\n\n🧭 *Overview*\n\nTech: things happened\\.\n\n📌"
+	if !strings.HasPrefix(messages[0], want) {
+		t.Fatalf("expected overview after the header, got %q", messages[0])
+	}
+
+	if !strings.Contains(stub.input, "- Feed: Post — Summary\n") {
+		t.Fatalf("expected titles and summaries in overview input, got %q", stub.input)
+	}
+}
+
+func TestFormatPostsAsMessagesSkipsDigestOverview(t *testing.T) {
+	tests := []struct {
+		name  string
+		stub  *stubOverviewSummarizer
+		posts int
+	}{
+		{name: "error", stub: &stubOverviewSummarizer{err: errors.New("boom")}, posts: 3},
+		{name: "too few posts", stub: &stubOverviewSummarizer{overview: "Overview"}, posts: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &Bot{
+				overviewSummarizer: tt.stub,
+				overviewCfg:        config.DigestOverviewConfig{MinPosts: 2},
+				log:                slog.Default(),
+			}
+
+			messages := b.formatPostsAsMessages(t.Context(), digestOverviewTestPosts(tt.posts), nil)
+			if len(messages) != 1 || strings.Contains(messages[0], "Overview") {
+				t.Fatalf("expected a digest without overview, got %q", messages)
+			}
+		})
+	}
+}
+
+func TestDigestOverviewInputRespectsMaxChars(t *testing.T) {
+	got := digestOverviewInput(digestOverviewTestPosts(10), 50)
+
+	if utf8.RuneCountInString(got) > 50 {
+		t.Fatalf("expected input within 50 chars, got %d", utf8.RuneCountInString(got))
+	}
+	if !strings.HasPrefix(got, "- Feed: Post — Summary\n") {
+		t.Fatalf("expected first post in input, got %q", got)
+	}
+}
+
+func TestFeedUnhealthyUsesFailurePeriod(t *testing.T) {
+	b := &Bot{cfg: config.BotConfig{FeedHealthFailurePeriod: 24 * time.Hour}}
+	failingSince := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	shortOutage := domain.FeedHealth{
+		FailingSince:        failingSince,
+		LastErrorAt:         failingSince.Add(time.Hour),
+		ConsecutiveFailures: 100,
+	}
+	if b.feedUnhealthy(domain.UserFeed{Health: shortOutage}) {
+		t.Fatal("feed failing often for less than the period should be healthy")
+	}
+
+	longOutage := domain.FeedHealth{
+		FailingSince:        failingSince,
+		LastErrorAt:         failingSince.Add(24 * time.Hour),
+		ConsecutiveFailures: 2,
+	}
+	if !b.feedUnhealthy(domain.UserFeed{Health: longOutage}) {
+		t.Fatal("feed failing for the whole period should be unhealthy")
+	}
+
+	if b.feedUnhealthy(domain.UserFeed{Health: domain.FeedHealth{LastErrorAt: failingSince}}) {
+		t.Fatal("feed without a failure streak should be healthy")
+	}
+
+	b.cfg.FeedHealthFailurePeriod = 0
+	if b.feedUnhealthy(domain.UserFeed{Health: longOutage}) {
+		t.Fatal("zero period should disable health tracking")
+	}
+}
+
+func TestGetFeedsForHealthAlertSkipsMutedAndInactive(t *testing.T) {
+	ctx := t.Context()
+
+	db, err := database.New(ctx, filepath.Join(t.TempDir(), "test.db"), slog.Default())
+	if err != nil {
+		t.Fatalf("new database: %v", err)
+	}
+
+	const feedURL = "https://example.com/feed.xml"
+	for userID := int64(1); userID <= 3; userID++ {
+		if err = db.AddFeed(ctx, userID, feedURL, "Example"); err != nil {
+			t.Fatalf("add feed: %v", err)
+		}
+	}
+
+	muted, err := db.GetUserFeeds(ctx, 2)
+	if err != nil {
+		t.Fatalf("get user feeds: %v", err)
+	}
+	if err = db.UpdateFeedDeliveryMode(ctx, 2, muted[0].ID, domain.DeliveryModeMuted); err != nil {
+		t.Fatalf("update feed delivery mode: %v", err)
+	}
+	if err = db.MarkUserInactive(ctx, 3, time.Now()); err != nil {
+		t.Fatalf("mark user inactive: %v", err)
+	}
+
+	failingSince := time.Date(2025, 1, 1, 9, 0, 0, 0, time.UTC)
+	for _, failedAt := range []time.Time{failingSince, failingSince.Add(time.Hour)} {
+		if err = db.RecordSourceFailure(ctx, muted[0].SourceID, 500, "unexpected status: 500", failedAt); err != nil {
+			t.Fatalf("record source failure: %v", err)
+		}
+	}
+
+	feeds, err := db.GetFeedsForHealthAlert(ctx)
+	if err != nil {
+		t.Fatalf("get feeds for health alert: %v", err)
+	}
+
+	if len(feeds) != 1 || feeds[0].UserID != 1 {
+		t.Fatalf("expected an alert for user 1 only, got %+v", feeds)
+	}
+	if !feeds[0].Health.FailingSince.Equal(failingSince) {
+		t.Fatalf("expected failure streak to start at %v, got %v", failingSince, feeds[0].Health.FailingSince)
+	}
+
+	if err = db.RecordSourceSuccess(ctx, muted[0].SourceID, 200, failingSince.Add(2*time.Hour)); err != nil {
+		t.Fatalf("record source success: %v", err)
+	}
+
+	feeds, err = db.GetFeedsForHealthAlert(ctx)
+	if err != nil {
+		t.Fatalf("get feeds for health alert: %v", err)
+	}
+	if len(feeds) != 0 {
+		t.Fatalf("expected no alerts after a successful fetch, got %+v", feeds)
+	}
+}
+
+func TestFeedHealthAlertTextEscapesDetails(t *testing.T) {
+	got := feedHealthAlertText(domain.UserFeed{
+		URL:   "https://example.com/feed.xml",
+		Title: "Example",
+		Health: domain.FeedHealth{
+			LastError:           "do request: unexpected status: 404",
+			FailingSince:        time.Date(2024, 12, 30, 8, 0, 0, 0, time.UTC),
+			ConsecutiveFailures: 4,
+			LastHTTPStatus:      404,
+			LastSuccessAt:       time.Date(2025, 1, 2, 3, 4, 0, 0, time.UTC),
+		},
+	})
+
+	for _, want := range []string{
+		"[Example](https://example.com/feed.xml)",
+		"failing since 2024\\-12\\-30 08:00 \\(UTC\\), 4 fetches in a row",
+		"Last HTTP status: 404",
+		"2025\\-01\\-02 03:04",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("feedHealthAlertText() should contain %q, got %q", want, got)
+		}
+	}
+}
+
+func TestFeedHealthAlertTextWithoutHistory(t *testing.T) {
+	got := feedHealthAlertText(domain.UserFeed{URL: "https://example.com/feed.xml"})
+
+	if !strings.Contains(got, "Last HTTP status: none") || !strings.Contains(got, "\\(UTC\\): never") {
+		t.Fatalf("feedHealthAlertText() should describe missing history, got %q", got)
+	}
+}
+
+func TestPendingStoreScopesValuesToUser(t *testing.T) {
+	store := newPendingStore[domain.FeedChoice](time.Hour)
+	choice := domain.FeedChoice{
+		PageURL: "https://example.com",
+		Feeds:   []domain.Feed{{URL: "https://example.com/rss.xml", Title: "Posts"}},
+	}
+
+	token, err := store.put(1, choice)
+	if err != nil {
+		t.Fatalf("put() error = %v", err)
+	}
+
+	if _, ok := store.get(token, 2); ok {
+		t.Fatalf("get() should not return a choice to another user")
+	}
+
+	got, ok := store.get(token, 1)
+	if !ok || got.PageURL != choice.PageURL || len(got.Feeds) != 1 {
+		t.Fatalf("get() = %+v, %v, want stored choice", got, ok)
+	}
+}
+
+func TestPendingStoreExpiresValues(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := newPendingStore[domain.FeedChoice](time.Hour)
+	store.now = func() time.Time { return now }
+
+	token, err := store.put(1, domain.FeedChoice{PageURL: "https://example.com"})
+	if err != nil {
+		t.Fatalf("put() error = %v", err)
+	}
+
+	now = now.Add(time.Hour)
+
+	if _, ok := store.get(token, 1); ok {
+		t.Fatalf("get() should not return an expired choice")
+	}
+}
+
+func TestFeedChoiceKeyboardFitsCallbackData(t *testing.T) {
+	feeds := []domain.Feed{
+		{URL: "https://example.com/rss.xml", Title: strings.Repeat("Long title ", 10)},
+		{URL: "https://example.com/atom.xml"},
+	}
+
+	keyboard := getFeedChoiceKeyboard(strings.Repeat("a", pendingTokenBytes*2), feeds)
+
+	if len(keyboard) != len(feeds)+2 {
+		t.Fatalf("keyboard has %d rows, want %d", len(keyboard), len(feeds)+2)
+	}
+
+	for _, row := range keyboard {
+		for _, button := range row {
+			if len(button.CallbackData) > 64 {
+				t.Fatalf("callback data %q exceeds 64 bytes", button.CallbackData)
+			}
+		}
+	}
+
+	if got := keyboard[1][0].Text; got != "➕ https://example.com/atom.xml" {
+		t.Fatalf("untitled feed button text = %q", got)
+	}
+
+	if got := utf8.RuneCountInString(keyboard[0][0].Text); got > feedChoiceTitleMaxLen+2 {
+		t.Fatalf("long title button text has %d runes", got)
+	}
+}
+
+func TestOPMLImportReportText(t *testing.T) {
+	got := opmlImportReportText([]opmlImportResult{
+		{feed: domain.Feed{URL: "https://example.com/rss.xml", Title: "Example"}, status: opmlImportAdded},
+		{feed: domain.Feed{URL: "https://t.me/s/durov", Title: "Durov"}, status: opmlImportAlreadyFollowed},
+		{feed: domain.Feed{URL: "https://blog.example.com/"}, status: opmlImportAmbiguous},
+		{feed: domain.Feed{URL: "https://broken.example.com/feed"}, status: opmlImportInvalid},
+	})
+
+	for _, want := range []string{
+		"Added: 1\nAlready followed: 1\nAmbiguous: 1\nInvalid: 1",
+		"❔ https://blog\\.example\\.com/ \\(several feeds found, send the URL to pick one\\)",
+		"✅ [Example](https://example.com/rss.xml)",
+		"☑️ [Durov](https://t.me/s/durov) \\(already followed\\)",
+		"❌ https://broken\\.example\\.com/feed \\(invalid\\)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("opmlImportReportText() should contain %q, got %q", want, got)
+		}
+	}
+}
+
+func TestKeepOPMLTitle(t *testing.T) {
+	ctx := t.Context()
+
+	db, err := database.New(ctx, filepath.Join(t.TempDir(), "test.db"), slog.Default())
+	if err != nil {
+		t.Fatalf("new database: %v", err)
+	}
+
+	b := &Bot{db: db, log: slog.Default()}
+
+	renamed := domain.Feed{URL: "https://example.com/renamed.xml", Title: "Original"}
+	same := domain.Feed{URL: "https://example.com/same.xml", Title: "Same"}
+
+	for _, f := range []domain.Feed{renamed, same} {
+		if err = db.AddFeed(ctx, 1, f.URL, f.Title); err != nil {
+			t.Fatalf("add feed: %v", err)
+		}
+	}
+
+	if err = b.keepOPMLTitle(ctx, 1, renamed, " Renamed "); err != nil {
+		t.Fatalf("keepOPMLTitle() error = %v", err)
+	}
+	if err = b.keepOPMLTitle(ctx, 1, same, "Same"); err != nil {
+		t.Fatalf("keepOPMLTitle() error = %v", err)
+	}
+
+	feeds, err := db.GetUserFeeds(ctx, 1)
+	if err != nil {
+		t.Fatalf("get user feeds: %v", err)
+	}
+
+	if feeds[0].CustomTitle != "Renamed" || feeds[1].CustomTitle != "" {
+		t.Fatalf("unexpected custom titles %q and %q", feeds[0].CustomTitle, feeds[1].CustomTitle)
+	}
+}
+
+func TestIsOPMLDocument(t *testing.T) {
+	tests := []struct {
+		document *models.Document
+		want     bool
+	}{
+		{document: nil, want: false},
+		{document: &models.Document{FileName: "subs.OPML"}, want: true},
+		{document: &models.Document{FileName: "subs", MimeType: "text/x-opml"}, want: true},
+		{document: &models.Document{FileName: "photo.jpg", MimeType: "image/jpeg"}, want: false},
+	}
+
+	for _, tt := range tests {
+		if got := isOPMLDocument(tt.document); got != tt.want {
+			t.Fatalf("isOPMLDocument(%+v) = %v, want %v", tt.document, got, tt.want)
+		}
+	}
+}
+
+func TestParseFilterRule(t *testing.T) {
+	tests := []struct {
+		args    string
+		want    domain.FilterRule
+		wantErr bool
+	}{
+		{args: "include golang", want: domain.FilterRule{Pattern: "golang"}},
+		{args: "exclude regex case ^\\[Ad\\] now", want: domain.FilterRule{
+			Exclude: true, Regex: true, CaseSensitive: true, Pattern: "^\\[Ad\\] now",
+		}},
+		{args: "Exclude keyword case", want: domain.FilterRule{Exclude: true, Pattern: "case"}},
+		{args: "include regex", want: domain.FilterRule{Pattern: "regex"}},
+		{args: "include", wantErr: true},
+		{args: "drop golang", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseFilterRule(tt.args)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("parseFilterRule(%q) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Fatalf("parseFilterRule(%q) = %+v, want %+v", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestFilterRuleDescriptionEscapesPattern(t *testing.T) {
+	got := filterRuleDescription(domain.FilterRule{
+		FeedID:    1,
+		FeedTitle: "Go. Blog",
+		Exclude:   true,
+		Regex:     true,
+		Pattern:   "a`b\\c",
+	})
+
+	want := "➖ exclude regex `a\\`b\\\\c` in Go\\. Blog"
+	if got != want {
+		t.Fatalf("filterRuleDescription() = %q, want %q", got, want)
+	}
+}
+
+func TestWithFilteredPostsFooter(t *testing.T) {
+	if got := withFilteredPostsFooter([]string{"posts\n\n"}, 0); got[0] != "posts\n\n" {
+		t.Fatalf("withFilteredPostsFooter() should not change messages without filtered posts, got %q", got)
+	}
+
+	got := withFilteredPostsFooter([]string{"posts\n\n"}, 2)
+	if len(got) != 1 || !strings.HasSuffix(got[0], filteredPostsText(2)) {
+		t.Fatalf("withFilteredPostsFooter() = %q, want footer appended", got)
+	}
+
+	full := strings.Repeat("a", telegramMessageMaxLength)
+	got = withFilteredPostsFooter([]string{full}, 1)
+	if len(got) != 2 || got[1] != filteredPostsText(1) {
+		t.Fatalf("withFilteredPostsFooter() should send footer separately when message is full, got %d messages", len(got))
+	}
+}
+
+func TestTimezoneCandidates(t *testing.T) {
+	tests := []struct {
+		name      string
+		latitude  float64
+		longitude float64
+		want      string
+	}{
+		{name: "Madrid", latitude: 40.4168, longitude: -3.7038, want: "Europe/Madrid"},
+		{name: "New York", latitude: 40.7128, longitude: -74.006, want: "America/New_York"},
+		{name: "Urumqi", latitude: 43.8256, longitude: 87.6168, want: "Asia/Urumqi"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := timezoneCandidates(tt.latitude, tt.longitude)
+			if len(got) != timezoneCandidateCount || got[0] != tt.want {
+				t.Fatalf("timezoneCandidates() = %v, want %s first", got, tt.want)
+			}
+
+			for _, name := range got {
+				if strings.HasPrefix(name, "Etc/") {
+					t.Fatalf("timezoneCandidates() = %v, want named zones only", got)
+				}
+			}
+		})
+	}
+}
+
+func TestParseISO6709(t *testing.T) {
+	latitude, longitude, ok := parseISO6709("+404251-0034104")
+	if !ok || math.Abs(latitude-40.714167) > 1e-4 || math.Abs(longitude+3.684444) > 1e-4 {
+		t.Fatalf("parseISO6709() = %v, %v, %v", latitude, longitude, ok)
+	}
+
+	if _, _, ok = parseISO6709("+4024"); ok {
+		t.Fatal("parseISO6709() should reject coordinates without longitude")
+	}
+}
+
+func TestTimezoneCandidatesKeyboardShowsOffsets(t *testing.T) {
+	now := time.Date(2026, 7, 1, 12, 0, 0, 0, time.UTC)
+
+	keyboard := getTimezoneCandidatesKeyboard([]string{"Europe/Madrid"}, now)
+	if len(keyboard) != 2 {
+		t.Fatalf("expected a candidate row and a back row, got %d rows", len(keyboard))
+	}
+
+	button := keyboard[0][0]
+	wantData := settingsTimezoneCallbackPrefix + "Europe/Madrid"
+	if button.Text != "Europe/Madrid (UTC+02:00)" || button.CallbackData != wantData {
+		t.Fatalf("unexpected candidate button: %+v", button)
+	}
+}
+
+func TestLoadUserTimezone(t *testing.T) {
+	if _, err := loadUserTimezone("Europe/Berlin"); err != nil {
+		t.Fatalf("loadUserTimezone() error = %v", err)
+	}
+
+	for _, name := range []string{"", "Local", "Mars/Olympus"} {
+		if _, err := loadUserTimezone(name); err == nil {
+			t.Fatalf("loadUserTimezone(%q) should fail", name)
+		}
+	}
+}
+
+func TestWeekdayMaskText(t *testing.T) {
+	tests := []struct {
+		weekdays int64
+		want     string
+	}{
+		{weekdays: domain.EveryDayMask, want: "every day"},
+		{weekdays: domain.WorkdaysMask, want: "on workdays"},
+		{weekdays: domain.WeekendsMask, want: "on weekends"},
+		{weekdays: domain.WeekdayMask(time.Sunday) | domain.WeekdayMask(time.Wednesday), want: "on Wed, Sun"},
+	}
+
+	for _, tt := range tests {
+		if got := weekdayMaskText(tt.weekdays); got != tt.want {
+			t.Fatalf("weekdayMaskText(%b) = %q, want %q", tt.weekdays, got, tt.want)
+		}
+	}
+}
+
+func TestParseDigestSlot(t *testing.T) {
+	got, err := parseDigestSlot("62_08")
+	if err != nil {
+		t.Fatalf("parseDigestSlot() error = %v", err)
+	}
+	if got.Hour != 8 || got.Weekdays != domain.WorkdaysMask {
+		t.Fatalf("parseDigestSlot() = %+v", got)
+	}
+
+	for _, data := range []string{"", "62", "0_08", "128_08", "62_24", "62_-1", "x_08"} {
+		if _, err = parseDigestSlot(data); err == nil {
+			t.Fatalf("parseDigestSlot(%q) should fail", data)
+		}
+	}
+}
+
+func TestDigestSlotKeyboardsFitCallbackData(t *testing.T) {
+	keyboards := [][][]models.InlineKeyboardButton{
+		getSettingsKeyboard([]domain.DigestSchedule{{ID: 1<<63 - 1, Hour: 23, Weekdays: domain.EveryDayMask}}),
+		getSettingsDigestSlotDaysKeyboard(),
+		getSettingsDigestSlotHourKeyboard(domain.EveryDayMask),
+	}
+
+	for _, keyboard := range keyboards {
+		for _, row := range keyboard {
+			for _, button := range row {
+				if len(button.CallbackData) > 64 {
+					t.Fatalf("callback data %q exceeds 64 bytes", button.CallbackData)
+				}
+			}
+		}
+	}
+
+	hours := 0
+	for _, row := range getSettingsDigestSlotHourKeyboard(domain.WorkdaysMask) {
+		for _, button := range row {
+			if strings.HasPrefix(button.CallbackData, settingsDigestSlotAddCallbackPrefix) {
+				if _, err := parseDigestSlot(
+					strings.TrimPrefix(button.CallbackData, settingsDigestSlotAddCallbackPrefix),
+				); err != nil {
+					t.Fatalf("parseDigestSlot(%q) error = %v", button.CallbackData, err)
+				}
+				hours++
+			}
+		}
+	}
+	if hours != hoursPerDay {
+		t.Fatalf("hour keyboard has %d hours, want %d", hours, hoursPerDay)
+	}
+}
+
+func TestParseDeliveryMode(t *testing.T) {
+	for _, mode := range deliveryModes() {
+		got, err := parseDeliveryMode(string(mode))
+		if err != nil || got != mode {
+			t.Fatalf("parseDeliveryMode(%q) = %q, %v", mode, got, err)
+		}
+	}
+
+	if _, err := parseDeliveryMode("loud"); err == nil {
+		t.Fatalf("parseDeliveryMode() should fail for unknown modes")
+	}
+}
+
+func TestFeedMenuKeyboardMarksCurrentMode(t *testing.T) {
+	keyboard := getFeedMenuKeyboard(domain.UserFeed{ID: 1<<63 - 1, DeliveryMode: domain.DeliveryModeRealtime})
+
+	var marked []string
+	for _, button := range keyboard[0] {
+		if len(button.CallbackData) > 64 {
+			t.Fatalf("callback data %q exceeds 64 bytes", button.CallbackData)
+		}
+
+		modeData := strings.TrimPrefix(button.CallbackData, feedModeCallbackPrefix)
+		if _, modeStr, _ := strings.Cut(modeData, "_"); modeStr == "" {
+			t.Fatalf("callback data %q has no mode", button.CallbackData)
+		}
+
+		if strings.HasPrefix(button.Text, "✅ ") {
+			marked = append(marked, button.CallbackData)
+		}
+	}
+
+	want := feedModeCallbackPrefix + "9223372036854775807_realtime"
+	if len(marked) != 1 || marked[0] != want {
+		t.Fatalf("marked buttons = %v, want [%s]", marked, want)
+	}
+}
+
+func TestPostBulletPointAddsEscapedSummary(t *testing.T) {
+	post := domain.Post{Title: "Weekly notes", URL: "https://example.com/1"}
+
+	if got := postBulletPoint(post); got != "– [Weekly notes](https://example.com/1)\n\n" {
+		t.Fatalf("postBulletPoint() without summary = %q", got)
+	}
+
+	post.Summary = " Release 1.2 adds\n dark_mode. "
+	want := "– [Weekly notes](https://example.com/1)\n_Release 1\\.2 adds dark\\_mode\\._\n\n"
+	if got := postBulletPoint(post); got != want {
+		t.Fatalf("postBulletPoint() = %q, want %q", got, want)
+	}
+}
+
+func TestPostBulletPointShowsTelegramMeta(t *testing.T) {
+	post := domain.Post{
+		Title: "Video: launch recap",
+		URL:   "https://t.me/example/2",
+		Telegram: domain.TelegramPostMeta{
+			MediaKind:     domain.MediaKindVideo,
+			Views:         1250,
+			ForwardedFrom: "Other_Channel",
+			Edited:        true,
+		},
+	}
+
+	want := "– 🎬 [Video: launch recap](https://t.me/example/2) ↪️ Other\\_Channel · 👁 1\\.2K · ✏️\n\n"
+	if got := postBulletPoint(post); got != want {
+		t.Fatalf("postBulletPoint() = %q, want %q", got, want)
+	}
+}
+
+func TestPostBulletPointShowsReplyContext(t *testing.T) {
+	post := domain.Post{
+		Title:    "Dates are confirmed",
+		URL:      "https://t.me/example/3",
+		Telegram: domain.TelegramPostMeta{ReplyTo: "Earlier announcement: v2.0 ships soon"},
+	}
+
+	want := "– [Dates are confirmed](https://t.me/example/3)\n💬 Earlier announcement: v2\\.0 ships soon\n\n"
+	if got := postBulletPoint(post); got != want {
+		t.Fatalf("postBulletPoint() = %q, want %q", got, want)
+	}
+
+	post.Telegram.ReplyTo = strings.Repeat("a", replyToMaxLength+10)
+	want = "– [Dates are confirmed](https://t.me/example/3)\n💬 " + strings.Repeat("a", replyToMaxLength) + "…\n\n"
+	if got := postBulletPoint(post); got != want {
+		t.Fatalf("postBulletPoint() with long reply = %q, want %q", got, want)
+	}
+}
+
+func TestFormatViews(t *testing.T) {
+	tests := map[int64]string{
+		987:       "987",
+		1000:      "1K",
+		15_340:    "15.3K",
+		3_000_000: "3M",
+	}
+
+	for views, want := range tests {
+		if got := formatViews(views); got != want {
+			t.Errorf("formatViews(%d) = %q, want %q", views, got, want)
+		}
+	}
+}
+
+func TestFeedMenuShowsSummaryToggleForNonTelegramFeeds(t *testing.T) {
+	hasToggle := func(f domain.UserFeed) (string, bool) {
+		for _, row := range getFeedMenuKeyboard(f) {
+			for _, button := range row {
+				if strings.HasPrefix(button.CallbackData, feedSummaryCallbackPrefix) {
+					return button.CallbackData, true
+				}
+			}
+		}
+
+		return "", false
+	}
+
+	if _, ok := hasToggle(domain.UserFeed{ID: 1, URL: "https://t.me/s/durov"}); ok {
+		t.Fatalf("Telegram feed menu should not have a summary toggle")
+	}
+
+	data, ok := hasToggle(domain.UserFeed{ID: 2, URL: "https://example.com/rss.xml"})
+	if !ok || data != feedSummaryCallbackPrefix+"2_"+feedSummaryOnOption {
+		t.Fatalf("summary toggle = %q, %v", data, ok)
+	}
+
+	data, _ = hasToggle(domain.UserFeed{ID: 2, URL: "https://example.com/rss.xml", SummarizeItems: true})
+	if data != feedSummaryCallbackPrefix+"2_"+feedSummaryOffOption {
+		t.Fatalf("summary toggle for enabled feed = %q", data)
+	}
+}
+
+func TestValidateModeConfig(t *testing.T) {
+	webhook := config.BotConfig{
+		Mode:               ModeWebhook,
+		WebhookPath:        "/webhook",
+		WebhookURL:         "https://example.com/webhook",
+		WebhookSecretToken: "secret",
+	}
+
+	tests := []struct {
+		name    string
+		cfg     config.BotConfig
+		wantErr bool
+	}{
+		{name: "polling", cfg: config.BotConfig{Mode: ModePolling}},
+		{name: "webhook", cfg: webhook},
+		{name: "unknown mode", cfg: config.BotConfig{Mode: "push"}, wantErr: true},
+		{name: "webhook without URL", cfg: func() config.BotConfig {
+			cfg := webhook
+			cfg.WebhookURL = ""
+			return cfg
+		}(), wantErr: true},
+		{name: "webhook without secret token", cfg: func() config.BotConfig {
+			cfg := webhook
+			cfg.WebhookSecretToken = " "
+			return cfg
+		}(), wantErr: true},
+		{name: "webhook with relative path", cfg: func() config.BotConfig {
+			cfg := webhook
+			cfg.WebhookPath = "webhook"
+			return cfg
+		}(), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateModeConfig(tt.cfg); (err != nil) != tt.wantErr {
+				t.Fatalf("validateModeConfig() error = %v, wantErr %t", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWebhookHandlerValidatesSecretToken(t *testing.T) {
+	api, err := bot.New("123:token", bot.WithSkipGetMe())
+	if err != nil {
+		t.Fatalf("new bot API: %v", err)
+	}
+
+	b := &Bot{
+		api: api,
+		cfg: config.BotConfig{WebhookSecretToken: "secret"},
+		log: slog.Default(),
+	}
+	handler := b.webhookHandler()
+
+	tests := []struct {
+		name       string
+		method     string
+		token      string
+		wantStatus int
+	}{
+		{name: "valid token", method: http.MethodPost, token: "secret", wantStatus: http.StatusOK},
+		{name: "invalid token", method: http.MethodPost, token: "wrong", wantStatus: http.StatusUnauthorized},
+		{name: "missing token", method: http.MethodPost, wantStatus: http.StatusUnauthorized},
+		{name: "wrong method", method: http.MethodGet, token: "secret", wantStatus: http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequestWithContext(
+				t.Context(),
+				tt.method,
+				"/webhook",
+				strings.NewReader(`{"update_id":1}`),
+			)
+			if tt.token != "" {
+				req.Header.Set(webhookSecretTokenHeader, tt.token)
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestStartPollingDeletesWebhookFirst(t *testing.T) {
+	var mu sync.Mutex
+	var methods []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method := path.Base(r.URL.Path)
+
+		mu.Lock()
+		methods = append(methods, method)
+		mu.Unlock()
+
+		if method == "getUpdates" {
+			_, _ = w.Write([]byte(`{"ok":true,"result":[]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"ok":true,"result":true}`))
+	}))
+	defer server.Close()
+
+	api, err := bot.New("123:token", bot.WithServerURL(server.URL), bot.WithSkipGetMe())
+	if err != nil {
+		t.Fatalf("new bot API: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	b := &Bot{api: api, cfg: config.BotConfig{Mode: ModePolling}, log: slog.Default()}
+	if err = b.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(methods) == 0 || methods[0] != "deleteWebhook" {
+		t.Fatalf("expected deleteWebhook before polling, got %v", methods)
+	}
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int64
+		want     time.Duration
+	}{
+		{attempts: 1, want: 30 * time.Second},
+		{attempts: 2, want: time.Minute},
+		{attempts: 4, want: 4 * time.Minute},
+		{attempts: 8, want: 10 * time.Minute},
+		{attempts: 100, want: 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		if got := outboxBackoff(tt.attempts, 30*time.Second, 10*time.Minute); got != tt.want {
+			t.Fatalf("outboxBackoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+// fakeOutboxAPI answers sendMessage by chat: chats in forbidden get 403, chats
+// in failing get 500, and the rest succeed.
+type fakeOutboxAPI struct {
+	mu        sync.Mutex
+	forbidden map[string]bool
+	failing   map[string]bool
+	texts     []string
+}
+
+func (f *fakeOutboxAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	chatID := r.FormValue("chat_id")
+	f.texts = append(f.texts, r.FormValue("text"))
+
+	switch {
+	case f.forbidden[chatID]:
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"ok":false,"error_code":403,"description":"Forbidden: bot was blocked by the user"}`))
+	case f.failing[chatID]:
+		w.WriteHeader(http.StatusInternalServerError)
+		_, _ = w.Write([]byte(`{"ok":false,"error_code":500,"description":"Internal Server Error"}`))
+	default:
+		_, _ = w.Write([]byte(`{"ok":true,"result":{"message_id":1,"date":0,"chat":{"id":1,"type":"private"}}}`))
+	}
+}
+
+func (f *fakeOutboxAPI) sentTexts() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.texts)
+}
+
+func TestDrainOutbox(t *testing.T) {
+	fake := &fakeOutboxAPI{
+		forbidden: map[string]bool{"2": true},
+		failing:   map[string]bool{"3": true},
+	}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	api, err := bot.New("123:token", bot.WithSkipGetMe(), bot.WithServerURL(srv.URL))
+	if err != nil {
+		t.Fatalf("new bot API: %v", err)
+	}
+
+	db, err := database.New(t.Context(), filepath.Join(t.TempDir(), "test.db"), slog.Default())
+	if err != nil {
+		t.Fatalf("new database: %v", err)
+	}
+
+	rateLimiter := ratelimiter.New(api, config.RateLimiterConfig{QueueSize: 10}, nil, slog.Default())
+	t.Cleanup(rateLimiter.Stop)
+
+	b := &Bot{
+		api:         api,
+		rateLimiter: rateLimiter,
+		db:          db,
+		cfg: config.BotConfig{
+			OutboxMaxAttempts: 3,
+			OutboxBaseBackoff: time.Minute,
+			OutboxMaxBackoff:  time.Hour,
+			OutboxBatchSize:   2,
+		},
+		log: slog.Default(),
+	}
+
+	now := time.Now()
+	for _, m := range []struct {
+		chatID int64
+		text   string
+	}{{1, "a1"}, {2, "b1"}, {3, "c1"}, {1, "a2"}, {2, "b2"}, {3, "c2"}} {
+		if err = db.AddOutboxMessages(t.Context(), m.chatID, []string{m.text}, now); err != nil {
+			t.Fatalf("add outbox message: %v", err)
+		}
+	}
+
+	sent, err := b.DrainOutbox(t.Context())
+	if err != nil {
+		t.Fatalf("DrainOutbox() error = %v", err)
+	}
+	if sent != 2 {
+		t.Fatalf("expected 2 sent messages, got %d", sent)
+	}
+
+	// c2 isn't sent while c1 waits for a retry, and b2 is dropped with b1.
+	if got, want := fake.sentTexts(), []string{"a1", "b1", "c1", "a2"}; !slices.Equal(got, want) {
+		t.Fatalf("expected sent texts %v, got %v", want, got)
+	}
+
+	due, err := db.GetDueOutboxMessages(t.Context(), time.Now(), 10)
+	if err != nil {
+		t.Fatalf("get due outbox messages: %v", err)
+	}
+	if len(due) != 0 {
+		t.Fatalf("expected no due messages, got %+v", due)
+	}
+
+	due, err = db.GetDueOutboxMessages(t.Context(), time.Now().Add(2*time.Minute), 10)
+	if err != nil {
+		t.Fatalf("get due outbox messages: %v", err)
+	}
+	if len(due) != 2 || due[0].Text != "c1" || due[0].Attempts != 1 || due[1].Text != "c2" {
+		t.Fatalf("expected c1 and c2 to be retried in order, got %+v", due)
+	}
+}
+
+func TestCanonicalPostURL(t *testing.T) {
+	tests := map[string]string{
+		"https://www.Example.com/posts/1/?utm_source=tg&id=2#comments": "example.com/posts/1?id=2",
+		"http://example.com/posts/1":                                   "example.com/posts/1",
+		"https://t.me/s/channel/42?embed=1":                            "t.me/channel/42?embed=1",
+		"https://t.me/channel/42":                                      "t.me/channel/42",
+		"https://t.me/sport/42":                                        "t.me/sport/42",
+		"https://t.me/s/channel?q=%23news":                             "",
+		"https://t.me/channel":                                         "",
+		"https://example.com/":                                         "",
+		"mailto:news@example.com":                                      "",
+		"":                                                             "",
+	}
+
+	for raw, want := range tests {
+		if got := canonicalPostURL(raw); got != want {
+			t.Errorf("canonicalPostURL(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestClusterPosts(t *testing.T) {
+	base := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	announcement := "Example 2.0 is released today with a new plugin system, faster builds, and a redesigned CLI"
+
+	posts := []domain.Post{
+		{
+			FeedID:    1,
+			FeedTitle: "Blog",
+			Title:     "Example 2.0 released",
+			URL:       "https://www.example.com/blog/2-0/?utm_source=rss",
+			Published: base.Add(time.Hour),
+		},
+		{
+			FeedID:    2,
+			FeedTitle: "Channel A",
+			Text:      "Big news: " + announcement + "!",
+			URL:       "https://t.me/channel_a/10",
+			Published: base.Add(2 * time.Hour),
+			Telegram:  domain.TelegramPostMeta{Links: []string{"https://example.com/blog/2-0"}},
+		},
+		{
+			FeedID:    3,
+			FeedTitle: "Channel B",
+			Text:      announcement,
+			URL:       "https://t.me/channel_b/20",
+			Published: base,
+		},
+		{
+			FeedID:    3,
+			FeedTitle: "Channel B",
+			Text:      "Unrelated post about the weekly community call and meetup schedule for next month",
+			URL:       "https://t.me/channel_b/21",
+			Published: base,
+		},
+		{
+			FeedID:    4,
+			FeedTitle: "Channel C",
+			Text:      "Read the blog",
+			URL:       "https://t.me/channel_c/30",
+			Published: base,
+			Telegram:  domain.TelegramPostMeta{Links: []string{"https://example.com/", "https://t.me/channel_c"}},
+		},
+	}
+
+	clusters := clusterPosts(posts)
+
+	if len(clusters) != 3 {
+		t.Fatalf("clusterPosts() returned %d clusters, want 3: %+v", len(clusters), clusters)
+	}
+
+	story := clusters[0]
+	if story.post.URL != "https://t.me/channel_b/20" {
+		t.Fatalf("story cluster shows %q, want the earliest post", story.post.URL)
+	}
+	storyAlsoIn := []string{"https://www.example.com/blog/2-0/?utm_source=rss", "https://t.me/channel_a/10"}
+	assertPostURLs(t, story.alsoIn, storyAlsoIn...)
+
+	if clusters[1].post.URL != "https://t.me/channel_b/21" || len(clusters[1].alsoIn) != 0 {
+		t.Fatalf("unrelated post should stay alone, got %+v", clusters[1])
+	}
+	if clusters[2].post.URL != "https://t.me/channel_c/30" || len(clusters[2].alsoIn) != 0 {
+		t.Fatalf("home page and channel links shouldn't cluster posts, got %+v", clusters[2])
+	}
+
+	reversed := slices.Clone(posts)
+	slices.Reverse(reversed)
+
+	for _, cluster := range clusterPosts(reversed) {
+		if cluster.post.URL == story.post.URL {
+			assertPostURLs(t, cluster.alsoIn, storyAlsoIn...)
+			return
+		}
+	}
+
+	t.Fatalf("clusterPosts() of reversed posts should show the same post for the story")
+}
+
+func TestFormatPostsAsMessagesCollapsesDuplicates(t *testing.T) {
+	b := &Bot{log: slog.Default()}
+
+	posts := []domain.Post{
+		{
+			FeedID:    1,
+			FeedTitle: "Blog",
+			FeedURL:   "https://example.com/feed",
+			Title:     "Launch",
+			URL:       "https://example.com/launch",
+		},
+		{
+			FeedID:    2,
+			FeedTitle: "Channel",
+			FeedURL:   "https://t.me/s/channel",
+			Title:     "Launch recap",
+			URL:       "https://t.me/channel/1",
+			Telegram:  domain.TelegramPostMeta{Links: []string{"https://example.com/launch?utm_source=tg"}},
+		},
+	}
+
+	messages := b.formatPostsAsMessages(t.Context(), posts, nil)
+	if len(messages) != 1 {
+		t.Fatalf("expected one digest message, got %d", len(messages))
+	}
+
+	if strings.Contains(messages[0], "Launch recap") {
+		t.Fatalf("duplicate post should be collapsed, got %q", messages[0])
+	}
+
+	want := "🔁 also in: [Channel](https://t.me/channel/1)\n\n"
+	if !strings.Contains(messages[0], want) {
+		t.Fatalf("expected attribution %q, got %q", want, messages[0])
+	}
+}
+
+func TestClusterPostsKeepsOnePostPerFeed(t *testing.T) {
+	base := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	posts := []domain.Post{
+		{
+			FeedID:    1,
+			URL:       "https://t.me/channel_a/1",
+			Published: base,
+			Telegram:  domain.TelegramPostMeta{Links: []string{"https://example.com/launch"}},
+		},
+		{
+			FeedID:    2,
+			URL:       "https://example.com/launch",
+			Published: base.Add(time.Hour),
+			Telegram:  domain.TelegramPostMeta{Links: []string{"https://example.com/changelog"}},
+		},
+		{
+			FeedID:    1,
+			URL:       "https://t.me/channel_a/2",
+			Published: base.Add(2 * time.Hour),
+			Telegram:  domain.TelegramPostMeta{Links: []string{"https://example.com/changelog"}},
+		},
+	}
+
+	clusters := clusterPosts(posts)
+	if len(clusters) != 2 {
+		t.Fatalf("clusterPosts() returned %d clusters, want 2: %+v", len(clusters), clusters)
+	}
+
+	if clusters[0].post.URL != "https://t.me/channel_a/1" {
+		t.Fatalf("first cluster shows %q, want the earliest post", clusters[0].post.URL)
+	}
+	assertPostURLs(t, clusters[0].alsoIn, "https://example.com/launch")
+
+	if clusters[1].post.URL != "https://t.me/channel_a/2" || len(clusters[1].alsoIn) != 0 {
+		t.Fatalf("second post of the feed should be shown alone, got %+v", clusters[1])
+	}
+}
+
+func TestFormatPostsAsMessagesLinksHiddenPostsOfCollapsedFeed(t *testing.T) {
+	b := &Bot{log: slog.Default()}
+
+	posts := []domain.Post{
+		{
+			FeedID:    1,
+			FeedTitle: "Blog",
+			FeedURL:   "https://example.com/feed",
+			Title:     "Launch",
+			URL:       "https://example.com/launch",
+		},
+		{
+			FeedID:    2,
+			FeedTitle: "Channel",
+			FeedURL:   "https://t.me/s/channel",
+			Title:     "Launch recap",
+			URL:       "https://t.me/channel/1",
+			Telegram:  domain.TelegramPostMeta{Links: []string{"https://example.com/launch"}},
+		},
+	}
+	hidden := []domain.Post{{
+		FeedID:    2,
+		FeedTitle: "Channel",
+		FeedURL:   "https://t.me/s/channel",
+		Title:     "Meetup",
+		URL:       "https://t.me/channel/2",
+	}}
+
+	messages := b.formatPostsAsMessages(t.Context(), posts, hidden)
+	if len(messages) != 1 {
+		t.Fatalf("expected one digest message, got %d", len(messages))
+	}
+
+	want := "📌 *[Channel](https://t.me/s/channel)*\n\n– […and 1 more](https://t.me/s/channel)\n\n"
+	if !strings.Contains(messages[0], want) {
+		t.Fatalf("expected hidden posts link %q of the collapsed feed, got %q", want, messages[0])
+	}
+}
+
+func assertPostURLs(t *testing.T, posts []domain.Post, want ...string) {
+	t.Helper()
+
+	got := make([]string, 0, len(posts))
+	for _, post := range posts {
+		got = append(got, post.URL)
+	}
+
+	if !slices.Equal(got, want) {
+		t.Fatalf("post URLs = %v, want %v", got, want)
+	}
+}
+
+func TestPendingFeedRenameRequiresReplyToPrompt(t *testing.T) {
+	b := &Bot{feedRenames: newPendingStore[feedRename](time.Hour)}
+	b.feedRenames.set(feedRenameToken(7), 7, feedRename{feedID: 3, promptMessageID: 42})
+
+	reply := func(replyToID int) *models.Message {
+		return &models.Message{
+			From:           &models.User{ID: 7},
+			Text:           "My feed",
+			ReplyToMessage: &models.Message{ID: replyToID},
+		}
+	}
+
+	if _, ok := b.pendingFeedRename(reply(41)); ok {
+		t.Fatal("a reply to another message shouldn't rename the feed")
+	}
+
+	if feedID, ok := b.pendingFeedRename(reply(42)); !ok || feedID != 3 {
+		t.Fatalf("pendingFeedRename() = %d, %v, want 3, true", feedID, ok)
+	}
+
+	b.cancelFeedRename(7)
+
+	if _, ok := b.pendingFeedRename(reply(42)); ok {
+		t.Fatal("a canceled rename shouldn't rename the feed")
+	}
+}
+
+func TestFeedCustomTitleAndPosition(t *testing.T) {
+	ctx := t.Context()
+
+	db, err := database.New(ctx, filepath.Join(t.TempDir(), "test.db"), slog.Default())
+	if err != nil {
+		t.Fatalf("new database: %v", err)
+	}
+
+	for _, u := range []string{"https://example.com/a", "https://example.com/b", "https://example.com/c"} {
+		if err = db.AddFeed(ctx, 1, u, u); err != nil {
+			t.Fatalf("add feed: %v", err)
+		}
+	}
+
+	feeds, err := db.GetUserFeeds(ctx, 1)
+	if err != nil {
+		t.Fatalf("get user feeds: %v", err)
+	}
+
+	last := feeds[2]
+
+	if err = db.UpdateFeedCustomTitle(ctx, 1, last.ID, "  Short  "); err != nil {
+		t.Fatalf("update feed custom title: %v", err)
+	}
+	if err = db.UpdateSourceTitle(ctx, last.SourceID, "Remote title"); err != nil {
+		t.Fatalf("update source title: %v", err)
+	}
+
+	moved, err := db.MoveFeed(ctx, 1, last.ID, -1)
+	if err != nil || !moved {
+		t.Fatalf("MoveFeed() = %v, %v, want moved", moved, err)
+	}
+
+	moved, err = db.MoveFeed(ctx, 1, feeds[0].ID, -1)
+	if err != nil || moved {
+		t.Fatalf("MoveFeed() of the first feed = %v, %v, want not moved", moved, err)
+	}
+
+	if _, err = db.MoveFeed(ctx, 2, last.ID, 1); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("MoveFeed() of another user's feed error = %v, want sql.ErrNoRows", err)
+	}
+
+	feeds, err = db.GetUserFeeds(ctx, 1)
+	if err != nil {
+		t.Fatalf("get user feeds: %v", err)
+	}
+
+	var got []string
+	for _, f := range feeds {
+		got = append(got, f.DisplayTitle())
+	}
+
+	want := []string{"https://example.com/a", "Short", "https://example.com/b"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("feed titles = %v, want %v", got, want)
+	}
+
+	if feeds[1].Title != "Remote title" {
+		t.Fatalf("source title = %q, want it updated under the custom title", feeds[1].Title)
+	}
+}
@@ -4,9 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"strconv"
 	"strings"
-	"telekilogram/internal/domain"
 
 	"github.com/go-telegram/bot"
 	"github.com/go-telegram/bot/models"
@@ -31,65 +29,98 @@ func (b *Bot) handleCallbackQuery(ctx context.Context, callback *models.Callback
 				return b.handleListCommand(ctx, message.Chat.ID, callback.From.ID)
 			})
 		case "menu_digest":
-			return b.withEmptyCallbackAnswer(ctx, callback, "get 24-hour digest", func() error {
+			return b.withEmptyCallbackAnswer(ctx, callback, "get digest since your last one", func() error {
 				return b.handleDigestCommand(ctx, message.Chat.ID, callback.From.ID)
 			})
+		case "menu_filter":
+			return b.withEmptyCallbackAnswer(ctx, callback, "open filters", func() error {
+				return b.sendFilterList(ctx, message.Chat.ID, callback.From.ID)
+			})
+		case "settings_slot_new":
+			return b.withEmptyCallbackAnswer(ctx, callback, "open digest slot days", func() error {
+				return b.sendMessageWithKeyboard(
+					ctx,
+					message.Chat.ID,
+					"🗓 On which days should the new digest slot run?",
+					getSettingsDigestSlotDaysKeyboard(),
+				)
+			})
+		case "settings_timezone":
+			return b.withEmptyCallbackAnswer(ctx, callback, "open time zone settings", func() error {
+				return b.sendTimezonePrompt(ctx, message.Chat.ID)
+			})
 		case "menu_settings":
 			return b.withEmptyCallbackAnswer(ctx, callback, "open settings", func() error {
 				return b.handleSettingsCommand(ctx, message.Chat.ID, callback.From.ID)
 			})
 		}
 
-		if hourUTCStr, ok := strings.CutPrefix(data, "settings_auto_digest_hour_utc_"); ok {
-			return b.handleSettingsAutoDigestHourUTCQuery(ctx, hourUTCStr, callback)
+		if name, ok := strings.CutPrefix(data, settingsTimezoneCallbackPrefix); ok {
+			return b.handleTimezoneQuery(ctx, name, callback)
 		}
 
-		return nil
-	})
-}
+		if weekdaysStr, ok := strings.CutPrefix(data, settingsDigestSlotDaysCallbackPrefix); ok {
+			return b.handleDigestSlotDaysQuery(ctx, weekdaysStr, callback)
+		}
 
-func (b *Bot) handleSettingsAutoDigestHourUTCQuery(
-	ctx context.Context,
-	hourUTCStr string,
-	callback *models.CallbackQuery,
-) error {
-	message := callbackMessage(callback)
-	if message == nil {
-		return errors.New("callback query has no accessible message")
-	}
+		if slotStr, ok := strings.CutPrefix(data, settingsDigestSlotAddCallbackPrefix); ok {
+			return b.handleDigestSlotAddQuery(ctx, slotStr, callback)
+		}
 
-	hourUTCStr = strings.TrimSpace(hourUTCStr)
+		if scheduleIDStr, ok := strings.CutPrefix(data, settingsDigestSlotRemoveCallbackPrefix); ok {
+			return b.handleDigestSlotRemoveQuery(ctx, scheduleIDStr, callback)
+		}
 
-	hourUTC, err := strconv.ParseInt(hourUTCStr, 10, 64)
-	if err != nil {
-		return b.answerCallbackError(
-			ctx,
-			callback,
-			"❌ Couldn't parse provided value. Please try again.",
-			fmt.Errorf("parse hourUTC: %w", err),
-		)
-	}
+		if feedIDStr, ok := strings.CutPrefix(data, feedMenuCallbackPrefix); ok {
+			return b.handleFeedMenuQuery(ctx, feedIDStr, callback)
+		}
 
-	if err = b.db.UpsertUserSettings(ctx, &domain.UserSettings{
-		UserID:            callback.From.ID,
-		AutoDigestHourUTC: hourUTC,
-	}); err != nil {
-		return b.answerCallbackError(
-			ctx,
-			callback,
-			"❌ Couldn't update settings. Please try again.",
-			fmt.Errorf("update user settings: %w", err),
-		)
-	}
+		if modeData, ok := strings.CutPrefix(data, feedModeCallbackPrefix); ok {
+			return b.handleFeedModeQuery(ctx, modeData, callback)
+		}
 
-	if _, err = b.rateLimiter.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
-		CallbackQueryID: callback.ID,
-		Text:            "✅ Settings are updated.",
-	}); err != nil {
-		return fmt.Errorf("answer callback query: %w", err)
-	}
+		if summaryData, ok := strings.CutPrefix(data, feedSummaryCallbackPrefix); ok {
+			return b.handleFeedSummaryQuery(ctx, summaryData, callback)
+		}
+
+		if topData, ok := strings.CutPrefix(data, feedTopCallbackPrefix); ok {
+			return b.handleFeedTopQuery(ctx, topData, callback)
+		}
+
+		if feedIDStr, ok := strings.CutPrefix(data, feedRenameCallbackPrefix); ok {
+			return b.handleFeedRenameQuery(ctx, feedIDStr, callback)
+		}
+
+		if moveData, ok := strings.CutPrefix(data, feedMoveCallbackPrefix); ok {
+			return b.handleFeedMoveQuery(ctx, moveData, callback)
+		}
 
-	return b.handleSettingsCommand(ctx, message.Chat.ID, callback.From.ID)
+		if feedIDStr, ok := strings.CutPrefix(data, feedHealthRetryCallbackPrefix); ok {
+			return b.handleFeedHealthRetryQuery(ctx, feedIDStr, callback)
+		}
+
+		if feedIDStr, ok := strings.CutPrefix(data, feedHealthUnfollowCallbackPrefix); ok {
+			return b.handleFeedHealthUnfollowQuery(ctx, feedIDStr, callback)
+		}
+
+		if feedIDStr, ok := strings.CutPrefix(data, feedHealthKeepCallbackPrefix); ok {
+			return b.handleFeedHealthKeepQuery(ctx, feedIDStr, callback)
+		}
+
+		if scopeData, ok := strings.CutPrefix(data, filterScopeCallbackPrefix); ok {
+			return b.handleFilterScopeQuery(ctx, scopeData, callback)
+		}
+
+		if ruleIDStr, ok := strings.CutPrefix(data, filterDeleteCallbackPrefix); ok {
+			return b.handleFilterDeleteQuery(ctx, ruleIDStr, callback)
+		}
+
+		if choiceData, ok := strings.CutPrefix(data, feedChoiceCallbackPrefix); ok {
+			return b.handleFeedChoiceQuery(ctx, choiceData, callback)
+		}
+
+		return nil
+	})
 }
 
 func (b *Bot) withEmptyCallbackAnswer(
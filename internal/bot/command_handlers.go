@@ -4,12 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
-)
 
-const maxHourForAddingLeadingZero = 9
+	"github.com/go-telegram/bot"
+)
 
 const welcomeTextBase = `🤖 *Welcome to Telekilogram\!*
 
@@ -18,18 +19,21 @@ I'm your feed assistant\. I can help you:
 – Follow RSS, Atom, and JSON feeds, as well as public Telegram channels, by sending feed URLs, channel usernames, or forwarded messages from channels
 – View your current feed list with /list
 – Unfollow feeds directly from the list
-– Receive an automatic 24\-hour digest every day \(default\: 00\:00 UTC\)
-– Request a 24\-hour digest manually with /digest
+– Filter posts by keywords or regular expressions with /filter
+– Export subscriptions as OPML with /export, and import them by sending an \.opml file
+– Receive automatic digests at daily or weekly slots you choose in /settings \(default\: daily at 00\:00\), with your time zone set by /timezone
+– Request a digest of posts since your last digest manually with /digest
 – Get concise summaries for Telegram channel posts \(AI\-generated when configured\)
 – Configure user\-specific settings with /settings`
 
 const settingsText = `*⚙️ Settings*
 
-Current UTC time is %s\.
+Your time zone is %s, and your local time is %s\.
 
-Current auto\-digest hour \(UTC\) setting is %s\.
+*Digest slots:*
+%s
 
-You can choose different setting below:`
+Add or remove a slot below, or change the time zone with /timezone\.`
 
 func (b *Bot) handleStartCommand(
 	ctx context.Context,
@@ -138,26 +142,31 @@ Send a feed URL, a t\.me link, a @channel username, or forward a message from a
 			continue
 		}
 
-		title := strings.TrimSpace(f.Title)
-		if title == "" {
-			title = url
+		title := f.DisplayTitle()
+
+		healthIcon := deliveryModeIcon(f.DeliveryMode)
+		if b.feedUnhealthy(f) {
+			healthIcon += unhealthyFeedIcon + " "
 		}
 
 		if botInfoErr == nil {
 			unfollowURL := fmt.Sprintf("https://t.me/%s?start=unfollow_%d", botInfo.Username, f.ID)
 			fmt.Fprintf(
 				&message,
-				"%d\\. %s \\[%s\\]\n",
+				"%d\\. %s%s \\[%s\\]\n",
 				i+1,
+				healthIcon,
 				formatMarkdownLink(title, url),
 				formatMarkdownLink("unfollow", unfollowURL),
 			)
 		} else {
-			fmt.Fprintf(&message, "%d\\. %s\n", i+1, formatMarkdownLink(title, url))
+			fmt.Fprintf(&message, "%d\\. %s%s\n", i+1, healthIcon, formatMarkdownLink(title, url))
 		}
 	}
 
-	if err = b.sendMessageWithKeyboard(ctx, chatID, message.String(), b.returnKeyboard); err != nil {
+	message.WriteString("\nTap ⚙️ with a feed number to change its delivery, title, or place in the list\\.")
+
+	if err = b.sendMessageWithKeyboard(ctx, chatID, message.String(), getFeedListKeyboard(feeds)); err != nil {
 		errs = append(errs, fmt.Errorf("send message with keyboard: %w", err))
 	}
 
@@ -177,7 +186,7 @@ func (b *Bot) handleDigestCommand(ctx context.Context, chatID int64, userID int6
 			errs = append(errs, fmt.Errorf("fetch user feeds: %w", err))
 		}
 
-		messageText := `📭 No recent posts were found in the last 24 hours\.
+		messageText := `📭 No new posts were found since your last digest\.
 
 If you haven't added feeds yet, send a feed URL, a t\.me link, a @channel username, or forward a message from a public channel\.`
 
@@ -198,9 +207,28 @@ If you haven't added feeds yet, send a feed URL, a t\.me link, a @channel userna
 		errs = append(errs, fmt.Errorf("fetch user feeds: %w", err))
 	}
 
-	for _, posts := range userPosts {
-		if err = b.SendNewPosts(ctx, chatID, posts); err != nil {
+	for _, up := range userPosts {
+		if len(up.Posts) == 0 {
+			err = b.sendMessageWithKeyboard(
+				ctx,
+				chatID,
+				"📭 No new posts were found since your last digest\\.\n\n"+filteredPostsText(len(up.Filtered)),
+				b.returnKeyboard,
+			)
+		} else {
+			err = b.SendNewPosts(ctx, chatID, up)
+		}
+		if err != nil {
 			errs = append(errs, fmt.Errorf("send new posts: %w", err))
+			continue
+		}
+
+		if err = b.fetcher.MarkPostsDelivered(ctx, userID, slices.Concat(up.Posts, up.Hidden, up.Filtered)); err != nil {
+			errs = append(errs, fmt.Errorf("mark posts delivered: %w", err))
+		}
+
+		if err = b.fetcher.RecordDigestDelivery(ctx, userID, time.Now()); err != nil {
+			errs = append(errs, fmt.Errorf("record digest delivery: %w", err))
 		}
 	}
 
@@ -225,19 +253,24 @@ func (b *Bot) handleSettingsCommand(ctx context.Context, chatID int64, userID in
 		return errors.Join(errs...)
 	}
 
-	currentUTC := time.Now().UTC().Format("15:04")
-
-	hourUTC := settings.AutoDigestHourUTC
-	hourUTCStr := fmt.Sprintf("%d:00", hourUTC)
-	if hourUTC <= maxHourForAddingLeadingZero {
-		hourUTCStr = fmt.Sprintf("0%s", hourUTCStr)
+	schedules, err := b.db.GetUserDigestSchedules(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get user digest schedules: %w", err)
 	}
 
+	loc := userLocation(settings)
+	currentTime := time.Now().In(loc).Format("15:04")
+
 	if err = b.sendMessageWithKeyboard(
 		ctx,
 		chatID,
-		fmt.Sprintf(settingsText, currentUTC, hourUTCStr),
-		b.settingsAutoDigestHourUTCKeyboard,
+		fmt.Sprintf(
+			settingsText,
+			bot.EscapeMarkdownUnescaped(loc.String()),
+			currentTime,
+			digestSchedulesText(schedules),
+		),
+		getSettingsKeyboard(schedules),
 	); err != nil {
 		return fmt.Errorf("send message with keyboard: %w", err)
 	}
@@ -0,0 +1,232 @@
+package bot
+
+import (
+	"cmp"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+	"telekilogram/internal/domain"
+	"unicode"
+)
+
+const (
+	telegramHost = "t.me"
+
+	// shingleSize is the number of words in a text shingle.
+	shingleSize = 3
+	// minShingles keeps short texts, which share shingles by chance, out of
+	// near-duplicate matching.
+	minShingles = 5
+	// nearDuplicateSimilarity is the Jaccard similarity of shingle sets above
+	// which two posts tell the same story.
+	nearDuplicateSimilarity = 0.5
+	// minTelegramPostPathSegments skips links to channels and hashtags, which
+	// many unrelated posts share, and keeps links to posts.
+	minTelegramPostPathSegments = 2
+)
+
+// postCluster is a post shown once in a digest for copies of the same story
+// in other feeds.
+type postCluster struct {
+	post   domain.Post
+	alsoIn []domain.Post
+}
+
+// clusterPosts collapses posts of different feeds telling the same story.
+// Posts are exact duplicates when they share a canonical URL, counting links
+// in Telegram posts, and near duplicates when their texts share most word
+// shingles. A cluster has at most one post per feed, so two posts of a feed
+// matching the same story elsewhere are both shown. The earliest post of a
+// cluster is shown, and clusters keep the order of their shown posts.
+func clusterPosts(posts []domain.Post) []postCluster {
+	keys := make([]map[string]struct{}, len(posts))
+	shingles := make([]map[string]struct{}, len(posts))
+	feeds := make([]map[int64]struct{}, len(posts))
+	parents := make([]int, len(posts))
+
+	for i, post := range posts {
+		keys[i] = postURLKeys(post)
+		shingles[i] = textShingles(postText(post))
+		feeds[i] = map[int64]struct{}{post.FeedID: {}}
+		parents[i] = i
+	}
+
+	for i := range posts {
+		for j := i + 1; j < len(posts); j++ {
+			if !sharesKey(keys[i], keys[j]) && shingleSimilarity(shingles[i], shingles[j]) < nearDuplicateSimilarity {
+				continue
+			}
+
+			rootI, rootJ := findCluster(parents, i), findCluster(parents, j)
+			if rootI == rootJ || sharesKey(feeds[rootI], feeds[rootJ]) {
+				continue
+			}
+
+			// The smaller root wins, so clusters don't depend on the union order.
+			root, other := min(rootI, rootJ), max(rootI, rootJ)
+			parents[other] = root
+			maps.Copy(feeds[root], feeds[other])
+		}
+	}
+
+	members := make(map[int][]int)
+	for i := range posts {
+		root := findCluster(parents, i)
+		members[root] = append(members[root], i)
+	}
+
+	shown := make([]int, 0, len(members))
+	clusters := make(map[int]postCluster, len(members))
+
+	for _, indexes := range members {
+		slices.SortFunc(indexes, func(a, b int) int { return comparePostAge(posts[a], posts[b]) })
+		first := indexes[0]
+
+		cluster := postCluster{post: posts[first]}
+		for _, i := range indexes[1:] {
+			cluster.alsoIn = append(cluster.alsoIn, posts[i])
+		}
+
+		shown = append(shown, first)
+		clusters[first] = cluster
+	}
+
+	slices.Sort(shown)
+
+	result := make([]postCluster, 0, len(shown))
+	for _, i := range shown {
+		result = append(result, clusters[i])
+	}
+
+	return result
+}
+
+// comparePostAge orders posts by publication, then feed and URL, so the shown
+// post of a cluster doesn't depend on the order feeds were fetched in.
+func comparePostAge(a, b domain.Post) int {
+	return cmp.Or(
+		a.Published.Compare(b.Published),
+		cmp.Compare(a.FeedID, b.FeedID),
+		cmp.Compare(a.URL, b.URL),
+	)
+}
+
+func findCluster(parents []int, i int) int {
+	for parents[i] != i {
+		parents[i] = parents[parents[i]]
+		i = parents[i]
+	}
+
+	return i
+}
+
+func sharesKey[K comparable](a, b map[K]struct{}) bool {
+	for key := range a {
+		if _, ok := b[key]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// postURLKeys returns canonical URLs of the post and of links in its text.
+func postURLKeys(post domain.Post) map[string]struct{} {
+	keys := make(map[string]struct{})
+
+	for _, raw := range slices.Concat([]string{post.URL}, post.Telegram.Links) {
+		if key := canonicalPostURL(raw); key != "" {
+			keys[key] = struct{}{}
+		}
+	}
+
+	return keys
+}
+
+// canonicalPostURL returns the URL without scheme, "www.", tracking parameters,
+// fragment, and trailing slash, with Telegram preview URLs mapped to post URLs.
+// Links to home pages, channels, and hashtags return an empty string, as they
+// don't identify a story.
+func canonicalPostURL(raw string) string {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ""
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	path := strings.TrimSuffix(u.EscapedPath(), "/")
+
+	if host == telegramHost {
+		if rest, ok := strings.CutPrefix(path, "/s/"); ok {
+			path = "/" + rest
+		}
+
+		if len(strings.Split(strings.Trim(path, "/"), "/")) < minTelegramPostPathSegments {
+			return ""
+		}
+	}
+
+	if path == "" {
+		return ""
+	}
+
+	query := u.Query()
+	for name := range query {
+		if strings.HasPrefix(strings.ToLower(name), "utm_") {
+			query.Del(name)
+		}
+	}
+
+	key := host + path
+	if encoded := query.Encode(); encoded != "" {
+		key += "?" + encoded
+	}
+
+	return key
+}
+
+// postText returns the text to compare posts by: the Telegram post text, or
+// the title and summary of a feed item.
+func postText(post domain.Post) string {
+	if post.Text != "" {
+		return post.Text
+	}
+
+	return post.Title + " " + post.Summary
+}
+
+// textShingles returns the set of shingleSize-word sequences of the lowercased
+// text, or nil when the text is too short to compare.
+func textShingles(text string) map[string]struct{} {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	if len(words)-shingleSize+1 < minShingles {
+		return nil
+	}
+
+	shingles := make(map[string]struct{}, len(words)-shingleSize+1)
+	for i := 0; i+shingleSize <= len(words); i++ {
+		shingles[strings.Join(words[i:i+shingleSize], " ")] = struct{}{}
+	}
+
+	return shingles
+}
+
+// shingleSimilarity returns the Jaccard similarity of two shingle sets.
+func shingleSimilarity(a, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	common := 0
+	for shingle := range a {
+		if _, ok := b[shingle]; ok {
+			common++
+		}
+	}
+
+	return float64(common) / float64(len(a)+len(b)-common)
+}
@@ -0,0 +1,75 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"telekilogram/internal/domain"
+	"telekilogram/internal/summarizer"
+	"unicode/utf8"
+
+	"github.com/go-telegram/bot"
+)
+
+// digestOverview asks the overview summarizer for a topic-grouped overview of
+// all posts in a digest, returning an empty string when it's disabled, the
+// digest is too short, or the request fails.
+func (b *Bot) digestOverview(ctx context.Context, posts []domain.Post) string {
+	if b.overviewSummarizer == nil || len(posts) == 0 || len(posts) < b.overviewCfg.MinPosts {
+		return ""
+	}
+
+	overview, err := b.overviewSummarizer.Summarize(ctx, summarizer.Input{
+		Text: digestOverviewInput(posts, b.overviewCfg.MaxInputChars),
+	})
+	if err != nil {
+		b.log.WarnContext(ctx, "Failed to get digest overview",
+			"error", err,
+			"postsCount", len(posts))
+
+		return ""
+	}
+
+	return truncateRunes(strings.TrimSpace(overview), b.overviewCfg.MaxChars)
+}
+
+// digestOverviewInput lists post titles and summaries, one post per line,
+// stopping before maxChars is exceeded.
+func digestOverviewInput(posts []domain.Post, maxChars int) string {
+	var sb strings.Builder
+	length := 0
+
+	for _, post := range posts {
+		line := fmt.Sprintf("- %s: %s", post.FeedTitle, strings.Join(strings.Fields(post.Title), " "))
+		if summary := strings.Join(strings.Fields(post.Summary), " "); summary != "" {
+			line += " — " + summary
+		}
+		line += "\n"
+
+		lineLen := utf8.RuneCountInString(line)
+		if maxChars > 0 && length+lineLen > maxChars {
+			break
+		}
+
+		sb.WriteString(line)
+		length += lineLen
+	}
+
+	return sb.String()
+}
+
+func digestOverviewBlock(overview string) string {
+	if overview == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("🧭 *Overview*\n\n%s\n\n", bot.EscapeMarkdownUnescaped(overview))
+}
+
+func truncateRunes(text string, maxChars int) string {
+	if maxChars <= 0 || utf8.RuneCountInString(text) <= maxChars {
+		return text
+	}
+
+	return strings.TrimSpace(string([]rune(text)[:maxChars])) + "…"
+}
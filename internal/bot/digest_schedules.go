@@ -0,0 +1,227 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"telekilogram/internal/domain"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+const maxDigestSlotsPerUser = 12
+
+func (b *Bot) handleDigestSlotDaysQuery(
+	ctx context.Context,
+	weekdaysStr string,
+	callback *models.CallbackQuery,
+) error {
+	message := callbackMessage(callback)
+	if message == nil {
+		return errors.New("callback query has no accessible message")
+	}
+
+	weekdays, err := parseWeekdayMask(weekdaysStr)
+	if err != nil {
+		return b.answerCallbackError(
+			ctx,
+			callback,
+			"❌ Couldn't parse provided value. Please try again.",
+			fmt.Errorf("parse weekdays: %w", err),
+		)
+	}
+
+	return b.withEmptyCallbackAnswer(ctx, callback, "open digest slot hours", func() error {
+		return b.sendMessageWithKeyboard(
+			ctx,
+			message.Chat.ID,
+			fmt.Sprintf("🕐 At which local hour should the digest be sent %s?", weekdayMaskText(weekdays)),
+			getSettingsDigestSlotHourKeyboard(weekdays),
+		)
+	})
+}
+
+// handleDigestSlotAddQuery handles "<weekdays>_<hour>" callback data.
+func (b *Bot) handleDigestSlotAddQuery(
+	ctx context.Context,
+	slotStr string,
+	callback *models.CallbackQuery,
+) error {
+	message := callbackMessage(callback)
+	if message == nil {
+		return errors.New("callback query has no accessible message")
+	}
+
+	schedule, err := parseDigestSlot(slotStr)
+	if err != nil {
+		return b.answerCallbackError(
+			ctx,
+			callback,
+			"❌ Couldn't parse provided value. Please try again.",
+			fmt.Errorf("parse digest slot: %w", err),
+		)
+	}
+
+	schedule.UserID = callback.From.ID
+
+	schedules, err := b.db.GetUserDigestSchedules(ctx, schedule.UserID)
+	if err != nil {
+		return b.answerCallbackError(
+			ctx,
+			callback,
+			"❌ Couldn't add digest slot. Please try again.",
+			fmt.Errorf("get user digest schedules: %w", err),
+		)
+	}
+
+	if len(schedules) >= maxDigestSlotsPerUser {
+		return b.answerCallbackError(
+			ctx,
+			callback,
+			fmt.Sprintf("❌ You can have up to %d digest slots. Remove one first.", maxDigestSlotsPerUser),
+			nil,
+		)
+	}
+
+	if err = b.db.AddDigestSchedule(ctx, &schedule); err != nil {
+		return b.answerCallbackError(
+			ctx,
+			callback,
+			"❌ Couldn't add digest slot. Please try again.",
+			fmt.Errorf("add digest schedule: %w", err),
+		)
+	}
+
+	if _, err = b.rateLimiter.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: callback.ID,
+		Text:            "✅ Digest slot is added.",
+	}); err != nil {
+		return fmt.Errorf("answer callback query: %w", err)
+	}
+
+	return b.handleSettingsCommand(ctx, message.Chat.ID, callback.From.ID)
+}
+
+func (b *Bot) handleDigestSlotRemoveQuery(
+	ctx context.Context,
+	scheduleIDStr string,
+	callback *models.CallbackQuery,
+) error {
+	message := callbackMessage(callback)
+	if message == nil {
+		return errors.New("callback query has no accessible message")
+	}
+
+	scheduleID, err := strconv.ParseInt(strings.TrimSpace(scheduleIDStr), 10, 64)
+	if err != nil {
+		return b.answerCallbackError(
+			ctx,
+			callback,
+			"❌ Couldn't parse provided value. Please try again.",
+			fmt.Errorf("parse scheduleID: %w", err),
+		)
+	}
+
+	if err = b.db.RemoveDigestSchedule(ctx, callback.From.ID, scheduleID); err != nil {
+		return b.answerCallbackError(
+			ctx,
+			callback,
+			"❌ Couldn't remove digest slot. Please open /settings and try again.",
+			fmt.Errorf("remove digest schedule: %w", err),
+		)
+	}
+
+	if _, err = b.rateLimiter.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: callback.ID,
+		Text:            "✅ Digest slot is removed.",
+	}); err != nil {
+		return fmt.Errorf("answer callback query: %w", err)
+	}
+
+	return b.handleSettingsCommand(ctx, message.Chat.ID, callback.From.ID)
+}
+
+func parseWeekdayMask(weekdaysStr string) (int64, error) {
+	weekdays, err := strconv.ParseInt(strings.TrimSpace(weekdaysStr), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse int: %w", err)
+	}
+
+	if weekdays <= 0 || weekdays > domain.EveryDayMask {
+		return 0, fmt.Errorf("weekday mask is out of range: %d", weekdays)
+	}
+
+	return weekdays, nil
+}
+
+func parseDigestSlot(slotStr string) (domain.DigestSchedule, error) {
+	weekdaysStr, hourStr, ok := strings.Cut(strings.TrimSpace(slotStr), "_")
+	if !ok {
+		return domain.DigestSchedule{}, fmt.Errorf("invalid digest slot: %q", slotStr)
+	}
+
+	weekdays, err := parseWeekdayMask(weekdaysStr)
+	if err != nil {
+		return domain.DigestSchedule{}, fmt.Errorf("parse weekday mask: %w", err)
+	}
+
+	hour, err := strconv.ParseInt(hourStr, 10, 64)
+	if err != nil {
+		return domain.DigestSchedule{}, fmt.Errorf("parse hour: %w", err)
+	}
+
+	if hour < 0 || hour >= hoursPerDay {
+		return domain.DigestSchedule{}, fmt.Errorf("hour is out of range: %d", hour)
+	}
+
+	return domain.DigestSchedule{Hour: hour, Weekdays: weekdays}, nil
+}
+
+// weekdayMaskText describes a weekday mask, e.g. "on workdays" or "on Mon, Wed".
+func weekdayMaskText(weekdays int64) string {
+	switch weekdays {
+	case domain.EveryDayMask:
+		return "every day"
+	case domain.WorkdaysMask:
+		return "on workdays"
+	case domain.WeekendsMask:
+		return "on weekends"
+	}
+
+	var days []string
+	// Weeks start on Monday in the list, while time.Sunday is 0.
+	for i := range 7 {
+		day := time.Weekday((i + 1) % 7)
+		if weekdays&domain.WeekdayMask(day) != 0 {
+			days = append(days, day.String()[:3])
+		}
+	}
+
+	return "on " + strings.Join(days, ", ")
+}
+
+func digestSchedulesText(schedules []domain.DigestSchedule) string {
+	if len(schedules) == 0 {
+		return bot.EscapeMarkdownUnescaped(
+			fmt.Sprintf("No slots yet, so the digest is sent daily at %02d:00.", domain.DefaultAutoDigestHour),
+		)
+	}
+
+	var sb strings.Builder
+
+	for i, schedule := range schedules {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+
+		sb.WriteString(bot.EscapeMarkdownUnescaped(
+			fmt.Sprintf("%d. %02d:00 %s", i+1, schedule.Hour, weekdayMaskText(schedule.Weekdays)),
+		))
+	}
+
+	return sb.String()
+}
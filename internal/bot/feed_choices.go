@@ -0,0 +1,139 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"telekilogram/internal/domain"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+const (
+	feedChoiceAllOption   = "all"
+	feedChoiceTitleMaxLen = 48
+	feedChoiceTextFormat  = `🔎 Found %d feeds on %s\.
+
+Choose the one you want to follow, or follow all of them\.`
+)
+
+func (b *Bot) sendFeedChoice(
+	ctx context.Context,
+	chatID int64,
+	userID int64,
+	choice domain.FeedChoice,
+) error {
+	token, err := b.feedChoices.put(userID, choice)
+	if err != nil {
+		return fmt.Errorf("put feed choice: %w", err)
+	}
+
+	return b.sendMessageWithKeyboard(
+		ctx,
+		chatID,
+		fmt.Sprintf(feedChoiceTextFormat, len(choice.Feeds), formatMarkdownLink(choice.PageURL, choice.PageURL)),
+		getFeedChoiceKeyboard(token, choice.Feeds),
+	)
+}
+
+func (b *Bot) handleFeedChoiceQuery(
+	ctx context.Context,
+	choiceData string,
+	callback *models.CallbackQuery,
+) error {
+	message := callbackMessage(callback)
+	if message == nil {
+		return errors.New("callback query has no accessible message")
+	}
+
+	token, option, ok := strings.Cut(choiceData, "_")
+	if !ok {
+		return b.answerCallbackError(
+			ctx,
+			callback,
+			"❌ Couldn't parse provided value. Please try again.",
+			fmt.Errorf("parse feed choice (data = %s)", choiceData),
+		)
+	}
+
+	choice, ok := b.feedChoices.get(token, callback.From.ID)
+	if !ok {
+		return b.answerCallbackError(
+			ctx,
+			callback,
+			"❌ This choice has expired. Please send the page URL again.",
+			errors.New("get feed choice: not found or expired"),
+		)
+	}
+
+	feeds := choice.Feeds
+	if option != feedChoiceAllOption {
+		idx, err := strconv.Atoi(option)
+		if err == nil && (idx < 0 || idx >= len(choice.Feeds)) {
+			err = fmt.Errorf("index %d is out of range", idx)
+		}
+		if err != nil {
+			return b.answerCallbackError(
+				ctx,
+				callback,
+				"❌ Couldn't parse provided value. Please try again.",
+				fmt.Errorf("parse feed choice index (option = %s): %w", option, err),
+			)
+		}
+
+		feeds = choice.Feeds[idx : idx+1]
+	}
+
+	var errs []error
+	added := 0
+	for _, f := range feeds {
+		if err := b.db.AddFeed(ctx, callback.From.ID, f.URL, f.Title); err != nil {
+			errs = append(errs, fmt.Errorf("add feed: %w", err))
+		} else {
+			added++
+		}
+	}
+
+	if added == 0 {
+		return b.answerCallbackError(
+			ctx,
+			callback,
+			"❌ Couldn't add feed. Please try again.",
+			errors.Join(errs...),
+		)
+	}
+
+	if _, err := b.rateLimiter.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: callback.ID,
+	}); err != nil {
+		errs = append(errs, fmt.Errorf("answer callback query: %w", err))
+	}
+
+	if err := b.sendMessageWithKeyboard(
+		ctx,
+		message.Chat.ID,
+		fmt.Sprintf("✅ Added %d feed\\(s\\)\\.", added),
+		b.returnKeyboard,
+	); err != nil {
+		errs = append(errs, fmt.Errorf("send message with keyboard: %w", err))
+	}
+
+	return errors.Join(errs...)
+}
+
+func feedChoiceButtonText(f domain.Feed) string {
+	title := strings.TrimSpace(f.Title)
+	if title == "" {
+		title = f.URL
+	}
+
+	runes := []rune(title)
+	if len(runes) > feedChoiceTitleMaxLen {
+		title = string(runes[:feedChoiceTitleMaxLen-1]) + "…"
+	}
+
+	return "➕ " + title
+}
@@ -0,0 +1,236 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"telekilogram/internal/domain"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+const (
+	unhealthyFeedIcon     = "⚠️"
+	feedHealthTimeLayout  = "2006-01-02 15:04"
+	feedHealthAlertFormat = `%s *Feed seems to be broken*
+
+%s has been failing since %s \(UTC\), %d fetches in a row\.
+
+Last error: %s
+Last HTTP status: %s
+Last successful fetch \(UTC\): %s
+
+You can retry it now, unfollow it, or keep it and wait until it recovers\.`
+)
+
+func (b *Bot) NotifyUnhealthyFeeds(ctx context.Context) error {
+	if b.cfg.FeedHealthFailurePeriod <= 0 {
+		return nil
+	}
+
+	feeds, err := b.db.GetFeedsForHealthAlert(ctx)
+	if err != nil {
+		return fmt.Errorf("get feeds for health alert: %w", err)
+	}
+
+	var errs []error
+	for _, f := range feeds {
+		if !b.feedUnhealthy(f) {
+			continue
+		}
+
+		if err = b.sendMessageWithKeyboard(
+			ctx,
+			f.UserID,
+			feedHealthAlertText(f),
+			getFeedHealthAlertKeyboard(f.ID),
+		); err != nil {
+			errs = append(errs, fmt.Errorf("send message with keyboard (feedID = %d): %w", f.ID, err))
+			continue
+		}
+
+		if err = b.db.MarkFeedHealthAlertSent(ctx, f.ID); err != nil {
+			errs = append(errs, fmt.Errorf("mark feed health alert sent (feedID = %d): %w", f.ID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// feedUnhealthy reports whether fetches of the feed kept failing for the whole
+// failure period. The period is measured between the first and the last failed
+// fetch, so feeds polled in real time and feeds fetched once a day for their
+// digest are judged alike.
+func (b *Bot) feedUnhealthy(f domain.UserFeed) bool {
+	return b.cfg.FeedHealthFailurePeriod > 0 &&
+		!f.Health.FailingSince.IsZero() &&
+		f.Health.LastErrorAt.Sub(f.Health.FailingSince) >= b.cfg.FeedHealthFailurePeriod
+}
+
+func (b *Bot) handleFeedHealthRetryQuery(
+	ctx context.Context,
+	feedIDStr string,
+	callback *models.CallbackQuery,
+) error {
+	message := callbackMessage(callback)
+	if message == nil {
+		return errors.New("callback query has no accessible message")
+	}
+
+	f, err := b.callbackUserFeed(ctx, feedIDStr, callback)
+	if err != nil {
+		return err
+	}
+
+	if _, err = b.rateLimiter.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: callback.ID,
+		Text:            "🔄 Retrying...",
+	}); err != nil {
+		return fmt.Errorf("answer callback query: %w", err)
+	}
+
+	if retryErr := b.fetcher.RetryFeed(ctx, f); retryErr != nil {
+		errs := []error{fmt.Errorf("retry feed: %w", retryErr)}
+
+		refreshed, getErr := b.db.GetUserFeed(ctx, callback.From.ID, f.ID)
+		if getErr != nil {
+			errs = append(errs, fmt.Errorf("get user feed: %w", getErr))
+			refreshed = f
+		}
+
+		if sendErr := b.sendMessageWithKeyboard(
+			ctx,
+			message.Chat.ID,
+			feedHealthAlertText(*refreshed),
+			getFeedHealthAlertKeyboard(f.ID),
+		); sendErr != nil {
+			errs = append(errs, fmt.Errorf("send message with keyboard: %w", sendErr))
+		}
+
+		return errors.Join(errs...)
+	}
+
+	return b.sendMessageWithKeyboard(
+		ctx,
+		message.Chat.ID,
+		fmt.Sprintf("✅ %s is reachable again\\.", formatMarkdownLink(f.DisplayTitle(), f.URL)),
+		b.returnKeyboard,
+	)
+}
+
+func (b *Bot) handleFeedHealthUnfollowQuery(
+	ctx context.Context,
+	feedIDStr string,
+	callback *models.CallbackQuery,
+) error {
+	message := callbackMessage(callback)
+	if message == nil {
+		return errors.New("callback query has no accessible message")
+	}
+
+	f, err := b.callbackUserFeed(ctx, feedIDStr, callback)
+	if err != nil {
+		return err
+	}
+
+	if err = b.db.RemoveFeed(ctx, f.ID); err != nil {
+		return b.answerCallbackError(
+			ctx,
+			callback,
+			"❌ Couldn't unfollow feed. Please try again.",
+			fmt.Errorf("remove feed: %w", err),
+		)
+	}
+
+	if _, err = b.rateLimiter.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: callback.ID,
+		Text:            "✅ Feed is removed.",
+	}); err != nil {
+		return fmt.Errorf("answer callback query: %w", err)
+	}
+
+	return b.handleListCommand(ctx, message.Chat.ID, callback.From.ID)
+}
+
+func (b *Bot) handleFeedHealthKeepQuery(
+	ctx context.Context,
+	feedIDStr string,
+	callback *models.CallbackQuery,
+) error {
+	if _, err := b.callbackUserFeed(ctx, feedIDStr, callback); err != nil {
+		return err
+	}
+
+	if _, err := b.rateLimiter.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: callback.ID,
+		Text:            "👌 Feed is kept. You'll be notified again if it recovers and breaks later.",
+	}); err != nil {
+		return fmt.Errorf("answer callback query: %w", err)
+	}
+
+	return nil
+}
+
+func (b *Bot) callbackUserFeed(
+	ctx context.Context,
+	feedIDStr string,
+	callback *models.CallbackQuery,
+) (*domain.UserFeed, error) {
+	feedID, err := strconv.ParseInt(strings.TrimSpace(feedIDStr), 10, 64)
+	if err != nil {
+		return nil, b.answerCallbackError(
+			ctx,
+			callback,
+			"❌ Couldn't parse provided value. Please try again.",
+			fmt.Errorf("parse feedID: %w", err),
+		)
+	}
+
+	f, err := b.db.GetUserFeed(ctx, callback.From.ID, feedID)
+	if err != nil {
+		return nil, b.answerCallbackError(
+			ctx,
+			callback,
+			"❌ Couldn't find feed. Please open /list and try again.",
+			fmt.Errorf("get user feed: %w", err),
+		)
+	}
+
+	return f, nil
+}
+
+func feedHealthAlertText(f domain.UserFeed) string {
+	lastError := strings.TrimSpace(f.Health.LastError)
+	if lastError == "" {
+		lastError = "unknown"
+	}
+
+	lastHTTPStatus := "none"
+	if f.Health.LastHTTPStatus != 0 {
+		lastHTTPStatus = strconv.FormatInt(f.Health.LastHTTPStatus, 10)
+	}
+
+	lastSuccess := "never"
+	if !f.Health.LastSuccessAt.IsZero() {
+		lastSuccess = f.Health.LastSuccessAt.UTC().Format(feedHealthTimeLayout)
+	}
+
+	failingSince := "unknown"
+	if !f.Health.FailingSince.IsZero() {
+		failingSince = f.Health.FailingSince.UTC().Format(feedHealthTimeLayout)
+	}
+
+	return fmt.Sprintf(
+		feedHealthAlertFormat,
+		unhealthyFeedIcon,
+		formatMarkdownLink(f.DisplayTitle(), f.URL),
+		bot.EscapeMarkdownUnescaped(failingSince),
+		f.Health.ConsecutiveFailures,
+		bot.EscapeMarkdownUnescaped(lastError),
+		bot.EscapeMarkdownUnescaped(lastHTTPStatus),
+		bot.EscapeMarkdownUnescaped(lastSuccess),
+	)
+}
@@ -0,0 +1,480 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"telekilogram/internal/domain"
+	"telekilogram/internal/feed"
+	"unicode/utf8"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+const (
+	feedMenuTextFormat = `⚙️ *Feed settings*
+
+%s
+
+Delivery mode: %s\.%s
+Digest size: %s\.
+
+– *Digest* adds new posts to your scheduled digests
+– *Real\-time* sends each new post within minutes
+– *Muted* keeps the feed without sending its posts`
+	feedRenamePromptFormat = `✏️ Send a new title for %s\.
+
+Send "\-" to use the feed's own title again\.`
+	feedRenameTitleMaxLength = 100
+	feedRenameResetText      = "-"
+	feedMenuSummariesOnText  = "\nItem summaries: on\\."
+	feedMenuSummariesOffText = "\nItem summaries: off\\. Turn them on for feeds with vague titles\\."
+)
+
+// feedRename is a pending rename, answered by a reply to its prompt message.
+type feedRename struct {
+	feedID          int64
+	promptMessageID int
+}
+
+// feedTopNOptions are the digest caps offered in feed settings; 0 shows all posts.
+func feedTopNOptions() []int64 {
+	return []int64{0, 5, 10, 20}
+}
+
+func feedTopNButtonText(topN int64) string {
+	if topN == 0 {
+		return "All posts"
+	}
+
+	return fmt.Sprintf("🏆 Top %d", topN)
+}
+
+func feedTopNText(topN int64) string {
+	if topN == 0 {
+		return "all posts"
+	}
+
+	return fmt.Sprintf("top %d posts by engagement", topN)
+}
+
+func deliveryModes() []domain.DeliveryMode {
+	return []domain.DeliveryMode{domain.DeliveryModeDigest, domain.DeliveryModeRealtime, domain.DeliveryModeMuted}
+}
+
+func deliveryModeButtonText(mode domain.DeliveryMode) string {
+	switch mode {
+	case domain.DeliveryModeRealtime:
+		return "⚡ Real-time"
+	case domain.DeliveryModeMuted:
+		return "🔇 Muted"
+	default:
+		return "📰 Digest"
+	}
+}
+
+// deliveryModeIcon marks non-default modes in the feed list.
+func deliveryModeIcon(mode domain.DeliveryMode) string {
+	switch mode {
+	case domain.DeliveryModeRealtime:
+		return "⚡ "
+	case domain.DeliveryModeMuted:
+		return "🔇 "
+	default:
+		return ""
+	}
+}
+
+func parseDeliveryMode(modeStr string) (domain.DeliveryMode, error) {
+	mode := domain.DeliveryMode(strings.TrimSpace(modeStr))
+	for _, known := range deliveryModes() {
+		if mode == known {
+			return mode, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown delivery mode: %q", modeStr)
+}
+
+func (b *Bot) handleFeedMenuQuery(
+	ctx context.Context,
+	feedIDStr string,
+	callback *models.CallbackQuery,
+) error {
+	message := callbackMessage(callback)
+	if message == nil {
+		return errors.New("callback query has no accessible message")
+	}
+
+	f, err := b.callbackUserFeed(ctx, feedIDStr, callback)
+	if err != nil {
+		return err
+	}
+
+	return b.withEmptyCallbackAnswer(ctx, callback, "open feed settings", func() error {
+		return b.sendFeedMenu(ctx, message.Chat.ID, *f)
+	})
+}
+
+// handleFeedModeQuery handles "<feedID>_<mode>" callback data.
+func (b *Bot) handleFeedModeQuery(
+	ctx context.Context,
+	modeData string,
+	callback *models.CallbackQuery,
+) error {
+	message := callbackMessage(callback)
+	if message == nil {
+		return errors.New("callback query has no accessible message")
+	}
+
+	feedIDStr, modeStr, _ := strings.Cut(modeData, "_")
+
+	mode, err := parseDeliveryMode(modeStr)
+	if err != nil {
+		return b.answerCallbackError(
+			ctx,
+			callback,
+			"❌ Couldn't parse provided value. Please try again.",
+			fmt.Errorf("parse delivery mode: %w", err),
+		)
+	}
+
+	f, err := b.callbackUserFeed(ctx, feedIDStr, callback)
+	if err != nil {
+		return err
+	}
+
+	if err = b.db.UpdateFeedDeliveryMode(ctx, callback.From.ID, f.ID, mode); err != nil {
+		return b.answerCallbackError(
+			ctx,
+			callback,
+			"❌ Couldn't update delivery mode. Please open /list and try again.",
+			fmt.Errorf("update feed delivery mode: %w", err),
+		)
+	}
+
+	if _, err = b.rateLimiter.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: callback.ID,
+		Text:            "✅ Delivery mode is updated.",
+	}); err != nil {
+		return fmt.Errorf("answer callback query: %w", err)
+	}
+
+	f.DeliveryMode = mode
+
+	return b.sendFeedMenu(ctx, message.Chat.ID, *f)
+}
+
+// handleFeedSummaryQuery handles "<feedID>_<on|off>" callback data.
+func (b *Bot) handleFeedSummaryQuery(
+	ctx context.Context,
+	summaryData string,
+	callback *models.CallbackQuery,
+) error {
+	message := callbackMessage(callback)
+	if message == nil {
+		return errors.New("callback query has no accessible message")
+	}
+
+	feedIDStr, option, _ := strings.Cut(summaryData, "_")
+
+	var summarize bool
+	switch option {
+	case feedSummaryOnOption:
+		summarize = true
+	case feedSummaryOffOption:
+	default:
+		return b.answerCallbackError(
+			ctx,
+			callback,
+			"❌ Couldn't parse provided value. Please try again.",
+			fmt.Errorf("unknown summary option: %q", option),
+		)
+	}
+
+	f, err := b.callbackUserFeed(ctx, feedIDStr, callback)
+	if err != nil {
+		return err
+	}
+
+	if err = b.db.UpdateFeedSummarize(ctx, callback.From.ID, f.ID, summarize); err != nil {
+		return b.answerCallbackError(
+			ctx,
+			callback,
+			"❌ Couldn't update summaries. Please open /list and try again.",
+			fmt.Errorf("update feed summarize: %w", err),
+		)
+	}
+
+	answerText := "✅ Summaries are turned off."
+	if summarize {
+		answerText = "✅ Summaries are turned on."
+	}
+
+	if _, err = b.rateLimiter.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: callback.ID,
+		Text:            answerText,
+	}); err != nil {
+		return fmt.Errorf("answer callback query: %w", err)
+	}
+
+	f.SummarizeItems = summarize
+
+	return b.sendFeedMenu(ctx, message.Chat.ID, *f)
+}
+
+// handleFeedTopQuery handles "<feedID>_<topN>" callback data.
+func (b *Bot) handleFeedTopQuery(
+	ctx context.Context,
+	topData string,
+	callback *models.CallbackQuery,
+) error {
+	message := callbackMessage(callback)
+	if message == nil {
+		return errors.New("callback query has no accessible message")
+	}
+
+	feedIDStr, topNStr, _ := strings.Cut(topData, "_")
+
+	topN, err := strconv.ParseInt(topNStr, 10, 64)
+	if err == nil && !slices.Contains(feedTopNOptions(), topN) {
+		err = fmt.Errorf("unknown top N option: %d", topN)
+	}
+	if err != nil {
+		return b.answerCallbackError(
+			ctx,
+			callback,
+			"❌ Couldn't parse provided value. Please try again.",
+			fmt.Errorf("parse top N: %w", err),
+		)
+	}
+
+	f, err := b.callbackUserFeed(ctx, feedIDStr, callback)
+	if err != nil {
+		return err
+	}
+
+	if err = b.db.UpdateFeedTopN(ctx, callback.From.ID, f.ID, topN); err != nil {
+		return b.answerCallbackError(
+			ctx,
+			callback,
+			"❌ Couldn't update digest size. Please open /list and try again.",
+			fmt.Errorf("update feed top N: %w", err),
+		)
+	}
+
+	if _, err = b.rateLimiter.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: callback.ID,
+		Text:            "✅ Digest size is updated.",
+	}); err != nil {
+		return fmt.Errorf("answer callback query: %w", err)
+	}
+
+	f.TopN = topN
+
+	return b.sendFeedMenu(ctx, message.Chat.ID, *f)
+}
+
+// handleFeedRenameQuery asks for a new feed title in a reply to the prompt.
+func (b *Bot) handleFeedRenameQuery(
+	ctx context.Context,
+	feedIDStr string,
+	callback *models.CallbackQuery,
+) error {
+	message := callbackMessage(callback)
+	if message == nil {
+		return errors.New("callback query has no accessible message")
+	}
+
+	f, err := b.callbackUserFeed(ctx, feedIDStr, callback)
+	if err != nil {
+		return err
+	}
+
+	return b.withEmptyCallbackAnswer(ctx, callback, "rename feed", func() error {
+		prompt, sendErr := b.rateLimiter.SendMessage(ctx, &bot.SendMessageParams{
+			ChatID:    message.Chat.ID,
+			Text:      fmt.Sprintf(feedRenamePromptFormat, formatMarkdownLink(f.DisplayTitle(), f.URL)),
+			ParseMode: models.ParseModeMarkdown,
+			LinkPreviewOptions: &models.LinkPreviewOptions{
+				IsDisabled: bot.True(),
+			},
+			ReplyMarkup: &models.ForceReply{
+				ForceReply:            true,
+				InputFieldPlaceholder: "New title",
+			},
+		})
+		if sendErr != nil {
+			return fmt.Errorf("send message: %w", sendErr)
+		}
+
+		b.feedRenames.set(feedRenameToken(callback.From.ID), callback.From.ID, feedRename{
+			feedID:          f.ID,
+			promptMessageID: prompt.ID,
+		})
+
+		return nil
+	})
+}
+
+// pendingFeedRename returns the feed the user is renaming when the message
+// replies to the latest rename prompt.
+func (b *Bot) pendingFeedRename(message *models.Message) (int64, bool) {
+	if message.ReplyToMessage == nil || message.From == nil || strings.TrimSpace(message.Text) == "" {
+		return 0, false
+	}
+
+	rename, ok := b.feedRenames.get(feedRenameToken(message.From.ID), message.From.ID)
+	if !ok || rename.promptMessageID != message.ReplyToMessage.ID {
+		return 0, false
+	}
+
+	return rename.feedID, true
+}
+
+func (b *Bot) handleFeedRenameReply(ctx context.Context, text string, feedID int64, message *models.Message) error {
+	title := strings.Join(strings.Fields(text), " ")
+	if title == feedRenameResetText {
+		title = ""
+	}
+
+	if utf8.RuneCountInString(title) > feedRenameTitleMaxLength {
+		return b.sendMessageWithKeyboard(
+			ctx,
+			message.Chat.ID,
+			fmt.Sprintf(
+				"❌ The title is too long\\. Please reply with at most %d characters\\.",
+				feedRenameTitleMaxLength,
+			),
+			b.returnKeyboard,
+		)
+	}
+
+	userID := message.From.ID
+
+	if err := b.db.UpdateFeedCustomTitle(ctx, userID, feedID, title); err != nil {
+		var errs []error
+		errs = append(errs, fmt.Errorf("update feed custom title: %w", err))
+
+		if sendErr := b.sendMessageWithKeyboard(
+			ctx,
+			message.Chat.ID,
+			b.withIssueReportLink("❌ Couldn't rename feed\\. Please open /list and try again\\."),
+			b.returnKeyboard,
+		); sendErr != nil {
+			errs = append(errs, fmt.Errorf("send message with keyboard: %w", sendErr))
+		}
+
+		return errors.Join(errs...)
+	}
+
+	b.cancelFeedRename(userID)
+
+	f, err := b.db.GetUserFeed(ctx, userID, feedID)
+	if err != nil {
+		return fmt.Errorf("get user feed: %w", err)
+	}
+
+	return b.sendFeedMenu(ctx, message.Chat.ID, *f)
+}
+
+// feedRenameToken keys the pending rename of a user, as a user renames one
+// feed at a time.
+func feedRenameToken(userID int64) string {
+	return strconv.FormatInt(userID, 10)
+}
+
+// cancelFeedRename drops the pending rename of a user, so a later reply to the
+// prompt isn't taken as a title once the user moved on to another command.
+func (b *Bot) cancelFeedRename(userID int64) {
+	b.feedRenames.remove(feedRenameToken(userID))
+}
+
+// handleFeedMoveQuery handles "<feedID>_<up|down>" callback data.
+func (b *Bot) handleFeedMoveQuery(
+	ctx context.Context,
+	moveData string,
+	callback *models.CallbackQuery,
+) error {
+	message := callbackMessage(callback)
+	if message == nil {
+		return errors.New("callback query has no accessible message")
+	}
+
+	feedIDStr, option, _ := strings.Cut(moveData, "_")
+
+	var offset int
+	switch option {
+	case feedMoveUpOption:
+		offset = -1
+	case feedMoveDownOption:
+		offset = 1
+	default:
+		return b.answerCallbackError(
+			ctx,
+			callback,
+			"❌ Couldn't parse provided value. Please try again.",
+			fmt.Errorf("unknown move option: %q", option),
+		)
+	}
+
+	f, err := b.callbackUserFeed(ctx, feedIDStr, callback)
+	if err != nil {
+		return err
+	}
+
+	moved, err := b.db.MoveFeed(ctx, callback.From.ID, f.ID, offset)
+	if err != nil {
+		return b.answerCallbackError(
+			ctx,
+			callback,
+			"❌ Couldn't move feed. Please open /list and try again.",
+			fmt.Errorf("move feed: %w", err),
+		)
+	}
+
+	answerText := "✅ Moved down."
+	switch {
+	case !moved && offset < 0:
+		answerText = "The feed is already first in /list."
+	case !moved:
+		answerText = "The feed is already last in /list."
+	case offset < 0:
+		answerText = "✅ Moved up."
+	}
+
+	if _, err = b.rateLimiter.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: callback.ID,
+		Text:            answerText,
+	}); err != nil {
+		return fmt.Errorf("answer callback query: %w", err)
+	}
+
+	return nil
+}
+
+func (b *Bot) sendFeedMenu(ctx context.Context, chatID int64, f domain.UserFeed) error {
+	return b.sendMessageWithKeyboard(ctx, chatID, feedMenuText(f), getFeedMenuKeyboard(f))
+}
+
+func feedMenuText(f domain.UserFeed) string {
+	summaries := ""
+	if !feed.IsTelegramChannelURL(f.URL) {
+		summaries = feedMenuSummariesOffText
+		if f.SummarizeItems {
+			summaries = feedMenuSummariesOnText
+		}
+	}
+
+	return fmt.Sprintf(
+		feedMenuTextFormat,
+		formatMarkdownLink(f.DisplayTitle(), f.URL),
+		bot.EscapeMarkdownUnescaped(deliveryModeButtonText(f.DeliveryMode)),
+		summaries,
+		feedTopNText(f.TopN),
+	)
+}
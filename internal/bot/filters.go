@@ -0,0 +1,292 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"telekilogram/internal/domain"
+	"telekilogram/internal/feed"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+const (
+	filterScopeAllOption = "all"
+	filterUsageText      = `Add a rule with:
+/filter include \<pattern\> – keep only matching posts
+/filter exclude \<pattern\> – drop matching posts
+
+Add *regex* before the pattern to use a regular expression, and *case* to make matching case\-sensitive, e\.g\.:
+/filter exclude regex case ^\[Ad\]
+
+Rules match RSS item titles and Telegram post text\.`
+)
+
+func (b *Bot) handleFilterCommand(ctx context.Context, text string, chatID int64, userID int64) error {
+	args := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(text), "/filter"))
+	if args == "" {
+		return b.sendFilterList(ctx, chatID, userID)
+	}
+
+	rule, err := parseFilterRule(args)
+	if err == nil {
+		_, err = feed.CompileFilterPattern(rule.Pattern, rule.Regex, rule.CaseSensitive)
+	}
+	if err != nil {
+		errs := []error{fmt.Errorf("parse filter rule: %w", err)}
+
+		sendErr := b.sendMessageWithKeyboard(
+			ctx,
+			chatID,
+			"❌ Couldn't parse filter rule\\.\n\n"+filterUsageText,
+			b.returnKeyboard,
+		)
+		if sendErr != nil {
+			errs = append(errs, fmt.Errorf("send message with keyboard: %w", sendErr))
+		}
+
+		return errors.Join(errs...)
+	}
+
+	rule.UserID = userID
+
+	feeds, err := b.db.GetUserFeeds(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get user feeds: %w", err)
+	}
+
+	token, err := b.filterDrafts.put(userID, rule)
+	if err != nil {
+		return fmt.Errorf("put filter draft: %w", err)
+	}
+
+	return b.sendMessageWithKeyboard(
+		ctx,
+		chatID,
+		fmt.Sprintf("🔎 Where should the rule %s apply?", filterRuleDescription(rule)),
+		getFilterScopeKeyboard(token, feeds),
+	)
+}
+
+func (b *Bot) sendFilterList(ctx context.Context, chatID int64, userID int64) error {
+	rules, err := b.db.GetUserFilterRules(ctx, userID)
+	if err != nil {
+		errs := []error{fmt.Errorf("get user filter rules: %w", err)}
+
+		sendErr := b.sendMessageWithKeyboard(
+			ctx,
+			chatID,
+			b.withIssueReportLink("❌ Couldn't load filters\\. Please try again\\."),
+			b.returnKeyboard,
+		)
+		if sendErr != nil {
+			errs = append(errs, fmt.Errorf("send message with keyboard: %w", sendErr))
+		}
+
+		return errors.Join(errs...)
+	}
+
+	var message strings.Builder
+	message.WriteString("🔎 *Filters*\n\n")
+
+	if len(rules) == 0 {
+		message.WriteString("You don't have any filters yet\\.\n\n")
+	}
+
+	for i, rule := range rules {
+		fmt.Fprintf(&message, "%d\\. %s\n", i+1, filterRuleDescription(rule))
+	}
+	if len(rules) > 0 {
+		message.WriteString("\n")
+	}
+
+	message.WriteString(filterUsageText)
+
+	return b.sendMessageWithKeyboard(ctx, chatID, message.String(), getFilterListKeyboard(rules))
+}
+
+func (b *Bot) handleFilterScopeQuery(
+	ctx context.Context,
+	scopeData string,
+	callback *models.CallbackQuery,
+) error {
+	message := callbackMessage(callback)
+	if message == nil {
+		return errors.New("callback query has no accessible message")
+	}
+
+	token, option, ok := strings.Cut(scopeData, "_")
+	if !ok {
+		return b.answerCallbackError(
+			ctx,
+			callback,
+			"❌ Couldn't parse provided value. Please try again.",
+			fmt.Errorf("parse filter scope (data = %s)", scopeData),
+		)
+	}
+
+	rule, ok := b.filterDrafts.get(token, callback.From.ID)
+	if !ok {
+		return b.answerCallbackError(
+			ctx,
+			callback,
+			"❌ This rule has expired. Please send /filter again.",
+			errors.New("get filter draft: not found or expired"),
+		)
+	}
+
+	if option != filterScopeAllOption {
+		f, err := b.callbackUserFeed(ctx, option, callback)
+		if err != nil {
+			return err
+		}
+
+		rule.FeedID = f.ID
+	}
+
+	if err := b.db.AddFilterRule(ctx, &rule); err != nil {
+		return b.answerCallbackError(
+			ctx,
+			callback,
+			"❌ Couldn't add filter. Please try again.",
+			fmt.Errorf("add filter rule: %w", err),
+		)
+	}
+
+	if _, err := b.rateLimiter.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: callback.ID,
+		Text:            "✅ Filter is added.",
+	}); err != nil {
+		return fmt.Errorf("answer callback query: %w", err)
+	}
+
+	return b.sendFilterList(ctx, message.Chat.ID, callback.From.ID)
+}
+
+func (b *Bot) handleFilterDeleteQuery(
+	ctx context.Context,
+	ruleIDStr string,
+	callback *models.CallbackQuery,
+) error {
+	message := callbackMessage(callback)
+	if message == nil {
+		return errors.New("callback query has no accessible message")
+	}
+
+	ruleID, err := strconv.ParseInt(strings.TrimSpace(ruleIDStr), 10, 64)
+	if err != nil {
+		return b.answerCallbackError(
+			ctx,
+			callback,
+			"❌ Couldn't parse provided value. Please try again.",
+			fmt.Errorf("parse ruleID: %w", err),
+		)
+	}
+
+	if err = b.db.RemoveFilterRule(ctx, callback.From.ID, ruleID); err != nil {
+		return b.answerCallbackError(
+			ctx,
+			callback,
+			"❌ Couldn't remove filter. Please open /filter and try again.",
+			fmt.Errorf("remove filter rule: %w", err),
+		)
+	}
+
+	if _, err = b.rateLimiter.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{
+		CallbackQueryID: callback.ID,
+		Text:            "✅ Filter is removed.",
+	}); err != nil {
+		return fmt.Errorf("answer callback query: %w", err)
+	}
+
+	return b.sendFilterList(ctx, message.Chat.ID, callback.From.ID)
+}
+
+// parseFilterRule parses "<include|exclude> [regex|keyword] [case] <pattern>".
+// Optional words are only consumed when a pattern follows them, so a rule can
+// still match the literal words "regex" or "case".
+func parseFilterRule(args string) (domain.FilterRule, error) {
+	var rule domain.FilterRule
+
+	action, rest := cutFilterWord(args)
+	switch strings.ToLower(action) {
+	case "include":
+	case "exclude":
+		rule.Exclude = true
+	default:
+		return domain.FilterRule{}, fmt.Errorf("unknown filter action: %q", action)
+	}
+
+	if word, after := cutFilterWord(rest); after != "" {
+		switch strings.ToLower(word) {
+		case "regex":
+			rule.Regex = true
+			rest = after
+		case "keyword":
+			rest = after
+		}
+	}
+
+	if word, after := cutFilterWord(rest); after != "" && strings.EqualFold(word, "case") {
+		rule.CaseSensitive = true
+		rest = after
+	}
+
+	rule.Pattern = strings.TrimSpace(rest)
+	if rule.Pattern == "" {
+		return domain.FilterRule{}, errors.New("filter pattern is empty")
+	}
+
+	return rule, nil
+}
+
+func cutFilterWord(s string) (string, string) {
+	s = strings.TrimSpace(s)
+
+	word, rest, _ := strings.Cut(s, " ")
+
+	return word, strings.TrimSpace(rest)
+}
+
+func filterRuleDescription(rule domain.FilterRule) string {
+	action := "➕ include"
+	if rule.Exclude {
+		action = "➖ exclude"
+	}
+
+	kind := "keyword"
+	if rule.Regex {
+		kind = "regex"
+	}
+
+	caseNote := ""
+	if rule.CaseSensitive {
+		caseNote = " \\(case\\-sensitive\\)"
+	}
+
+	scope := "all feeds"
+	if rule.FeedID != 0 {
+		scope = rule.FeedTitle
+		if scope == "" {
+			scope = "feed #" + strconv.FormatInt(rule.FeedID, 10)
+		}
+	}
+
+	return fmt.Sprintf(
+		"%s %s `%s`%s in %s",
+		action,
+		kind,
+		escapeMarkdownCode(rule.Pattern),
+		caseNote,
+		bot.EscapeMarkdownUnescaped(scope),
+	)
+}
+
+// escapeMarkdownCode escapes text placed inside MarkdownV2 inline code, where
+// only backticks and backslashes are special.
+func escapeMarkdownCode(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "`", "\\`").Replace(s)
+}
@@ -3,7 +3,11 @@ package bot
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"strings"
+	"telekilogram/internal/domain"
+	"telekilogram/internal/feed"
+	"time"
 	"unicode/utf8"
 
 	"github.com/go-telegram/bot"
@@ -11,9 +15,30 @@ import (
 )
 
 const (
-	hoursPerDay                                     = 24
-	settingsAutoDigestHourUTCKeyboardRowSize        = 5
-	settingsAutoDigestHourUTCKeyboardCallbackPrefix = "settings_auto_digest_hour_utc_"
+	hoursPerDay                             = 24
+	settingsDigestSlotHourKeyboardRowSize   = 5
+	settingsDigestSlotRemoveKeyboardRowSize = 4
+	settingsDigestSlotDaysCallbackPrefix    = "settings_slot_days_"
+	settingsDigestSlotAddCallbackPrefix     = "settings_slot_add_"
+	settingsDigestSlotRemoveCallbackPrefix  = "settings_slot_remove_"
+	feedHealthRetryCallbackPrefix           = "feed_health_retry_"
+	feedHealthUnfollowCallbackPrefix        = "feed_health_unfollow_"
+	feedHealthKeepCallbackPrefix            = "feed_health_keep_"
+	feedChoiceCallbackPrefix                = "feed_choice_"
+	filterScopeCallbackPrefix               = "filter_scope_"
+	filterDeleteCallbackPrefix              = "filter_delete_"
+	filterListKeyboardRowSize               = 4
+	feedMenuCallbackPrefix                  = "feed_menu_"
+	feedModeCallbackPrefix                  = "feed_mode_"
+	feedSummaryCallbackPrefix               = "feed_summary_"
+	feedSummaryOnOption                     = "on"
+	feedSummaryOffOption                    = "off"
+	feedTopCallbackPrefix                   = "feed_top_"
+	feedRenameCallbackPrefix                = "feed_rename_"
+	feedMoveCallbackPrefix                  = "feed_move_"
+	feedMoveUpOption                        = "up"
+	feedMoveDownOption                      = "down"
+	feedListKeyboardRowSize                 = 5
 )
 
 func (b *Bot) sendMessageWithKeyboard(
@@ -61,27 +86,98 @@ func getMenuKeyboard() [][]models.InlineKeyboardButton {
 	return [][]models.InlineKeyboardButton{
 		{
 			{Text: "📄 Feed list", CallbackData: "menu_list"},
-			{Text: "👈 24h digest", CallbackData: "menu_digest"},
+			{Text: "👈 Digest since last", CallbackData: "menu_digest"},
 		},
 		{
+			{Text: "🔎 Filters", CallbackData: "menu_filter"},
 			{Text: "⚙️ Settings", CallbackData: "menu_settings"},
 		},
 	}
 }
 
-func getSettingsAutoDigestHourUTCKeyboard() [][]models.InlineKeyboardButton {
+func getSettingsKeyboard(schedules []domain.DigestSchedule) [][]models.InlineKeyboardButton {
 	var keyboard [][]models.InlineKeyboardButton
 
-	for i := 0; i < hoursPerDay; i += settingsAutoDigestHourUTCKeyboardRowSize {
+	for i := 0; i < len(schedules); i += settingsDigestSlotRemoveKeyboardRowSize {
 		var row []models.InlineKeyboardButton
 
-		for j := i; j < i+settingsAutoDigestHourUTCKeyboardRowSize && j < hoursPerDay; j++ {
+		for j := i; j < i+settingsDigestSlotRemoveKeyboardRowSize && j < len(schedules); j++ {
+			row = append(row, models.InlineKeyboardButton{
+				Text:         fmt.Sprintf("🗑 %d", j+1),
+				CallbackData: settingsDigestSlotRemoveCallbackPrefix + strconv.FormatInt(schedules[j].ID, 10),
+			})
+		}
+
+		keyboard = append(keyboard, row)
+	}
+
+	return append(
+		keyboard,
+		[]models.InlineKeyboardButton{
+			{Text: "➕ Add slot", CallbackData: "settings_slot_new"},
+			{Text: "🌍 Time zone", CallbackData: "settings_timezone"},
+		},
+		[]models.InlineKeyboardButton{{Text: "⬅️ Return to menu", CallbackData: "menu"}},
+	)
+}
+
+func getSettingsDigestSlotDaysKeyboard() [][]models.InlineKeyboardButton {
+	days := func(text string, mask int64) models.InlineKeyboardButton {
+		return models.InlineKeyboardButton{
+			Text:         text,
+			CallbackData: settingsDigestSlotDaysCallbackPrefix + strconv.FormatInt(mask, 10),
+		}
+	}
+	weekday := func(d time.Weekday) models.InlineKeyboardButton {
+		return days(d.String()[:3], domain.WeekdayMask(d))
+	}
+
+	return [][]models.InlineKeyboardButton{
+		{
+			days("Every day", domain.EveryDayMask),
+			days("Workdays", domain.WorkdaysMask),
+			days("Weekends", domain.WeekendsMask),
+		},
+		{weekday(time.Monday), weekday(time.Tuesday), weekday(time.Wednesday), weekday(time.Thursday)},
+		{weekday(time.Friday), weekday(time.Saturday), weekday(time.Sunday)},
+		{{Text: "⬅️ Back", CallbackData: "menu_settings"}},
+	}
+}
+
+// getTimezoneCandidatesKeyboard shows each candidate time zone with its
+// current UTC offset.
+func getTimezoneCandidatesKeyboard(candidates []string, now time.Time) [][]models.InlineKeyboardButton {
+	keyboard := make([][]models.InlineKeyboardButton, 0, len(candidates)+1)
+
+	for _, name := range candidates {
+		text := name
+		if loc, err := loadUserTimezone(name); err == nil {
+			text = fmt.Sprintf("%s (UTC%s)", name, now.In(loc).Format("-07:00"))
+		}
+
+		keyboard = append(keyboard, []models.InlineKeyboardButton{
+			{Text: text, CallbackData: settingsTimezoneCallbackPrefix + name},
+		})
+	}
+
+	return append(keyboard, []models.InlineKeyboardButton{{Text: "⬅️ Back", CallbackData: "menu_settings"}})
+}
+
+func getSettingsDigestSlotHourKeyboard(weekdays int64) [][]models.InlineKeyboardButton {
+	var keyboard [][]models.InlineKeyboardButton
+
+	prefix := settingsDigestSlotAddCallbackPrefix + strconv.FormatInt(weekdays, 10) + "_"
+
+	for i := 0; i < hoursPerDay; i += settingsDigestSlotHourKeyboardRowSize {
+		var row []models.InlineKeyboardButton
+
+		for j := i; j < i+settingsDigestSlotHourKeyboardRowSize && j < hoursPerDay; j++ {
 			hour := fmt.Sprintf("%02d", j)
 			row = append(
 				row,
 				models.InlineKeyboardButton{
-					Text:         hour,
-					CallbackData: settingsAutoDigestHourUTCKeyboardCallbackPrefix + hour,
+					Text:         hour + ":00",
+					CallbackData: prefix + hour,
 				},
 			)
 		}
@@ -89,7 +185,149 @@ func getSettingsAutoDigestHourUTCKeyboard() [][]models.InlineKeyboardButton {
 		keyboard = append(keyboard, row)
 	}
 
-	return keyboard
+	return append(keyboard, []models.InlineKeyboardButton{{Text: "⬅️ Back", CallbackData: "settings_slot_new"}})
+}
+
+func getFeedHealthAlertKeyboard(feedID int64) [][]models.InlineKeyboardButton {
+	id := strconv.FormatInt(feedID, 10)
+
+	return [][]models.InlineKeyboardButton{
+		{
+			{Text: "🔄 Retry", CallbackData: feedHealthRetryCallbackPrefix + id},
+			{Text: "🗑 Unfollow", CallbackData: feedHealthUnfollowCallbackPrefix + id},
+			{Text: "👌 Keep", CallbackData: feedHealthKeepCallbackPrefix + id},
+		},
+		{{Text: "⬅️ Return to menu", CallbackData: "menu"}},
+	}
+}
+
+func getFeedChoiceKeyboard(token string, feeds []domain.Feed) [][]models.InlineKeyboardButton {
+	keyboard := make([][]models.InlineKeyboardButton, 0, len(feeds)+2)
+
+	for i, f := range feeds {
+		keyboard = append(keyboard, []models.InlineKeyboardButton{{
+			Text:         feedChoiceButtonText(f),
+			CallbackData: feedChoiceCallbackPrefix + token + "_" + strconv.Itoa(i),
+		}})
+	}
+
+	return append(
+		keyboard,
+		[]models.InlineKeyboardButton{
+			{Text: "✅ Follow all", CallbackData: feedChoiceCallbackPrefix + token + "_" + feedChoiceAllOption},
+		},
+		[]models.InlineKeyboardButton{{Text: "⬅️ Return to menu", CallbackData: "menu"}},
+	)
+}
+
+func getFilterScopeKeyboard(token string, feeds []domain.UserFeed) [][]models.InlineKeyboardButton {
+	keyboard := make([][]models.InlineKeyboardButton, 0, len(feeds)+2)
+	keyboard = append(keyboard, []models.InlineKeyboardButton{
+		{Text: "🌐 All feeds", CallbackData: filterScopeCallbackPrefix + token + "_" + filterScopeAllOption},
+	})
+
+	for _, f := range feeds {
+		keyboard = append(keyboard, []models.InlineKeyboardButton{{
+			Text:         feedChoiceButtonText(domain.Feed{URL: f.URL, Title: f.DisplayTitle()}),
+			CallbackData: filterScopeCallbackPrefix + token + "_" + strconv.FormatInt(f.ID, 10),
+		}})
+	}
+
+	return append(keyboard, []models.InlineKeyboardButton{{Text: "⬅️ Return to menu", CallbackData: "menu"}})
+}
+
+func getFilterListKeyboard(rules []domain.FilterRule) [][]models.InlineKeyboardButton {
+	var keyboard [][]models.InlineKeyboardButton
+
+	for i := 0; i < len(rules); i += filterListKeyboardRowSize {
+		var row []models.InlineKeyboardButton
+
+		for j := i; j < i+filterListKeyboardRowSize && j < len(rules); j++ {
+			row = append(row, models.InlineKeyboardButton{
+				Text:         fmt.Sprintf("🗑 %d", j+1),
+				CallbackData: filterDeleteCallbackPrefix + strconv.FormatInt(rules[j].ID, 10),
+			})
+		}
+
+		keyboard = append(keyboard, row)
+	}
+
+	return append(keyboard, []models.InlineKeyboardButton{{Text: "⬅️ Return to menu", CallbackData: "menu"}})
+}
+
+func getFeedListKeyboard(feeds []domain.UserFeed) [][]models.InlineKeyboardButton {
+	var keyboard [][]models.InlineKeyboardButton
+
+	for i := 0; i < len(feeds); i += feedListKeyboardRowSize {
+		var row []models.InlineKeyboardButton
+
+		for j := i; j < i+feedListKeyboardRowSize && j < len(feeds); j++ {
+			row = append(row, models.InlineKeyboardButton{
+				Text:         fmt.Sprintf("⚙️ %d", j+1),
+				CallbackData: feedMenuCallbackPrefix + strconv.FormatInt(feeds[j].ID, 10),
+			})
+		}
+
+		keyboard = append(keyboard, row)
+	}
+
+	return append(keyboard, []models.InlineKeyboardButton{{Text: "⬅️ Return to menu", CallbackData: "menu"}})
+}
+
+func getFeedMenuKeyboard(f domain.UserFeed) [][]models.InlineKeyboardButton {
+	prefix := feedModeCallbackPrefix + strconv.FormatInt(f.ID, 10) + "_"
+
+	var row []models.InlineKeyboardButton
+	for _, mode := range deliveryModes() {
+		text := deliveryModeButtonText(mode)
+		if mode == f.DeliveryMode {
+			text = "✅ " + text
+		}
+
+		row = append(row, models.InlineKeyboardButton{Text: text, CallbackData: prefix + string(mode)})
+	}
+
+	keyboard := [][]models.InlineKeyboardButton{row}
+	id := strconv.FormatInt(f.ID, 10)
+
+	// Telegram posts are always summarized, so only other feeds get a toggle.
+	if !feed.IsTelegramChannelURL(f.URL) {
+		button := models.InlineKeyboardButton{
+			Text:         "📝 Turn summaries on",
+			CallbackData: feedSummaryCallbackPrefix + id + "_" + feedSummaryOnOption,
+		}
+		if f.SummarizeItems {
+			button = models.InlineKeyboardButton{
+				Text:         "📝 Turn summaries off",
+				CallbackData: feedSummaryCallbackPrefix + id + "_" + feedSummaryOffOption,
+			}
+		}
+
+		keyboard = append(keyboard, []models.InlineKeyboardButton{button})
+	}
+
+	topPrefix := feedTopCallbackPrefix + id + "_"
+
+	var topRow []models.InlineKeyboardButton
+	for _, topN := range feedTopNOptions() {
+		text := feedTopNButtonText(topN)
+		if topN == f.TopN {
+			text = "✅ " + text
+		}
+
+		topRow = append(topRow, models.InlineKeyboardButton{
+			Text:         text,
+			CallbackData: topPrefix + strconv.FormatInt(topN, 10),
+		})
+	}
+
+	keyboard = append(keyboard, topRow, []models.InlineKeyboardButton{
+		{Text: "✏️ Rename", CallbackData: feedRenameCallbackPrefix + id},
+		{Text: "⬆️ Move up", CallbackData: feedMoveCallbackPrefix + id + "_" + feedMoveUpOption},
+		{Text: "⬇️ Move down", CallbackData: feedMoveCallbackPrefix + id + "_" + feedMoveDownOption},
+	})
+
+	return append(keyboard, []models.InlineKeyboardButton{{Text: "⬅️ Back to list", CallbackData: "menu_list"}})
 }
 
 func splitTelegramText(text string) []string {
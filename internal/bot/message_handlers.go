@@ -5,18 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"telekilogram/internal/domain"
 	"telekilogram/internal/feed"
 
 	"github.com/go-telegram/bot/models"
 )
 
-func filterText() string {
-	return `Telekilogram does not support filtering\.\.\.
-
-But you can use awesome ` + formatMarkdownLink("siftrss", "https://siftrss.com/") + ` instead\! ✨
-It's totally great\. Bot author is also using it\.`
-}
-
 func (b *Bot) handleMessage(ctx context.Context, message *models.Message) error {
 	return b.withSpinner(ctx, message.Chat.ID, func() error {
 		if message.ForwardOrigin != nil && // If message is forwarded...
@@ -31,8 +25,20 @@ func (b *Bot) handleMessage(ctx context.Context, message *models.Message) error
 			)
 		}
 
+		if message.Location != nil {
+			return b.handleLocation(ctx, message.Location, message.Chat.ID)
+		}
+
+		if isOPMLDocument(message.Document) {
+			return b.handleOPMLDocument(ctx, message)
+		}
+
 		text := strings.TrimSpace(message.Text)
 
+		if strings.HasPrefix(text, "/") {
+			b.cancelFeedRename(message.From.ID)
+		}
+
 		switch {
 		case strings.HasPrefix(text, "/start"):
 			return b.handleStartCommand(ctx, text, message.Chat.ID, message.From.ID)
@@ -42,11 +48,19 @@ func (b *Bot) handleMessage(ctx context.Context, message *models.Message) error
 			return b.handleListCommand(ctx, message.Chat.ID, message.From.ID)
 		case strings.HasPrefix(text, "/digest"):
 			return b.handleDigestCommand(ctx, message.Chat.ID, message.From.ID)
+		case strings.HasPrefix(text, "/export"):
+			return b.handleExportCommand(ctx, message.Chat.ID, message.From.ID)
 		case strings.HasPrefix(text, "/filter"):
-			return b.sendMessageWithKeyboard(ctx, message.Chat.ID, filterText(), b.menuKeyboard)
+			return b.handleFilterCommand(ctx, text, message.Chat.ID, message.From.ID)
+		case strings.HasPrefix(text, "/timezone"):
+			return b.handleTimezoneCommand(ctx, text, message.Chat.ID, message.From.ID)
 		case strings.HasPrefix(text, "/settings"):
 			return b.handleSettingsCommand(ctx, message.Chat.ID, message.From.ID)
 		default:
+			if feedID, ok := b.pendingFeedRename(message); ok {
+				return b.handleFeedRenameReply(ctx, text, feedID, message)
+			}
+
 			return b.handleRandomText(ctx, text, message.From.ID, message)
 		}
 	})
@@ -60,7 +74,11 @@ func (b *Bot) handleRandomText(
 ) error {
 	text = strings.TrimSpace(text)
 
-	feeds, err := b.fetcher.FindValidFeeds(ctx, text)
+	feeds, choices, err := b.fetcher.FindValidFeeds(ctx, text)
+
+	if len(choices) > 0 {
+		return b.handleFeedChoices(ctx, feeds, choices, err, userID, message.Chat.ID)
+	}
 
 	if len(feeds) == 0 {
 		var errs []error
@@ -136,6 +154,50 @@ Send a feed URL, a t\.me link, a @channel username, or forward a message from a
 	return nil
 }
 
+// handleFeedChoices adds unambiguous feeds right away and asks the user to pick
+// from the feeds discovered on each web page that advertises several of them.
+func (b *Bot) handleFeedChoices(
+	ctx context.Context,
+	feeds []domain.Feed,
+	choices []domain.FeedChoice,
+	findErr error,
+	userID int64,
+	chatID int64,
+) error {
+	var errs []error
+	if findErr != nil {
+		errs = append(errs, fmt.Errorf("find valid feeds: %w", findErr))
+	}
+
+	added := 0
+	for _, f := range feeds {
+		if err := b.db.AddFeed(ctx, userID, f.URL, f.Title); err != nil {
+			errs = append(errs, fmt.Errorf("add feed: %w", err))
+		} else {
+			added++
+		}
+	}
+
+	if added > 0 {
+		if err := b.sendMessageWithKeyboard(
+			ctx,
+			chatID,
+			fmt.Sprintf("✅ Added %d feed\\(s\\)\\.", added),
+			b.returnKeyboard,
+		); err != nil {
+			errs = append(errs, fmt.Errorf("send message with keyboard: %w", err))
+		}
+	}
+
+	for _, choice := range choices {
+		if err := b.sendFeedChoice(ctx, chatID, userID, choice); err != nil {
+			errs = append(errs, fmt.Errorf("send feed choice: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
 func (b *Bot) handleForwardedChannel(
 	ctx context.Context,
 	chat *models.Chat,
@@ -0,0 +1,347 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"telekilogram/internal/domain"
+	"telekilogram/internal/feed"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+const (
+	opmlExportFilename = "telekilogram-subscriptions.opml"
+	opmlExportTitle    = "Telekilogram subscriptions"
+	// opmlImportReportTimeout bounds sending the report, which gets its own
+	// context as the import one may have expired.
+	opmlImportReportTimeout = 30 * time.Second
+)
+
+type opmlImportStatus int
+
+const (
+	opmlImportAdded opmlImportStatus = iota
+	opmlImportAlreadyFollowed
+	opmlImportAmbiguous
+	opmlImportInvalid
+)
+
+type opmlImportResult struct {
+	feed   domain.Feed
+	status opmlImportStatus
+}
+
+func (b *Bot) handleExportCommand(ctx context.Context, chatID int64, userID int64) error {
+	feeds, err := b.db.GetUserFeeds(ctx, userID)
+	if err != nil {
+		errs := []error{fmt.Errorf("get user feeds: %w", err)}
+
+		sendErr := b.sendMessageWithKeyboard(
+			ctx,
+			chatID,
+			b.withIssueReportLink("❌ Couldn't load feed list\\. Please try again\\."),
+			b.returnKeyboard,
+		)
+		if sendErr != nil {
+			errs = append(errs, fmt.Errorf("send message with keyboard: %w", sendErr))
+		}
+
+		return errors.Join(errs...)
+	}
+
+	if len(feeds) == 0 {
+		return b.sendMessageWithKeyboard(
+			ctx,
+			chatID,
+			"📭 You don't have any feeds to export yet\\.",
+			b.returnKeyboard,
+		)
+	}
+
+	data, err := feed.EncodeOPML(opmlExportTitle, feeds, time.Now())
+	if err != nil {
+		return fmt.Errorf("encode OPML: %w", err)
+	}
+
+	if _, err = b.rateLimiter.SendDocument(ctx, &bot.SendDocumentParams{
+		ChatID: chatID,
+		Document: &models.InputFileUpload{
+			Filename: opmlExportFilename,
+			Data:     bytes.NewReader(data),
+		},
+		Caption:   fmt.Sprintf("📤 Exported %d feed\\(s\\)\\.", len(feeds)),
+		ParseMode: models.ParseModeMarkdown,
+		ReplyMarkup: &models.InlineKeyboardMarkup{
+			InlineKeyboard: b.returnKeyboard,
+		},
+	}); err != nil {
+		return fmt.Errorf("send document: %w", err)
+	}
+
+	return nil
+}
+
+func isOPMLDocument(document *models.Document) bool {
+	if document == nil {
+		return false
+	}
+
+	switch strings.ToLower(path.Ext(document.FileName)) {
+	case ".opml":
+		return true
+	default:
+		return document.MimeType == "text/x-opml" || document.MimeType == "text/x-opml+xml"
+	}
+}
+
+// handleOPMLDocument parses an uploaded OPML file and imports it in the
+// background, since validating many feeds can outlast update processing.
+func (b *Bot) handleOPMLDocument(ctx context.Context, message *models.Message) error {
+	chatID := message.Chat.ID
+	userID := message.From.ID
+
+	items, err := b.downloadOPML(ctx, message.Document)
+	if err != nil {
+		errs := []error{fmt.Errorf("download OPML: %w", err)}
+
+		sendErr := b.sendMessageWithKeyboard(
+			ctx,
+			chatID,
+			b.withIssueReportLink("❌ Couldn't read OPML file\\. Make sure it is a valid OPML subscription list\\."),
+			b.returnKeyboard,
+		)
+		if sendErr != nil {
+			errs = append(errs, fmt.Errorf("send message with keyboard: %w", sendErr))
+		}
+
+		return errors.Join(errs...)
+	}
+
+	if err = b.sendMessageWithKeyboard(
+		ctx,
+		chatID,
+		fmt.Sprintf("⏳ Importing %d feed\\(s\\)\\. I'll send a report when it's done\\.", len(items)),
+		nil,
+	); err != nil {
+		return fmt.Errorf("send message with keyboard: %w", err)
+	}
+
+	importCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), b.cfg.OPMLImportTimeout)
+
+	go func() {
+		defer cancel()
+
+		results, importErr := b.importOPMLFeeds(importCtx, userID, items)
+		if importErr != nil {
+			b.log.ErrorContext(importCtx, "Failed to import some OPML feeds",
+				"error", importErr,
+				"userID", userID,
+				"chatID", chatID)
+		}
+
+		reportCtx, cancelReport := context.WithTimeout(context.WithoutCancel(ctx), opmlImportReportTimeout)
+		defer cancelReport()
+
+		if sendErr := b.sendMessageWithKeyboard(
+			reportCtx,
+			chatID,
+			opmlImportReportText(results),
+			b.returnKeyboard,
+		); sendErr != nil {
+			b.log.ErrorContext(reportCtx, "Failed to send OPML import report",
+				"error", sendErr,
+				"userID", userID,
+				"chatID", chatID)
+		}
+	}()
+
+	return nil
+}
+
+func (b *Bot) downloadOPML(ctx context.Context, document *models.Document) ([]domain.Feed, error) {
+	if b.cfg.OPMLMaxSize > 0 && document.FileSize > b.cfg.OPMLMaxSize {
+		return nil, fmt.Errorf("file exceeds %d bytes", b.cfg.OPMLMaxSize)
+	}
+
+	file, err := b.api.GetFile(ctx, &bot.GetFileParams{FileID: document.FileID})
+	if err != nil {
+		return nil, fmt.Errorf("get file: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.api.FileDownloadLink(file), nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	defer func() {
+		if err = resp.Body.Close(); err != nil {
+			b.log.ErrorContext(ctx, "Failed to close response body",
+				"error", err,
+				"fileID", document.FileID,
+				"operation", "downloadOPML")
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %d", resp.StatusCode)
+	}
+
+	body := io.Reader(resp.Body)
+	if b.cfg.OPMLMaxSize > 0 {
+		body = io.LimitReader(resp.Body, b.cfg.OPMLMaxSize)
+	}
+
+	return feed.ParseOPML(body)
+}
+
+func (b *Bot) importOPMLFeeds(
+	ctx context.Context,
+	userID int64,
+	items []domain.Feed,
+) ([]opmlImportResult, error) {
+	var errs []error
+
+	followed := make(map[string]struct{})
+
+	userFeeds, err := b.db.GetUserFeeds(ctx, userID)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("get user feeds: %w", err))
+	}
+	for _, f := range userFeeds {
+		followed[f.URL] = struct{}{}
+	}
+
+	results := make([]opmlImportResult, 0, len(items))
+	for _, item := range items {
+		result, importErr := b.importOPMLFeed(ctx, userID, item, followed)
+		if importErr != nil {
+			errs = append(errs, fmt.Errorf("import OPML feed (URL = %s): %w", item.URL, importErr))
+		}
+
+		results = append(results, result)
+	}
+
+	return results, errors.Join(errs...)
+}
+
+func (b *Bot) importOPMLFeed(
+	ctx context.Context,
+	userID int64,
+	item domain.Feed,
+	followed map[string]struct{},
+) (opmlImportResult, error) {
+	if _, ok := followed[item.URL]; ok {
+		return opmlImportResult{feed: item, status: opmlImportAlreadyFollowed}, nil
+	}
+
+	feeds, err := b.fetcher.FindFeedsAtURL(ctx, item.URL)
+	if err != nil {
+		return opmlImportResult{feed: item, status: opmlImportInvalid}, fmt.Errorf("find feeds at URL: %w", err)
+	}
+
+	// A page linking to several feeds is left to the user, as the outline
+	// doesn't tell which of them it meant.
+	if len(feeds) > 1 {
+		return opmlImportResult{feed: item, status: opmlImportAmbiguous}, nil
+	}
+
+	found := feeds[0]
+	if _, ok := followed[found.URL]; ok {
+		return opmlImportResult{feed: found, status: opmlImportAlreadyFollowed}, nil
+	}
+
+	if err = b.db.AddFeed(ctx, userID, found.URL, found.Title); err != nil {
+		return opmlImportResult{feed: item, status: opmlImportInvalid}, fmt.Errorf("add feed: %w", err)
+	}
+
+	followed[found.URL] = struct{}{}
+
+	if err = b.keepOPMLTitle(ctx, userID, found, item.Title); err != nil {
+		err = fmt.Errorf("keep OPML title: %w", err)
+	}
+
+	return opmlImportResult{feed: found, status: opmlImportAdded}, err
+}
+
+// keepOPMLTitle sets the outline title as the custom title of an imported feed
+// when it differs from the feed's own title, so renamed feeds survive a round
+// trip through another reader or an export.
+func (b *Bot) keepOPMLTitle(ctx context.Context, userID int64, found domain.Feed, outlineTitle string) error {
+	title := strings.Join(strings.Fields(outlineTitle), " ")
+	if title == "" || title == found.Title || title == found.URL ||
+		utf8.RuneCountInString(title) > feedRenameTitleMaxLength {
+		return nil
+	}
+
+	userFeeds, err := b.db.GetUserFeeds(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get user feeds: %w", err)
+	}
+
+	for _, f := range userFeeds {
+		if f.URL != found.URL {
+			continue
+		}
+
+		if err = b.db.UpdateFeedCustomTitle(ctx, userID, f.ID, title); err != nil {
+			return fmt.Errorf("update feed custom title: %w", err)
+		}
+
+		return nil
+	}
+
+	return nil
+}
+
+func opmlImportReportText(results []opmlImportResult) string {
+	var added, alreadyFollowed, ambiguous, invalid int
+	var lines strings.Builder
+
+	for _, r := range results {
+		title := strings.TrimSpace(r.feed.Title)
+		if title == "" {
+			title = r.feed.URL
+		}
+
+		switch r.status {
+		case opmlImportAdded:
+			added++
+			fmt.Fprintf(&lines, "✅ %s\n", formatMarkdownLink(title, r.feed.URL))
+		case opmlImportAlreadyFollowed:
+			alreadyFollowed++
+			fmt.Fprintf(&lines, "☑️ %s \\(already followed\\)\n", formatMarkdownLink(title, r.feed.URL))
+		case opmlImportAmbiguous:
+			ambiguous++
+			fmt.Fprintf(
+				&lines,
+				"❔ %s \\(several feeds found, send the URL to pick one\\)\n",
+				bot.EscapeMarkdownUnescaped(r.feed.URL),
+			)
+		case opmlImportInvalid:
+			invalid++
+			fmt.Fprintf(&lines, "❌ %s \\(invalid\\)\n", bot.EscapeMarkdownUnescaped(r.feed.URL))
+		}
+	}
+
+	return fmt.Sprintf(
+		"📥 *OPML import is finished*\n\nAdded: %d\nAlready followed: %d\nAmbiguous: %d\nInvalid: %d\n\n%s",
+		added,
+		alreadyFollowed,
+		ambiguous,
+		invalid,
+		lines.String(),
+	)
+}
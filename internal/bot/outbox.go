@@ -0,0 +1,170 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"telekilogram/internal/domain"
+	"time"
+
+	"github.com/go-telegram/bot"
+)
+
+const outboxBlockedError = "bot is blocked by the user"
+
+// QueueNewPosts renders posts like SendNewPosts and stores the messages in the
+// outbox, so they survive a restart. DrainOutbox sends them.
+func (b *Bot) QueueNewPosts(ctx context.Context, chatID int64, up domain.UserPosts) error {
+	if len(up.Posts) == 0 {
+		return nil
+	}
+
+	messages := withFilteredPostsFooter(b.formatPostsAsMessages(ctx, up.Posts, up.Hidden), len(up.Filtered))
+	if err := b.db.AddOutboxMessages(ctx, chatID, messages, time.Now()); err != nil {
+		return fmt.Errorf("add outbox messages: %w", err)
+	}
+
+	return nil
+}
+
+// DrainOutbox sends due outbox messages in batches of BOT_OUTBOX_BATCH_SIZE
+// until none are due, and returns how many were sent. A failed message is
+// retried with exponential backoff and given up after BOT_OUTBOX_MAX_ATTEMPTS.
+// When the user blocked the bot, all their messages are given up at once and
+// they are marked inactive until they write to the bot again.
+func (b *Bot) DrainOutbox(ctx context.Context) (int, error) {
+	sent := 0
+
+	for {
+		messages, err := b.db.GetDueOutboxMessages(ctx, time.Now(), b.cfg.OutboxBatchSize)
+		if err != nil {
+			return sent, fmt.Errorf("get due outbox messages: %w", err)
+		}
+
+		// Later messages of a chat wait while an earlier one is retried, so
+		// digests aren't reordered.
+		failedChats := make(map[int64]struct{})
+
+		for _, message := range messages {
+			if ctx.Err() != nil {
+				return sent, ctx.Err()
+			}
+
+			if _, ok := failedChats[message.ChatID]; ok {
+				continue
+			}
+
+			ok, sendErr := b.sendOutboxMessage(ctx, message)
+			if sendErr != nil {
+				return sent, sendErr
+			}
+
+			if ok {
+				sent++
+			} else {
+				failedChats[message.ChatID] = struct{}{}
+			}
+		}
+
+		if int64(len(messages)) < b.cfg.OutboxBatchSize {
+			return sent, nil
+		}
+	}
+}
+
+// PruneOutbox removes sent and dead messages older than BOT_OUTBOX_RETENTION.
+func (b *Bot) PruneOutbox(ctx context.Context) (int64, error) {
+	before := time.Now().Add(-b.cfg.OutboxRetention)
+
+	removed, err := b.db.RemoveOutboxMessagesBefore(ctx, before)
+	if err != nil {
+		return 0, fmt.Errorf("remove outbox messages before: %w", err)
+	}
+
+	return removed, nil
+}
+
+// sendOutboxMessage sends the message and records the result. It reports
+// whether the message was sent, and returns an error only when the result
+// couldn't be recorded.
+func (b *Bot) sendOutboxMessage(ctx context.Context, message domain.OutboxMessage) (bool, error) {
+	sendErr := b.sendMessageWithKeyboard(ctx, message.ChatID, message.Text, b.returnKeyboard)
+	now := time.Now()
+
+	if sendErr == nil {
+		if err := b.db.MarkOutboxMessageSent(ctx, message.ID, now); err != nil {
+			return true, fmt.Errorf("mark outbox message sent: %w", err)
+		}
+		return true, nil
+	}
+
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+
+	if errors.Is(sendErr, bot.ErrorForbidden) {
+		return false, b.deactivateChat(ctx, message.ChatID, now)
+	}
+
+	attempts := message.Attempts + 1
+	if attempts >= b.cfg.OutboxMaxAttempts {
+		b.log.ErrorContext(ctx, "Outbox message is dead",
+			"error", sendErr,
+			"outboxMessageID", message.ID,
+			"chatID", message.ChatID,
+			"attempts", attempts)
+
+		if err := b.db.MarkOutboxMessageDead(ctx, message.ID, sendErr.Error(), now); err != nil {
+			return false, fmt.Errorf("mark outbox message dead: %w", err)
+		}
+		return false, nil
+	}
+
+	delay := outboxBackoff(attempts, b.cfg.OutboxBaseBackoff, b.cfg.OutboxMaxBackoff)
+
+	var tooManyRequests *bot.TooManyRequestsError
+	if errors.As(sendErr, &tooManyRequests) {
+		delay = max(delay, time.Duration(tooManyRequests.RetryAfter)*time.Second)
+	}
+
+	b.log.WarnContext(ctx, "Failed to send outbox message",
+		"error", sendErr,
+		"outboxMessageID", message.ID,
+		"chatID", message.ChatID,
+		"attempts", attempts,
+		"retryIn", delay)
+
+	if err := b.db.RetryOutboxMessage(ctx, message.ID, sendErr.Error(), now.Add(delay), now); err != nil {
+		return false, fmt.Errorf("retry outbox message: %w", err)
+	}
+
+	return false, nil
+}
+
+func (b *Bot) deactivateChat(ctx context.Context, chatID int64, now time.Time) error {
+	dead, err := b.db.MarkChatOutboxMessagesDead(ctx, chatID, outboxBlockedError, now)
+	if err != nil {
+		return fmt.Errorf("mark chat outbox messages dead: %w", err)
+	}
+
+	if err = b.db.MarkUserInactive(ctx, chatID, now); err != nil {
+		return fmt.Errorf("mark user inactive: %w", err)
+	}
+
+	b.log.InfoContext(ctx, "User blocked the bot and is marked inactive",
+		"chatID", chatID,
+		"deadOutboxMessages", dead)
+
+	return nil
+}
+
+// outboxBackoff returns the delay before the next attempt after the given
+// number of failed ones: base, then doubled each time up to maxDelay.
+func outboxBackoff(attempts int64, base time.Duration, maxDelay time.Duration) time.Duration {
+	delay := base
+	for i := int64(1); i < attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+
+	return min(delay, maxDelay)
+}
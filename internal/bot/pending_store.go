@@ -0,0 +1,89 @@
+package bot
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	pendingTTL        = time.Hour
+	pendingTokenBytes = 6
+)
+
+type pendingEntry[T any] struct {
+	userID    int64
+	value     T
+	expiresAt time.Time
+}
+
+// pendingStore keeps values between a message and the inline keyboard answer
+// that completes it, since callback data is limited to 64 bytes.
+type pendingStore[T any] struct {
+	mu      sync.Mutex
+	entries map[string]pendingEntry[T]
+	ttl     time.Duration
+	now     func() time.Time
+}
+
+func newPendingStore[T any](ttl time.Duration) *pendingStore[T] {
+	return &pendingStore[T]{
+		entries: make(map[string]pendingEntry[T]),
+		ttl:     ttl,
+		now:     time.Now,
+	}
+}
+
+func (s *pendingStore[T]) put(userID int64, value T) (string, error) {
+	tokenBytes := make([]byte, pendingTokenBytes)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", fmt.Errorf("read random bytes: %w", err)
+	}
+
+	token := hex.EncodeToString(tokenBytes)
+	s.set(token, userID, value)
+
+	return token, nil
+}
+
+// set stores value under a known token, replacing the previous one.
+func (s *pendingStore[T]) set(token string, userID int64, value T) {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, entry := range s.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(s.entries, k)
+		}
+	}
+
+	s.entries[token] = pendingEntry[T]{
+		userID:    userID,
+		value:     value,
+		expiresAt: now.Add(s.ttl),
+	}
+}
+
+func (s *pendingStore[T]) get(token string, userID int64) (T, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[token]
+	if !ok || entry.userID != userID || !s.now().Before(entry.expiresAt) {
+		var zero T
+		return zero, false
+	}
+
+	return entry.value, true
+}
+
+func (s *pendingStore[T]) remove(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, token)
+}
@@ -7,26 +7,38 @@ import (
 	"fmt"
 	"maps"
 	"slices"
+	"strconv"
 	"strings"
 	"telekilogram/internal/domain"
 	"unicode/utf8"
+
+	"github.com/go-telegram/bot"
 )
 
-const telegramMessageMaxLength = 4096
+const (
+	telegramMessageMaxLength = 4096
+
+	viewsPerThousand = 1_000
+	viewsPerMillion  = 1_000_000
+
+	// replyToMaxLength keeps the quoted post a Telegram post replies to short.
+	replyToMaxLength = 80
+)
 
 type feedGroupKey struct {
-	ID    int64
-	title string
-	URL   string
+	ID       int64
+	position int64
+	title    string
+	URL      string
 }
 
-func (b *Bot) SendNewPosts(ctx context.Context, chatID int64, posts []domain.Post) error {
-	if len(posts) == 0 {
+func (b *Bot) SendNewPosts(ctx context.Context, chatID int64, up domain.UserPosts) error {
+	if len(up.Posts) == 0 {
 		return nil
 	}
 
 	var errs []error
-	messages := b.formatPostsAsMessages(ctx, posts)
+	messages := withFilteredPostsFooter(b.formatPostsAsMessages(ctx, up.Posts, up.Hidden), len(up.Filtered))
 
 	for _, message := range messages {
 		if err := b.sendMessageWithKeyboard(ctx, chatID, message, b.returnKeyboard); err != nil {
@@ -37,7 +49,30 @@ func (b *Bot) SendNewPosts(ctx context.Context, chatID int64, posts []domain.Pos
 	return errors.Join(errs...)
 }
 
-func (b *Bot) formatPostsAsMessages(ctx context.Context, posts []domain.Post) []string {
+// SendRealtimePost sends a single post of a feed in real-time delivery mode.
+func (b *Bot) SendRealtimePost(ctx context.Context, chatID int64, post domain.Post) error {
+	normalized, ok := b.normalizePost(ctx, post)
+	if !ok {
+		return nil
+	}
+
+	text := fmt.Sprintf(
+		"⚡ *%s*\n\n%s",
+		formatMarkdownLink(normalized.FeedTitle, normalized.FeedURL),
+		postBulletPoint(normalized),
+	)
+
+	if err := b.sendMessageWithKeyboard(ctx, chatID, text, b.returnKeyboard); err != nil {
+		return fmt.Errorf("send message with keyboard: %w", err)
+	}
+
+	return nil
+}
+
+// formatPostsAsMessages groups posts by feed after collapsing copies of a story
+// in several feeds, ending a feed with a link to it when some of its posts are
+// hidden by the feed's top N setting.
+func (b *Bot) formatPostsAsMessages(ctx context.Context, posts []domain.Post, hidden []domain.Post) []string {
 	var messages []string
 	var currentMessage strings.Builder
 
@@ -47,7 +82,7 @@ func (b *Bot) formatPostsAsMessages(ctx context.Context, posts []domain.Post) []
 	currentLen := currentHeaderLen
 	hasContent := false
 
-	feedGroups := make(map[feedGroupKey][]domain.Post)
+	normalizedPosts := make([]domain.Post, 0, len(posts))
 
 	for _, post := range posts {
 		normalized, ok := b.normalizePost(ctx, post)
@@ -55,29 +90,76 @@ func (b *Bot) formatPostsAsMessages(ctx context.Context, posts []domain.Post) []
 			continue
 		}
 
+		normalizedPosts = append(normalizedPosts, normalized)
+	}
+
+	feedGroups := make(map[feedGroupKey][]postCluster)
+	shownPosts := make([]domain.Post, 0, len(normalizedPosts))
+
+	for _, cluster := range clusterPosts(normalizedPosts) {
+		shownPosts = append(shownPosts, cluster.post)
+
 		key := feedGroupKey{
-			ID:    normalized.FeedID,
-			title: normalized.FeedTitle,
-			URL:   normalized.FeedURL,
+			ID:       cluster.post.FeedID,
+			position: cluster.post.FeedPosition,
+			title:    cluster.post.FeedTitle,
+			URL:      cluster.post.FeedURL,
+		}
+
+		feedGroups[key] = append(feedGroups[key], cluster)
+	}
+
+	if overview := digestOverviewBlock(b.digestOverview(ctx, shownPosts)); overview != "" {
+		currentMessage.WriteString(overview)
+		currentLen += utf8.RuneCountInString(overview)
+		hasContent = true
+	}
+
+	groupedFeeds := make(map[int64]struct{}, len(feedGroups))
+	for key := range feedGroups {
+		groupedFeeds[key.ID] = struct{}{}
+	}
+
+	// A feed whose shown posts all collapsed into other feeds' stories still
+	// gets its header and the link to its hidden posts.
+	hiddenCounts := make(map[int64]int)
+	for _, post := range hidden {
+		hiddenCounts[post.FeedID]++
+
+		if _, ok := groupedFeeds[post.FeedID]; ok {
+			continue
+		}
+
+		normalized, ok := b.normalizePost(ctx, post)
+		if !ok {
+			continue
 		}
 
-		feedGroups[key] = append(feedGroups[key], normalized)
+		groupedFeeds[post.FeedID] = struct{}{}
+		feedGroups[feedGroupKey{
+			ID:       normalized.FeedID,
+			position: normalized.FeedPosition,
+			title:    normalized.FeedTitle,
+			URL:      normalized.FeedURL,
+		}] = nil
 	}
 
 	feedGroupKeySeq := maps.Keys(feedGroups)
 	feedGroupKeys := slices.SortedFunc(
 		feedGroupKeySeq,
-		func(a, b feedGroupKey) int { return cmp.Compare(a.ID, b.ID) },
+		func(a, b feedGroupKey) int {
+			return cmp.Or(cmp.Compare(a.position, b.position), cmp.Compare(a.ID, b.ID))
+		},
 	)
 
 	for _, key := range feedGroupKeys {
-		feedPosts := feedGroups[key]
+		feedClusters := feedGroups[key]
 
 		feedHeader := fmt.Sprintf("📌 *%s*\n\n", formatMarkdownLink(key.title, key.URL))
-		firstBulletPoint := fmt.Sprintf(
-			"– %s\n\n",
-			formatMarkdownLink(feedPosts[0].Title, feedPosts[0].URL),
-		)
+		firstBulletPoint := hiddenPostsPoint(hiddenCounts[key.ID], key.URL)
+		if len(feedClusters) > 0 {
+			firstBulletPoint = clusterBulletPoint(feedClusters[0])
+		}
 
 		if hasContent && currentLen+
 			utf8.RuneCountInString(feedHeader)+
@@ -94,8 +176,8 @@ func (b *Bot) formatPostsAsMessages(ctx context.Context, posts []domain.Post) []
 		currentMessage.WriteString(feedHeader)
 		currentLen += utf8.RuneCountInString(feedHeader)
 
-		for _, post := range feedPosts {
-			bulletPoint := fmt.Sprintf("– %s\n\n", formatMarkdownLink(post.Title, post.URL))
+		for _, cluster := range feedClusters {
+			bulletPoint := clusterBulletPoint(cluster)
 
 			if hasContent && currentLen+utf8.RuneCountInString(bulletPoint) > telegramMessageMaxLength {
 				messages = append(messages, currentMessage.String())
@@ -111,6 +193,24 @@ func (b *Bot) formatPostsAsMessages(ctx context.Context, posts []domain.Post) []
 			currentLen += utf8.RuneCountInString(bulletPoint)
 			hasContent = true
 		}
+
+		if count := hiddenCounts[key.ID]; count > 0 {
+			morePoint := hiddenPostsPoint(count, key.URL)
+
+			if currentLen+utf8.RuneCountInString(morePoint) > telegramMessageMaxLength {
+				messages = append(messages, currentMessage.String())
+				currentMessage.Reset()
+				currentHeader = "📰 *New posts \\(continue\\)*\n\n"
+				currentMessage.WriteString(currentHeader)
+				currentMessage.WriteString(feedHeader)
+				currentHeaderLen = utf8.RuneCountInString(currentHeader)
+				currentLen = currentHeaderLen + utf8.RuneCountInString(feedHeader)
+			}
+
+			currentMessage.WriteString(morePoint)
+			currentLen += utf8.RuneCountInString(morePoint)
+			hasContent = true
+		}
 	}
 
 	if hasContent {
@@ -119,6 +219,125 @@ func (b *Bot) formatPostsAsMessages(ctx context.Context, posts []domain.Post) []
 	return messages
 }
 
+// postBulletPoint formats a post as a list item, adding the feed item summary
+// in italics under the title when there is one. Telegram posts get a media icon,
+// their forward origin, views, and edit mark, and the start of the post they
+// reply to.
+func postBulletPoint(post domain.Post) string {
+	link := formatMarkdownLink(post.Title, post.URL)
+	if icon := mediaIcon(post.Telegram.MediaKind); icon != "" {
+		link = icon + " " + link
+	}
+	link += telegramPostMetaSuffix(post.Telegram)
+
+	lines := []string{"– " + link}
+
+	if replyTo := post.Telegram.ReplyTo; replyTo != "" {
+		lines = append(lines, "💬 "+bot.EscapeMarkdownUnescaped(truncateRunes(replyTo, replyToMaxLength)))
+	}
+
+	if summary := strings.Join(strings.Fields(post.Summary), " "); summary != "" {
+		lines = append(lines, "_"+bot.EscapeMarkdownUnescaped(summary)+"_")
+	}
+
+	return strings.Join(lines, "\n") + "\n\n"
+}
+
+// clusterBulletPoint formats the shown post of a cluster, crediting the other
+// feeds that have the same story.
+func clusterBulletPoint(cluster postCluster) string {
+	bulletPoint := postBulletPoint(cluster.post)
+	if len(cluster.alsoIn) == 0 {
+		return bulletPoint
+	}
+
+	links := make([]string, 0, len(cluster.alsoIn))
+	for _, post := range cluster.alsoIn {
+		links = append(links, formatMarkdownLink(post.FeedTitle, post.URL))
+	}
+
+	return strings.TrimSuffix(bulletPoint, "\n") + "🔁 also in: " + strings.Join(links, ", ") + "\n\n"
+}
+
+// hiddenPostsPoint links the feed for posts left out of the digest.
+func hiddenPostsPoint(count int, feedURL string) string {
+	return fmt.Sprintf("– %s\n\n", formatMarkdownLink(fmt.Sprintf("…and %d more", count), feedURL))
+}
+
+func mediaIcon(kind domain.MediaKind) string {
+	switch kind {
+	case domain.MediaKindPhoto:
+		return "📷"
+	case domain.MediaKindVideo:
+		return "🎬"
+	case domain.MediaKindDocument:
+		return "📎"
+	case domain.MediaKindPoll:
+		return "🗳"
+	case domain.MediaKindVoice:
+		return "🎙"
+	default:
+		return ""
+	}
+}
+
+func telegramPostMetaSuffix(meta domain.TelegramPostMeta) string {
+	var parts []string
+
+	if meta.ForwardedFrom != "" {
+		parts = append(parts, "↪️ "+meta.ForwardedFrom)
+	}
+	if meta.Views > 0 {
+		parts = append(parts, "👁 "+formatViews(meta.Views))
+	}
+	if meta.Edited {
+		parts = append(parts, "✏️")
+	}
+
+	if len(parts) == 0 {
+		return ""
+	}
+
+	return " " + bot.EscapeMarkdownUnescaped(strings.Join(parts, " · "))
+}
+
+// formatViews shortens view counts like Telegram does: 987, 1.2K, 3M.
+func formatViews(views int64) string {
+	switch {
+	case views >= viewsPerMillion:
+		return trimZeroFraction(float64(views)/viewsPerMillion) + "M"
+	case views >= viewsPerThousand:
+		return trimZeroFraction(float64(views)/viewsPerThousand) + "K"
+	default:
+		return strconv.FormatInt(views, 10)
+	}
+}
+
+func trimZeroFraction(value float64) string {
+	return strings.TrimSuffix(strconv.FormatFloat(value, 'f', 1, 64), ".0")
+}
+
+func filteredPostsText(filteredCount int) string {
+	return fmt.Sprintf("🔇 %d post\\(s\\) filtered out by your /filter rules\\.", filteredCount)
+}
+
+func withFilteredPostsFooter(messages []string, filteredCount int) []string {
+	if filteredCount <= 0 || len(messages) == 0 {
+		return messages
+	}
+
+	footer := filteredPostsText(filteredCount)
+	last := messages[len(messages)-1]
+
+	if utf8.RuneCountInString(last)+utf8.RuneCountInString(footer) > telegramMessageMaxLength {
+		return append(messages, footer)
+	}
+
+	messages[len(messages)-1] = last + footer
+
+	return messages
+}
+
 func (b *Bot) normalizePost(ctx context.Context, post domain.Post) (domain.Post, bool) {
 	normalized := post
 
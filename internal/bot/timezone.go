@@ -0,0 +1,305 @@
+package bot
+
+import (
+	"cmp"
+	"context"
+	_ "embed" // Required by go:embed.
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"telekilogram/internal/domain"
+	"time"
+
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+)
+
+const (
+	timezoneCandidateCount         = 4
+	settingsTimezoneCallbackPrefix = "settings_timezone_set_"
+	timezoneUsageText              = `🌍 *Time zone*
+
+Send your IANA time zone, e\.g\. /timezone Europe/Berlin, or share your location below to pick one nearby\.`
+	timezoneCandidatesText = `🌍 Which of these time zones is yours?
+
+If none is, send its name, e\.g\. /timezone Europe/Berlin\.`
+
+	// zoneTabMinFields are the country codes, coordinates, and name of a zone.
+	zoneTabMinFields      = 3
+	latitudeDegreeDigits  = 2
+	longitudeDegreeDigits = 3
+	iso6709FieldDigits    = 2
+	minutesPerDegree      = 60
+	secondsPerDegree      = 3600
+	degreesPerHalfTurn    = 180
+)
+
+// zoneTab is tzdb's zone1970.tab, which lists the principal location of each
+// time zone.
+//
+//go:embed zone1970.tab
+var zoneTab string
+
+// timezoneLocation is the principal location of a time zone.
+type timezoneLocation struct {
+	name      string
+	latitude  float64
+	longitude float64
+}
+
+func (b *Bot) handleTimezoneCommand(ctx context.Context, text string, chatID int64, userID int64) error {
+	name := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(text), "/timezone"))
+	if name == "" {
+		return b.sendTimezonePrompt(ctx, chatID)
+	}
+
+	loc, err := loadUserTimezone(name)
+	if err != nil {
+		errs := []error{fmt.Errorf("load user timezone: %w", err)}
+
+		sendErr := b.sendMessageWithKeyboard(
+			ctx,
+			chatID,
+			fmt.Sprintf("❌ Unknown time zone %s\\.\n\n%s", bot.EscapeMarkdownUnescaped(name), timezoneUsageText),
+			b.returnKeyboard,
+		)
+		if sendErr != nil {
+			errs = append(errs, fmt.Errorf("send message with keyboard: %w", sendErr))
+		}
+
+		return errors.Join(errs...)
+	}
+
+	return b.updateUserTimezone(ctx, chatID, userID, loc.String())
+}
+
+// handleLocation offers the time zones nearest to a shared location, as the
+// user has to confirm the one their region follows.
+func (b *Bot) handleLocation(ctx context.Context, location *models.Location, chatID int64) error {
+	candidates := timezoneCandidates(location.Latitude, location.Longitude)
+	if len(candidates) == 0 {
+		return b.sendTimezonePrompt(ctx, chatID)
+	}
+
+	return b.sendMessageWithKeyboard(
+		ctx,
+		chatID,
+		timezoneCandidatesText,
+		getTimezoneCandidatesKeyboard(candidates, time.Now()),
+	)
+}
+
+func (b *Bot) handleTimezoneQuery(ctx context.Context, name string, callback *models.CallbackQuery) error {
+	message := callbackMessage(callback)
+	if message == nil {
+		return errors.New("callback query has no accessible message")
+	}
+
+	loc, err := loadUserTimezone(name)
+	if err != nil {
+		return b.answerCallbackError(
+			ctx,
+			callback,
+			"❌ Couldn't parse provided value. Please try again.",
+			fmt.Errorf("load user timezone: %w", err),
+		)
+	}
+
+	return b.withEmptyCallbackAnswer(ctx, callback, "update time zone", func() error {
+		return b.updateUserTimezone(ctx, message.Chat.ID, callback.From.ID, loc.String())
+	})
+}
+
+func (b *Bot) sendTimezonePrompt(ctx context.Context, chatID int64) error {
+	if _, err := b.rateLimiter.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:    chatID,
+		Text:      timezoneUsageText,
+		ParseMode: models.ParseModeMarkdown,
+		ReplyMarkup: &models.ReplyKeyboardMarkup{
+			Keyboard: [][]models.KeyboardButton{
+				{{Text: "📍 Share location", RequestLocation: true}},
+			},
+			ResizeKeyboard:  true,
+			OneTimeKeyboard: true,
+		},
+	}); err != nil {
+		return fmt.Errorf("send message: %w", err)
+	}
+
+	return nil
+}
+
+func (b *Bot) updateUserTimezone(ctx context.Context, chatID int64, userID int64, timezone string) error {
+	settings, err := b.db.GetUserSettingsWithDefault(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get user settings with default: %w", err)
+	}
+
+	settings.Timezone = timezone
+
+	if err = b.db.UpsertUserSettings(ctx, settings); err != nil {
+		errs := []error{fmt.Errorf("upsert user settings: %w", err)}
+
+		sendErr := b.sendMessageWithKeyboard(
+			ctx,
+			chatID,
+			b.withIssueReportLink("❌ Couldn't update settings\\. Please try again\\."),
+			b.returnKeyboard,
+		)
+		if sendErr != nil {
+			errs = append(errs, fmt.Errorf("send message with keyboard: %w", sendErr))
+		}
+
+		return errors.Join(errs...)
+	}
+
+	// A plain message is needed to hide the location reply keyboard.
+	if _, err = b.rateLimiter.SendMessage(ctx, &bot.SendMessageParams{
+		ChatID:      chatID,
+		Text:        fmt.Sprintf("✅ Time zone is set to %s\\.", bot.EscapeMarkdownUnescaped(timezone)),
+		ParseMode:   models.ParseModeMarkdown,
+		ReplyMarkup: &models.ReplyKeyboardRemove{RemoveKeyboard: true},
+	}); err != nil {
+		return fmt.Errorf("send message: %w", err)
+	}
+
+	return b.handleSettingsCommand(ctx, chatID, userID)
+}
+
+// loadUserTimezone loads an IANA time zone, rejecting "Local" because the bot
+// host zone means nothing to users.
+func loadUserTimezone(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || strings.EqualFold(name, "Local") {
+		return nil, fmt.Errorf("invalid time zone: %q", name)
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("load location: %w", err)
+	}
+
+	return loc, nil
+}
+
+// timezoneCandidates returns the time zones whose principal locations are
+// nearest to the given coordinates, nearest first. Zone borders need a
+// geographic database, so the user picks among them.
+func timezoneCandidates(latitude, longitude float64) []string {
+	locations := timezoneLocations()
+
+	slices.SortFunc(locations, func(a, b timezoneLocation) int {
+		return cmp.Compare(
+			haversine(latitude, longitude, a.latitude, a.longitude),
+			haversine(latitude, longitude, b.latitude, b.longitude),
+		)
+	})
+
+	candidates := make([]string, 0, timezoneCandidateCount)
+	for _, location := range locations {
+		if len(candidates) == timezoneCandidateCount {
+			break
+		}
+
+		if _, err := loadUserTimezone(location.name); err == nil {
+			candidates = append(candidates, location.name)
+		}
+	}
+
+	return candidates
+}
+
+func timezoneLocations() []timezoneLocation {
+	var locations []timezoneLocation
+
+	for line := range strings.Lines(zoneTab) {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(strings.TrimSpace(line), "\t")
+		if len(fields) < zoneTabMinFields {
+			continue
+		}
+
+		latitude, longitude, ok := parseISO6709(fields[1])
+		if !ok {
+			continue
+		}
+
+		locations = append(locations, timezoneLocation{
+			name:      fields[2],
+			latitude:  latitude,
+			longitude: longitude,
+		})
+	}
+
+	return locations
+}
+
+// parseISO6709 parses coordinates like +4024-00341 or +404251-0034104 into
+// degrees.
+func parseISO6709(coordinates string) (float64, float64, bool) {
+	split := strings.IndexAny(coordinates[min(1, len(coordinates)):], "+-") + 1
+	if split <= 0 {
+		return 0, 0, false
+	}
+
+	latitude, latOK := parseISO6709Degrees(coordinates[:split], latitudeDegreeDigits)
+	longitude, lonOK := parseISO6709Degrees(coordinates[split:], longitudeDegreeDigits)
+
+	return latitude, longitude, latOK && lonOK
+}
+
+// parseISO6709Degrees parses a signed ±DDMM or ±DDMMSS value, with degreeDigits
+// digits of degrees.
+func parseISO6709Degrees(value string, degreeDigits int) (float64, bool) {
+	if value == "" || (value[0] != '+' && value[0] != '-') {
+		return 0, false
+	}
+
+	digits := value[1:]
+	switch len(digits) {
+	case degreeDigits + iso6709FieldDigits:
+		digits += "00"
+	case degreeDigits + 2*iso6709FieldDigits:
+	default:
+		return 0, false
+	}
+
+	degrees, degreesErr := strconv.Atoi(digits[:degreeDigits])
+	minutes, minutesErr := strconv.Atoi(digits[degreeDigits : degreeDigits+iso6709FieldDigits])
+	seconds, secondsErr := strconv.Atoi(digits[degreeDigits+iso6709FieldDigits:])
+	if errors.Join(degreesErr, minutesErr, secondsErr) != nil {
+		return 0, false
+	}
+
+	result := float64(degrees) + float64(minutes)/minutesPerDegree + float64(seconds)/secondsPerDegree
+	if value[0] == '-' {
+		result = -result
+	}
+
+	return result, true
+}
+
+// haversine returns the haversine of the central angle between two points,
+// which grows with their distance.
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1, phi2 := lat1*math.Pi/degreesPerHalfTurn, lat2*math.Pi/degreesPerHalfTurn
+	sinHalfDPhi := math.Sin((phi2 - phi1) / 2)
+	sinHalfDLambda := math.Sin((lon2 - lon1) * math.Pi / degreesPerHalfTurn / 2)
+
+	return sinHalfDPhi*sinHalfDPhi + math.Cos(phi1)*math.Cos(phi2)*sinHalfDLambda*sinHalfDLambda
+}
+
+func userLocation(settings *domain.UserSettings) *time.Location {
+	loc, err := loadUserTimezone(settings.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}
@@ -0,0 +1,147 @@
+package bot
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"telekilogram/internal/config"
+	"time"
+
+	"github.com/go-telegram/bot"
+)
+
+// Modes accepted by BOT_MODE.
+const (
+	ModePolling = "polling"
+	ModeWebhook = "webhook"
+)
+
+const (
+	webhookSecretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+	webhookReadHeaderTimeout = 10 * time.Second
+)
+
+func validateModeConfig(cfg config.BotConfig) error {
+	switch cfg.Mode {
+	case ModePolling:
+		return nil
+	case ModeWebhook:
+		if strings.TrimSpace(cfg.WebhookURL) == "" {
+			return errors.New("webhook URL is empty")
+		}
+		if strings.TrimSpace(cfg.WebhookSecretToken) == "" {
+			return errors.New("webhook secret token is empty")
+		}
+		if !strings.HasPrefix(cfg.WebhookPath, "/") {
+			return fmt.Errorf("webhook path must start with /: %q", cfg.WebhookPath)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown mode: %q", cfg.Mode)
+	}
+}
+
+// startPolling deletes any webhook before long polling, since Telegram rejects
+// getUpdates while one is set, e.g. after a webhook-mode process crashed or
+// BOT_MODE was switched. Pending updates are kept.
+func (b *Bot) startPolling(ctx context.Context) error {
+	if _, err := b.api.DeleteWebhook(ctx, &bot.DeleteWebhookParams{}); err != nil {
+		return fmt.Errorf("delete webhook: %w", err)
+	}
+
+	go b.api.Start(ctx)
+
+	return nil
+}
+
+// startWebhook registers the webhook and serves Telegram updates on
+// BOT_WEBHOOK_LISTEN_ADDR until ctx is done. The listener is opened before
+// returning, so a busy address is reported to the caller.
+func (b *Bot) startWebhook(ctx context.Context) error {
+	listener, err := (&net.ListenConfig{}).Listen(ctx, "tcp", b.cfg.WebhookListenAddr)
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+
+	if _, err = b.api.SetWebhook(ctx, &bot.SetWebhookParams{
+		URL:            b.cfg.WebhookURL,
+		AllowedUpdates: allowedUpdates(),
+		SecretToken:    b.cfg.WebhookSecretToken,
+	}); err != nil {
+		return errors.Join(fmt.Errorf("set webhook: %w", err), listener.Close())
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(b.cfg.WebhookPath, b.webhookHandler())
+
+	b.webhookServer = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: webhookReadHeaderTimeout,
+	}
+
+	go func() {
+		if serveErr := b.webhookServer.Serve(listener); serveErr != nil &&
+			!errors.Is(serveErr, http.ErrServerClosed) {
+			b.log.ErrorContext(ctx, "Webhook server is stopped with error",
+				"error", serveErr,
+				"listenAddr", b.cfg.WebhookListenAddr)
+		}
+	}()
+
+	go b.api.StartWebhook(ctx)
+
+	return nil
+}
+
+// stopWebhook stops the webhook server and deletes the webhook when
+// BOT_WEBHOOK_DELETE_ON_SHUTDOWN is set.
+func (b *Bot) stopWebhook() error {
+	if b.webhookServer == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), b.cfg.WebhookShutdownTimeout)
+	defer cancel()
+
+	var errs []error
+
+	if err := b.webhookServer.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("shutdown webhook server: %w", err))
+	}
+
+	if b.cfg.WebhookDeleteOnShutdown {
+		if _, err := b.api.DeleteWebhook(ctx, &bot.DeleteWebhookParams{}); err != nil {
+			errs = append(errs, fmt.Errorf("delete webhook: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// webhookHandler rejects requests without the secret token set in setWebhook
+// and passes the rest to the library, which queues them for handleUpdate.
+func (b *Bot) webhookHandler() http.Handler {
+	updates := b.api.WebhookHandler()
+	secretToken := []byte(b.cfg.WebhookSecretToken)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get(webhookSecretTokenHeader)), secretToken) != 1 {
+			b.log.WarnContext(r.Context(), "Webhook request has invalid secret token",
+				"remoteAddr", r.RemoteAddr)
+
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		updates(w, r)
+	})
+}
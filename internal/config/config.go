@@ -7,16 +7,19 @@ import (
 )
 
 type Config struct {
-	Token        string            `env:"TOKEN,required,notEmpty"`
-	AllowedUsers []int64           `env:"ALLOWED_USERS"`
-	DBPath       string            `env:"DB_PATH"                 envDefault:"db.sqlite"`
-	OpenAIAPIKey string            `env:"OPENAI_API_KEY"`
-	OpenAI       OpenAIConfig      `                                                     envPrefix:"OPENAI_"`
-	Scheduler    SchedulerConfig   `                                                     envPrefix:"SCHEDULER_"`
-	RateLimiter  RateLimiterConfig `                                                     envPrefix:"RATE_LIMITER_"`
-	Feed         FeedConfig        `                                                     envPrefix:"FEED_"`
-	Telegram     TelegramConfig    `                                                     envPrefix:"TELEGRAM_"`
-	Bot          BotConfig         `                                                     envPrefix:"BOT_"`
+	Token          string               `env:"TOKEN,required,notEmpty"`
+	AllowedUsers   []int64              `env:"ALLOWED_USERS"`
+	DBPath         string               `env:"DB_PATH"                 envDefault:"db.sqlite"`
+	OpenAIAPIKey   string               `env:"OPENAI_API_KEY"`
+	OpenAI         OpenAIConfig         `                                                     envPrefix:"OPENAI_"`
+	Summarizer     SummarizerConfig     `                                                     envPrefix:"SUMMARIZER_"`
+	DigestOverview DigestOverviewConfig `                                                     envPrefix:"DIGEST_OVERVIEW_"`
+	Scheduler      SchedulerConfig      `                                                     envPrefix:"SCHEDULER_"`
+	RateLimiter    RateLimiterConfig    `                                                     envPrefix:"RATE_LIMITER_"`
+	Feed           FeedConfig           `                                                     envPrefix:"FEED_"`
+	Telegram       TelegramConfig       `                                                     envPrefix:"TELEGRAM_"`
+	Bot            BotConfig            `                                                     envPrefix:"BOT_"`
+	Metrics        MetricsConfig        `                                                     envPrefix:"METRICS_"`
 }
 
 type OpenAIConfig struct {
@@ -29,32 +32,91 @@ type OpenAIConfig struct {
 	ReasoningEffort             string `env:"REASONING_EFFORT"                envDefault:"low"`
 }
 
+// SummarizerConfig selects the summary provider. OpenAIConfig prompt and
+// output token settings apply to every provider.
+type SummarizerConfig struct {
+	Provider string        `env:"PROVIDER" envDefault:"openai"`
+	BaseURL  string        `env:"BASE_URL"`
+	Model    string        `env:"MODEL"`
+	Timeout  time.Duration `env:"TIMEOUT"  envDefault:"60s"`
+}
+
+// DigestOverviewConfig controls the AI overview prepended to digests. It uses
+// the SUMMARIZER_ provider with its own prompt and output token limits.
+type DigestOverviewConfig struct {
+	Enabled              bool   `env:"ENABLED"                 envDefault:"false"`
+	MinPosts             int    `env:"MIN_POSTS"               envDefault:"10"`
+	MaxInputChars        int    `env:"MAX_INPUT_CHARS"         envDefault:"20000"`
+	MaxChars             int    `env:"MAX_CHARS"               envDefault:"1500"`
+	BaseMaxOutputTokens  int64  `env:"BASE_MAX_OUTPUT_TOKENS"  envDefault:"1024"`
+	LimitMaxOutputTokens int64  `env:"LIMIT_MAX_OUTPUT_TOKENS" envDefault:"4096"`
+	SystemPrompt         string `env:"SYSTEM_PROMPT"           envDefault:"Write a short overview of what happened based on the digest posts below.\n\nRequirements:\n- Group related posts into at most 5 topics, most important first.\n- Write each topic on its own line as a short topic name, a colon, and one sentence.\n- Preserve essential names, numbers, and dates.\n- Stay under 120 words.\n- Use a neutral tone and plain text without Markdown, emojis, or links.\n- Write in the language most posts use."`
+}
+
 type SchedulerConfig struct {
-	CheckHourFeedsTimeout time.Duration `env:"CHECK_HOUR_FEEDS_TIMEOUT" envDefault:"15m"`
+	CheckHourFeedsTimeout     time.Duration `env:"CHECK_HOUR_FEEDS_TIMEOUT"     envDefault:"15m"`
+	RealtimePollInterval      time.Duration `env:"REALTIME_POLL_INTERVAL"       envDefault:"5m"`
+	CheckRealtimeFeedsTimeout time.Duration `env:"CHECK_REALTIME_FEEDS_TIMEOUT" envDefault:"4m"`
+	OutboxPollInterval        time.Duration `env:"OUTBOX_POLL_INTERVAL"         envDefault:"30s"`
+	DrainOutboxTimeout        time.Duration `env:"DRAIN_OUTBOX_TIMEOUT"         envDefault:"10m"`
+	CatchUpMaxLookback        time.Duration `env:"CATCH_UP_MAX_LOOKBACK"        envDefault:"24h"`
 }
 
 type RateLimiterConfig struct {
-	PrivateChatRate time.Duration `env:"PRIVATE_CHAT_RATE" envDefault:"1s"`
-	GroupChatRate   time.Duration `env:"GROUP_CHAT_RATE"   envDefault:"3s"`
-	QueueSize       int           `env:"QUEUE_SIZE"        envDefault:"1000"`
+	PrivateChatRate      time.Duration `env:"PRIVATE_CHAT_RATE"      envDefault:"1s"`
+	GroupChatRate        time.Duration `env:"GROUP_CHAT_RATE"        envDefault:"3s"`
+	QueueSize            int           `env:"QUEUE_SIZE"             envDefault:"1000"`
+	GlobalRate           float64       `env:"GLOBAL_RATE"            envDefault:"30"`
+	GlobalBurst          int           `env:"GLOBAL_BURST"           envDefault:"10"`
+	MaxRetries           int           `env:"MAX_RETRIES"            envDefault:"3"`
+	MaxInteractiveStreak int           `env:"MAX_INTERACTIVE_STREAK" envDefault:"5"`
 }
 
 type FeedConfig struct {
 	TelegramSummaryCacheMaxEntries       int           `env:"TELEGRAM_SUMMARY_CACHE_MAX_ENTRIES"        envDefault:"1024"`
 	TelegramSummariesMaxParallelism      int           `env:"TELEGRAM_SUMMARIES_MAX_PARALLELISM"        envDefault:"4"`
 	ParseFeedGracePeriod                 time.Duration `env:"PARSE_FEED_GRACE_PERIOD"                   envDefault:"10m"`
+	PostLookbackPeriod                   time.Duration `env:"POST_LOOKBACK_PERIOD"                      envDefault:"48h"`
+	DeliveredPostsRetention              time.Duration `env:"DELIVERED_POSTS_RETENTION"                 envDefault:"720h"`
 	FallbackTelegramSummaryMaxChars      int           `env:"FALLBACK_TELEGRAM_SUMMARY_MAX_CHARS"       envDefault:"200"`
 	FetchFeedsMaxConcurrencyGrowthFactor int           `env:"FETCH_FEEDS_MAX_CONCURRENCY_GROWTH_FACTOR" envDefault:"10"`
+	UserAgent                            string        `env:"USER_AGENT"                                envDefault:"Telekilogram/1.0 (+https://github.com/hu553in/telekilogram)"`
+	ClientTimeout                        time.Duration `env:"CLIENT_TIMEOUT"                            envDefault:"20s"`
+	MaxBodySize                          int64         `env:"MAX_BODY_SIZE"                             envDefault:"10485760"`
+	ParsedFeedCacheMaxEntries            int           `env:"PARSED_FEED_CACHE_MAX_ENTRIES"             envDefault:"1024"`
 }
 
 type TelegramConfig struct {
 	UserAgent     string        `env:"USER_AGENT"     envDefault:"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/127.0.0.0 Safari/537.36"`
 	ClientTimeout time.Duration `env:"CLIENT_TIMEOUT" envDefault:"20s"`
+	MaxPages      int           `env:"MAX_PAGES"      envDefault:"5"`
+	PageDelay     time.Duration `env:"PAGE_DELAY"     envDefault:"1s"`
 }
 
 type BotConfig struct {
-	UpdateProcessingTimeout time.Duration `env:"UPDATE_PROCESSING_TIMEOUT" envDefault:"60s"`
-	IssueURL                string        `env:"ISSUE_URL"                 envDefault:"https://github.com/hu553in/telekilogram/issues/new"`
+	UpdateProcessingTimeout time.Duration `env:"UPDATE_PROCESSING_TIMEOUT"  envDefault:"60s"`
+	IssueURL                string        `env:"ISSUE_URL"                  envDefault:"https://github.com/hu553in/telekilogram/issues/new"`
+	FeedHealthFailurePeriod time.Duration `env:"FEED_HEALTH_FAILURE_PERIOD" envDefault:"24h"`
+	OPMLImportTimeout       time.Duration `env:"OPML_IMPORT_TIMEOUT"        envDefault:"10m"`
+	OPMLMaxSize             int64         `env:"OPML_MAX_SIZE"              envDefault:"1048576"`
+	Mode                    string        `env:"MODE"                       envDefault:"polling"`
+	WebhookListenAddr       string        `env:"WEBHOOK_LISTEN_ADDR"        envDefault:":8080"`
+	WebhookPath             string        `env:"WEBHOOK_PATH"               envDefault:"/webhook"`
+	WebhookURL              string        `env:"WEBHOOK_URL"`
+	WebhookSecretToken      string        `env:"WEBHOOK_SECRET_TOKEN"`
+	WebhookDeleteOnShutdown bool          `env:"WEBHOOK_DELETE_ON_SHUTDOWN" envDefault:"false"`
+	WebhookShutdownTimeout  time.Duration `env:"WEBHOOK_SHUTDOWN_TIMEOUT"   envDefault:"10s"`
+	OutboxMaxAttempts       int64         `env:"OUTBOX_MAX_ATTEMPTS"        envDefault:"8"`
+	OutboxBaseBackoff       time.Duration `env:"OUTBOX_BASE_BACKOFF"        envDefault:"30s"`
+	OutboxMaxBackoff        time.Duration `env:"OUTBOX_MAX_BACKOFF"         envDefault:"1h"`
+	OutboxBatchSize         int64         `env:"OUTBOX_BATCH_SIZE"          envDefault:"100"`
+	OutboxRetention         time.Duration `env:"OUTBOX_RETENTION"           envDefault:"168h"`
+}
+
+// MetricsConfig controls the Prometheus endpoint, which is disabled when
+// ListenAddr is empty.
+type MetricsConfig struct {
+	ListenAddr string `env:"LISTEN_ADDR"`
 }
 
 func LoadConfig() Config {
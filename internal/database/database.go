@@ -18,6 +18,7 @@ import (
 )
 
 type Database struct {
+	db  *sql.DB
 	q   *dbsql.Queries
 	log *slog.Logger
 }
@@ -72,5 +73,28 @@ func New(ctx context.Context, dbPath string, log *slog.Logger) (*Database, error
 	}
 
 	q := dbsql.New(dbFile)
-	return &Database{q: q, log: log}, nil
+	return &Database{db: dbFile, q: q, log: log}, nil
+}
+
+// withTx runs fn with queries bound to a transaction, which is committed when
+// fn succeeds and rolled back otherwise.
+func (d *Database) withTx(ctx context.Context, fn func(q *dbsql.Queries) error) error {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+
+	if err = fn(d.q.WithTx(tx)); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return errors.Join(err, fmt.Errorf("roll back transaction: %w", rollbackErr))
+		}
+
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return nil
 }
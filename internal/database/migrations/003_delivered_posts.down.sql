@@ -0,0 +1,3 @@
+drop index if exists idx_delivered_posts_delivered_at;
+
+drop table if exists delivered_posts;
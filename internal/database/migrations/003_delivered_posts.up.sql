@@ -0,0 +1,9 @@
+create table if not exists delivered_posts (
+  user_id integer not null,
+  feed_id integer not null,
+  post_key text not null,
+  delivered_at timestamp not null,
+  primary key (user_id, feed_id, post_key)
+);
+
+create index if not exists idx_delivered_posts_delivered_at on delivered_posts (delivered_at);
@@ -0,0 +1,25 @@
+create table if not exists feeds (
+  id integer primary key autoincrement,
+  user_id integer not null,
+  url text not null,
+  title text not null,
+  unique (user_id, url)
+);
+
+insert or ignore into feeds (id, user_id, url, title)
+select
+  sub.id,
+  sub.user_id,
+  src.url,
+  src.title
+from
+  subscriptions as sub
+  join sources as src on src.id = sub.source_id
+order by
+  sub.id;
+
+drop index if exists idx_subscriptions_source_id;
+
+drop table if exists subscriptions;
+
+drop table if exists sources;
@@ -0,0 +1,37 @@
+create table if not exists sources (
+  id integer primary key autoincrement,
+  url text not null unique,
+  title text not null
+);
+
+insert or ignore into sources (url, title)
+select
+  url,
+  title
+from
+  feeds
+order by
+  id;
+
+-- Subscription IDs reuse feed IDs so unfollow links and delivered posts stay valid.
+create table if not exists subscriptions (
+  id integer primary key autoincrement,
+  user_id integer not null,
+  source_id integer not null references sources (id),
+  unique (user_id, source_id)
+);
+
+insert or ignore into subscriptions (id, user_id, source_id)
+select
+  f.id,
+  f.user_id,
+  s.id
+from
+  feeds as f
+  join sources as s on s.url = f.url
+order by
+  f.id;
+
+create index if not exists idx_subscriptions_source_id on subscriptions (source_id);
+
+drop table if exists feeds;
@@ -0,0 +1,8 @@
+alter table sources
+drop column body_hash;
+
+alter table sources
+drop column last_modified;
+
+alter table sources
+drop column etag;
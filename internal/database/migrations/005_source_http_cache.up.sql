@@ -0,0 +1,8 @@
+alter table sources
+add column etag text not null default '';
+
+alter table sources
+add column last_modified text not null default '';
+
+alter table sources
+add column body_hash text not null default '';
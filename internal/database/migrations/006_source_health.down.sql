@@ -0,0 +1,20 @@
+alter table subscriptions
+drop column health_alert_sent;
+
+alter table sources
+drop column failing_since;
+
+alter table sources
+drop column last_http_status;
+
+alter table sources
+drop column consecutive_failures;
+
+alter table sources
+drop column last_error_at;
+
+alter table sources
+drop column last_error;
+
+alter table sources
+drop column last_success_at;
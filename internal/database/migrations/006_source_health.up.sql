@@ -0,0 +1,20 @@
+alter table sources
+add column last_success_at timestamp;
+
+alter table sources
+add column last_error text not null default '';
+
+alter table sources
+add column last_error_at timestamp;
+
+alter table sources
+add column consecutive_failures integer not null default 0;
+
+alter table sources
+add column last_http_status integer not null default 0;
+
+alter table sources
+add column failing_since timestamp;
+
+alter table subscriptions
+add column health_alert_sent integer not null default 0;
@@ -0,0 +1,3 @@
+drop index if exists idx_filter_rules_user_id;
+
+drop table if exists filter_rules;
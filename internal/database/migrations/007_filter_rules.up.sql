@@ -0,0 +1,12 @@
+-- A null subscription_id applies the rule to all feeds of the user.
+create table if not exists filter_rules (
+  id integer primary key autoincrement,
+  user_id integer not null,
+  subscription_id integer references subscriptions (id),
+  exclude integer not null default 0,
+  is_regex integer not null default 0,
+  case_sensitive integer not null default 0,
+  pattern text not null
+);
+
+create index if not exists idx_filter_rules_user_id on filter_rules (user_id);
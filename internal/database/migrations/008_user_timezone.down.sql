@@ -0,0 +1,9 @@
+drop index if exists idx_user_settings_timezone_auto_digest_hour;
+
+alter table user_settings
+drop column timezone;
+
+alter table user_settings
+rename column auto_digest_hour to auto_digest_hour_utc;
+
+create index if not exists idx_user_settings_auto_digest_hour_utc on user_settings (auto_digest_hour_utc);
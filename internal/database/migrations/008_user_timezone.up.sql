@@ -0,0 +1,11 @@
+-- Digest hours become local to the user's IANA time zone; existing hours stay
+-- valid because every user starts in UTC.
+drop index if exists idx_user_settings_auto_digest_hour_utc;
+
+alter table user_settings
+rename column auto_digest_hour_utc to auto_digest_hour;
+
+alter table user_settings
+add column timezone text not null default 'UTC';
+
+create index if not exists idx_user_settings_timezone_auto_digest_hour on user_settings (timezone, auto_digest_hour);
@@ -0,0 +1,29 @@
+drop index if exists idx_user_settings_timezone;
+
+alter table user_settings
+add column auto_digest_hour integer not null default 0 check (
+  auto_digest_hour >= 0
+  and auto_digest_hour < 24
+);
+
+update user_settings
+set
+  auto_digest_hour = coalesce(
+    (
+      select
+        min(ds.hour)
+      from
+        digest_schedules as ds
+      where
+        ds.user_id = user_settings.user_id
+    ),
+    0
+  );
+
+create index if not exists idx_user_settings_timezone_auto_digest_hour on user_settings (timezone, auto_digest_hour);
+
+drop table if exists digest_deliveries;
+
+drop index if exists idx_digest_schedules_hour;
+
+drop table if exists digest_schedules;
@@ -0,0 +1,37 @@
+-- Weekdays is a bit mask where bit 0 is Sunday and bit 6 is Saturday, matching
+-- Go's time.Weekday. Users without schedules get a daily digest at 00:00.
+create table if not exists digest_schedules (
+  id integer primary key autoincrement,
+  user_id integer not null,
+  hour integer not null check (
+    hour >= 0
+    and hour < 24
+  ),
+  weekdays integer not null check (
+    weekdays > 0
+    and weekdays < 128
+  ),
+  unique (user_id, hour, weekdays)
+);
+
+create index if not exists idx_digest_schedules_hour on digest_schedules (hour);
+
+insert or ignore into digest_schedules (user_id, hour, weekdays)
+select
+  user_id,
+  auto_digest_hour,
+  127
+from
+  user_settings;
+
+create table if not exists digest_deliveries (
+  user_id integer primary key,
+  delivered_at timestamp not null
+);
+
+drop index if exists idx_user_settings_timezone_auto_digest_hour;
+
+alter table user_settings
+drop column auto_digest_hour;
+
+create index if not exists idx_user_settings_timezone on user_settings (timezone);
@@ -0,0 +1,4 @@
+drop index if exists idx_subscriptions_delivery_mode;
+
+alter table subscriptions
+drop column delivery_mode;
@@ -0,0 +1,4 @@
+alter table subscriptions
+add column delivery_mode text not null default 'digest' check (delivery_mode in ('digest', 'realtime', 'muted'));
+
+create index if not exists idx_subscriptions_delivery_mode on subscriptions (delivery_mode);
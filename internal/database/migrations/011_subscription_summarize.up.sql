@@ -0,0 +1,2 @@
+alter table subscriptions
+add column summarize integer not null default 0;
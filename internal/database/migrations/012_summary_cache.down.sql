@@ -0,0 +1,3 @@
+drop index if exists idx_summary_cache_expires_at;
+
+drop table if exists summary_cache;
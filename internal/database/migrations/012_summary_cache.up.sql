@@ -0,0 +1,9 @@
+-- Cache keys are built by the feed package from the canonical post URL and a
+-- sha256 of the post text, so an edited post gets a new key.
+create table if not exists summary_cache (
+  cache_key text primary key,
+  summary text not null,
+  expires_at timestamp not null
+);
+
+create index if not exists idx_summary_cache_expires_at on summary_cache (expires_at);
@@ -0,0 +1,7 @@
+drop table if exists inactive_users;
+
+drop index if exists idx_outbox_chat_id_status;
+
+drop index if exists idx_outbox_status_next_attempt_at;
+
+drop table if exists outbox;
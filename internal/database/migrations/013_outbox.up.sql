@@ -0,0 +1,23 @@
+-- Rendered digest messages are kept here until they are sent, so a restart or a
+-- failed send doesn't lose them. Messages of a chat are sent in id order.
+create table if not exists outbox (
+  id integer primary key autoincrement,
+  chat_id integer not null,
+  text text not null,
+  status text not null default 'pending' check (status in ('pending', 'sent', 'dead')),
+  attempts integer not null default 0,
+  last_error text not null default '',
+  next_attempt_at timestamp not null,
+  created_at timestamp not null,
+  updated_at timestamp not null
+);
+
+create index if not exists idx_outbox_status_next_attempt_at on outbox (status, next_attempt_at);
+
+create index if not exists idx_outbox_chat_id_status on outbox (chat_id, status);
+
+-- Users who blocked the bot get no digests until they write to it again.
+create table if not exists inactive_users (
+  user_id integer primary key,
+  inactive_since timestamp not null
+);
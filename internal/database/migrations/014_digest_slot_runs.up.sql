@@ -0,0 +1,7 @@
+-- The last completed hourly digest run of each UTC hour, so slots missed
+-- while the bot was down can be caught up.
+create table if not exists digest_slot_runs (
+  hour_utc integer primary key check (hour_utc between 0 and 23),
+  slot_at timestamp not null,
+  completed_at timestamp not null
+);
@@ -0,0 +1,2 @@
+alter table subscriptions
+drop column top_n;
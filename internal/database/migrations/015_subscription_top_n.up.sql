@@ -0,0 +1,2 @@
+alter table subscriptions
+add column top_n integer not null default 0;
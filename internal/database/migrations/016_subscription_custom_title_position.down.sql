@@ -0,0 +1,5 @@
+alter table subscriptions
+drop column position;
+
+alter table subscriptions
+drop column custom_title;
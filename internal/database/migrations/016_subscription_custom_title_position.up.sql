@@ -0,0 +1,9 @@
+alter table subscriptions
+add column custom_title text not null default '';
+
+alter table subscriptions
+add column position integer not null default 0;
+
+update subscriptions
+set
+  position = id;
@@ -5,9 +5,11 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	dbsql "telekilogram/internal/database/sql"
 	"telekilogram/internal/domain"
+	"time"
 )
 
 func (d *Database) AddFeed(
@@ -26,10 +28,22 @@ func (d *Database) AddFeed(
 		feedTitle = feedURL
 	}
 
-	err := d.q.AddOrIgnoreFeed(ctx, dbsql.AddOrIgnoreFeedParams{
-		UserID: userID,
-		Url:    feedURL,
-		Title:  feedTitle,
+	err := d.q.AddOrIgnoreSource(ctx, dbsql.AddOrIgnoreSourceParams{
+		Url:   feedURL,
+		Title: feedTitle,
+	})
+	if err != nil {
+		return fmt.Errorf("execute query: %w", err)
+	}
+
+	source, err := d.q.GetSourceByURL(ctx, feedURL)
+	if err != nil {
+		return fmt.Errorf("execute query: %w", err)
+	}
+
+	err = d.q.AddOrIgnoreSubscription(ctx, dbsql.AddOrIgnoreSubscriptionParams{
+		UserID:   userID,
+		SourceID: source.ID,
 	})
 	if err != nil {
 		return fmt.Errorf("execute query: %w", err)
@@ -38,15 +52,15 @@ func (d *Database) AddFeed(
 	return nil
 }
 
-func (d *Database) UpdateFeedTitle(ctx context.Context, feedID int64, feedTitle string) error {
-	feedTitle = strings.TrimSpace(feedTitle)
-	if feedTitle == "" {
-		return errors.New("feed title is empty")
+func (d *Database) UpdateSourceTitle(ctx context.Context, sourceID int64, sourceTitle string) error {
+	sourceTitle = strings.TrimSpace(sourceTitle)
+	if sourceTitle == "" {
+		return errors.New("source title is empty")
 	}
 
-	err := d.q.UpdateFeedTitle(ctx, dbsql.UpdateFeedTitleParams{
-		Title: feedTitle,
-		ID:    feedID,
+	err := d.q.UpdateSourceTitle(ctx, dbsql.UpdateSourceTitleParams{
+		Title: sourceTitle,
+		ID:    sourceID,
 	})
 	if err != nil {
 		return fmt.Errorf("execute query: %w", err)
@@ -55,8 +69,30 @@ func (d *Database) UpdateFeedTitle(ctx context.Context, feedID int64, feedTitle
 	return nil
 }
 
-func (d *Database) RemoveFeed(ctx context.Context, feedID int64) error {
-	err := d.q.RemoveFeed(ctx, feedID)
+func (d *Database) GetSourceHTTPCache(ctx context.Context, sourceID int64) (*domain.SourceHTTPCache, error) {
+	row, err := d.q.GetSourceHTTPCache(ctx, sourceID)
+	if err != nil {
+		return nil, fmt.Errorf("execute query: %w", err)
+	}
+
+	return &domain.SourceHTTPCache{
+		ETag:         row.Etag,
+		LastModified: row.LastModified,
+		BodyHash:     row.BodyHash,
+	}, nil
+}
+
+func (d *Database) UpdateSourceHTTPCache(
+	ctx context.Context,
+	sourceID int64,
+	cache *domain.SourceHTTPCache,
+) error {
+	err := d.q.UpdateSourceHTTPCache(ctx, dbsql.UpdateSourceHTTPCacheParams{
+		Etag:         strings.TrimSpace(cache.ETag),
+		LastModified: strings.TrimSpace(cache.LastModified),
+		BodyHash:     strings.TrimSpace(cache.BodyHash),
+		ID:           sourceID,
+	})
 	if err != nil {
 		return fmt.Errorf("execute query: %w", err)
 	}
@@ -64,6 +100,22 @@ func (d *Database) RemoveFeed(ctx context.Context, feedID int64) error {
 	return nil
 }
 
+func (d *Database) RemoveFeed(ctx context.Context, feedID int64) error {
+	if err := d.q.RemoveSubscriptionFilterRules(ctx, sql.NullInt64{Int64: feedID, Valid: true}); err != nil {
+		return fmt.Errorf("execute query: %w", err)
+	}
+
+	if err := d.q.RemoveSubscription(ctx, feedID); err != nil {
+		return fmt.Errorf("execute query: %w", err)
+	}
+
+	if err := d.q.RemoveOrphanSources(ctx); err != nil {
+		return fmt.Errorf("execute query: %w", err)
+	}
+
+	return nil
+}
+
 func (d *Database) GetUserFeeds(ctx context.Context, userID int64) ([]domain.UserFeed, error) {
 	rows, err := d.q.GetUserFeeds(ctx, userID)
 	if err != nil {
@@ -75,9 +127,23 @@ func (d *Database) GetUserFeeds(ctx context.Context, userID int64) ([]domain.Use
 		var f domain.UserFeed
 
 		f.ID = r.ID
+		f.SourceID = r.SourceID
 		f.URL = strings.TrimSpace(r.Url)
 		f.Title = strings.TrimSpace(r.Title)
 		f.UserID = userID
+		f.Health = feedHealth(
+			r.LastSuccessAt,
+			r.LastError,
+			r.LastErrorAt,
+			r.ConsecutiveFailures,
+			r.LastHttpStatus,
+			r.FailingSince,
+		)
+		f.DeliveryMode = domain.DeliveryMode(r.DeliveryMode)
+		f.SummarizeItems = r.Summarize != 0
+		f.TopN = r.TopN
+		f.CustomTitle = strings.TrimSpace(r.CustomTitle)
+		f.Position = r.Position
 
 		feeds = append(feeds, f)
 	}
@@ -85,32 +151,264 @@ func (d *Database) GetUserFeeds(ctx context.Context, userID int64) ([]domain.Use
 	return feeds, nil
 }
 
-func (d *Database) GetHourFeeds(ctx context.Context, hourUTC int64) ([]domain.UserFeed, error) {
-	var rows []dbsql.Feed
-	var err error
-
-	if hourUTC == 0 {
-		rows, err = d.q.GetHourFeedsMidnightUTC(ctx, hourUTC)
-	} else {
-		rows, err = d.q.GetHourFeeds(ctx, hourUTC)
+func (d *Database) GetUserFeed(ctx context.Context, userID int64, feedID int64) (*domain.UserFeed, error) {
+	row, err := d.q.GetUserFeed(ctx, dbsql.GetUserFeedParams{
+		ID:     feedID,
+		UserID: userID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("execute query: %w", err)
 	}
+
+	f := userFeedWithHealth(row)
+	return &f, nil
+}
+
+// GetFeedsForHealthAlert returns failing feeds whose subscribers weren't alerted
+// yet, skipping muted subscriptions and inactive users.
+func (d *Database) GetFeedsForHealthAlert(ctx context.Context) ([]domain.UserFeed, error) {
+	rows, err := d.q.GetFeedsForHealthAlert(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("execute query: %w", err)
 	}
 
 	var feeds []domain.UserFeed
 	for _, r := range rows {
-		var f domain.UserFeed
+		feeds = append(feeds, userFeedWithHealth(dbsql.GetUserFeedRow(r)))
+	}
 
-		f.ID = r.ID
-		f.URL = strings.TrimSpace(r.Url)
-		f.Title = strings.TrimSpace(r.Title)
-		f.UserID = r.UserID
+	return feeds, nil
+}
 
-		feeds = append(feeds, f)
+func (d *Database) MarkFeedHealthAlertSent(ctx context.Context, feedID int64) error {
+	if err := d.q.MarkSubscriptionHealthAlertSent(ctx, feedID); err != nil {
+		return fmt.Errorf("execute query: %w", err)
 	}
 
-	return feeds, nil
+	return nil
+}
+
+func (d *Database) RecordSourceSuccess(
+	ctx context.Context,
+	sourceID int64,
+	httpStatus int64,
+	succeededAt time.Time,
+) error {
+	err := d.q.RecordSourceSuccess(ctx, dbsql.RecordSourceSuccessParams{
+		LastSuccessAt:  sql.NullTime{Time: succeededAt.UTC(), Valid: true},
+		LastHttpStatus: httpStatus,
+		ID:             sourceID,
+	})
+	if err != nil {
+		return fmt.Errorf("execute query: %w", err)
+	}
+
+	if err = d.q.ResetSubscriptionHealthAlerts(ctx, sourceID); err != nil {
+		return fmt.Errorf("execute query: %w", err)
+	}
+
+	return nil
+}
+
+func (d *Database) RecordSourceFailure(
+	ctx context.Context,
+	sourceID int64,
+	httpStatus int64,
+	lastError string,
+	failedAt time.Time,
+) error {
+	err := d.q.RecordSourceFailure(ctx, dbsql.RecordSourceFailureParams{
+		LastError:      strings.TrimSpace(lastError),
+		LastErrorAt:    sql.NullTime{Time: failedAt.UTC(), Valid: true},
+		LastHttpStatus: httpStatus,
+		FailingSince:   sql.NullTime{Time: failedAt.UTC(), Valid: true},
+		ID:             sourceID,
+	})
+	if err != nil {
+		return fmt.Errorf("execute query: %w", err)
+	}
+
+	return nil
+}
+
+func (d *Database) GetHourFeeds(
+	ctx context.Context,
+	timezone string,
+	hour int64,
+	weekday time.Weekday,
+) ([]domain.UserFeed, error) {
+	rows, err := d.q.GetHourFeeds(ctx, dbsql.GetHourFeedsParams{
+		Timezone:    timezone,
+		Hour:        hour,
+		WeekdayMask: domain.WeekdayMask(weekday),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("execute query: %w", err)
+	}
+
+	return hourFeeds(rows), nil
+}
+
+// GetRealtimeFeeds returns feeds in domain.DeliveryModeRealtime of all users.
+func (d *Database) GetRealtimeFeeds(ctx context.Context) ([]domain.UserFeed, error) {
+	rows, err := d.q.GetRealtimeFeeds(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("execute query: %w", err)
+	}
+
+	var converted []dbsql.GetHourFeedsRow
+	for _, r := range rows {
+		converted = append(converted, dbsql.GetHourFeedsRow(r))
+	}
+
+	return hourFeeds(converted), nil
+}
+
+func (d *Database) UpdateFeedDeliveryMode(
+	ctx context.Context,
+	userID int64,
+	feedID int64,
+	mode domain.DeliveryMode,
+) error {
+	updated, err := d.q.UpdateSubscriptionDeliveryMode(ctx, dbsql.UpdateSubscriptionDeliveryModeParams{
+		DeliveryMode: string(mode),
+		ID:           feedID,
+		UserID:       userID,
+	})
+	if err != nil {
+		return fmt.Errorf("execute query: %w", err)
+	}
+	if updated == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (d *Database) UpdateFeedSummarize(ctx context.Context, userID int64, feedID int64, summarize bool) error {
+	updated, err := d.q.UpdateSubscriptionSummarize(ctx, dbsql.UpdateSubscriptionSummarizeParams{
+		Summarize: boolToInt64(summarize),
+		ID:        feedID,
+		UserID:    userID,
+	})
+	if err != nil {
+		return fmt.Errorf("execute query: %w", err)
+	}
+	if updated == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (d *Database) UpdateFeedTopN(ctx context.Context, userID int64, feedID int64, topN int64) error {
+	updated, err := d.q.UpdateSubscriptionTopN(ctx, dbsql.UpdateSubscriptionTopNParams{
+		TopN:   topN,
+		ID:     feedID,
+		UserID: userID,
+	})
+	if err != nil {
+		return fmt.Errorf("execute query: %w", err)
+	}
+	if updated == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// UpdateFeedCustomTitle sets the user's title of the feed; an empty title
+// restores the source title.
+func (d *Database) UpdateFeedCustomTitle(ctx context.Context, userID int64, feedID int64, title string) error {
+	updated, err := d.q.UpdateSubscriptionCustomTitle(ctx, dbsql.UpdateSubscriptionCustomTitleParams{
+		CustomTitle: strings.TrimSpace(title),
+		ID:          feedID,
+		UserID:      userID,
+	})
+	if err != nil {
+		return fmt.Errorf("execute query: %w", err)
+	}
+	if updated == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// MoveFeed moves the feed by offset places in the user's feed list and
+// renumbers the list in one transaction, so a failed or concurrent move
+// doesn't leave duplicate positions. It reports whether the feed moved, as it
+// stays put at either end of the list.
+func (d *Database) MoveFeed(ctx context.Context, userID int64, feedID int64, offset int) (bool, error) {
+	moved := false
+
+	err := d.withTx(ctx, func(q *dbsql.Queries) error {
+		rows, err := q.GetUserFeeds(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("execute query: %w", err)
+		}
+
+		from := slices.IndexFunc(rows, func(r dbsql.GetUserFeedsRow) bool { return r.ID == feedID })
+		if from == -1 {
+			return sql.ErrNoRows
+		}
+
+		to := from + offset
+		if to < 0 || to >= len(rows) {
+			return nil
+		}
+
+		rows[from], rows[to] = rows[to], rows[from]
+
+		for i, r := range rows {
+			position := int64(i + 1)
+			if r.Position == position {
+				continue
+			}
+
+			if _, err = q.UpdateSubscriptionPosition(ctx, dbsql.UpdateSubscriptionPositionParams{
+				Position: position,
+				ID:       r.ID,
+				UserID:   userID,
+			}); err != nil {
+				return fmt.Errorf("execute query: %w", err)
+			}
+		}
+
+		moved = true
+
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return moved, nil
+}
+
+// GetDefaultScheduleFeeds returns feeds of users in timezone who have no digest
+// schedules, so their digest runs daily at domain.DefaultAutoDigestHour.
+func (d *Database) GetDefaultScheduleFeeds(ctx context.Context, timezone string) ([]domain.UserFeed, error) {
+	rows, err := d.q.GetDefaultScheduleFeeds(ctx, timezone)
+	if err != nil {
+		return nil, fmt.Errorf("execute query: %w", err)
+	}
+
+	var converted []dbsql.GetHourFeedsRow
+	for _, r := range rows {
+		converted = append(converted, dbsql.GetHourFeedsRow(r))
+	}
+
+	return hourFeeds(converted), nil
+}
+
+func (d *Database) GetUserSettingsTimezones(ctx context.Context) ([]string, error) {
+	timezones, err := d.q.GetUserSettingsTimezones(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("execute query: %w", err)
+	}
+
+	return timezones, nil
 }
 
 func (d *Database) GetUserSettingsWithDefault(
@@ -121,23 +419,491 @@ func (d *Database) GetUserSettingsWithDefault(
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return &domain.UserSettings{
-				UserID:            userID,
-				AutoDigestHourUTC: 0,
+				UserID:   userID,
+				Timezone: domain.DefaultTimezone,
 			}, nil
 		}
 		return nil, fmt.Errorf("execute query: %w", err)
 	}
 
 	return &domain.UserSettings{
-		UserID:            row.UserID,
-		AutoDigestHourUTC: row.AutoDigestHourUtc,
+		UserID:   row.UserID,
+		Timezone: row.Timezone,
 	}, nil
 }
 
 func (d *Database) UpsertUserSettings(ctx context.Context, userSettings *domain.UserSettings) error {
+	timezone := strings.TrimSpace(userSettings.Timezone)
+	if timezone == "" {
+		timezone = domain.DefaultTimezone
+	}
+
 	err := d.q.UpsertUserSettings(ctx, dbsql.UpsertUserSettingsParams{
-		UserID:            userSettings.UserID,
-		AutoDigestHourUtc: userSettings.AutoDigestHourUTC,
+		UserID:   userSettings.UserID,
+		Timezone: timezone,
+	})
+	if err != nil {
+		return fmt.Errorf("execute query: %w", err)
+	}
+
+	return nil
+}
+
+func (d *Database) GetUserDigestSchedules(ctx context.Context, userID int64) ([]domain.DigestSchedule, error) {
+	rows, err := d.q.GetUserDigestSchedules(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("execute query: %w", err)
+	}
+
+	schedules := make([]domain.DigestSchedule, 0, len(rows))
+	for _, r := range rows {
+		schedules = append(schedules, domain.DigestSchedule{
+			ID:       r.ID,
+			UserID:   r.UserID,
+			Hour:     r.Hour,
+			Weekdays: r.Weekdays,
+		})
+	}
+
+	return schedules, nil
+}
+
+func (d *Database) AddDigestSchedule(ctx context.Context, schedule *domain.DigestSchedule) error {
+	err := d.q.AddOrIgnoreDigestSchedule(ctx, dbsql.AddOrIgnoreDigestScheduleParams{
+		UserID:   schedule.UserID,
+		Hour:     schedule.Hour,
+		Weekdays: schedule.Weekdays,
+	})
+	if err != nil {
+		return fmt.Errorf("execute query: %w", err)
+	}
+
+	return nil
+}
+
+func (d *Database) RemoveDigestSchedule(ctx context.Context, userID int64, scheduleID int64) error {
+	removed, err := d.q.RemoveDigestSchedule(ctx, dbsql.RemoveDigestScheduleParams{
+		ID:     scheduleID,
+		UserID: userID,
+	})
+	if err != nil {
+		return fmt.Errorf("execute query: %w", err)
+	}
+
+	if removed == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+// GetDigestDeliveredAt returns when the user last got a digest, or a zero time
+// when they never did.
+func (d *Database) GetDigestDeliveredAt(ctx context.Context, userID int64) (time.Time, error) {
+	deliveredAt, err := d.q.GetDigestDeliveredAt(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("execute query: %w", err)
+	}
+
+	return deliveredAt, nil
+}
+
+func (d *Database) UpsertDigestDelivery(ctx context.Context, userID int64, deliveredAt time.Time) error {
+	err := d.q.UpsertDigestDelivery(ctx, dbsql.UpsertDigestDeliveryParams{
+		UserID:      userID,
+		DeliveredAt: deliveredAt.UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("execute query: %w", err)
+	}
+
+	return nil
+}
+
+// GetDigestSlotRuns returns the last completed digest slot of each UTC hour
+// that has one, keyed by the hour.
+func (d *Database) GetDigestSlotRuns(ctx context.Context) (map[int64]time.Time, error) {
+	rows, err := d.q.GetDigestSlotRuns(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("execute query: %w", err)
+	}
+
+	runs := make(map[int64]time.Time, len(rows))
+	for _, r := range rows {
+		runs[r.HourUtc] = r.SlotAt
+	}
+
+	return runs, nil
+}
+
+// UpsertDigestSlotRun records the slot as the last completed one of its UTC
+// hour, unless a later slot of that hour is already recorded.
+func (d *Database) UpsertDigestSlotRun(ctx context.Context, slotAt time.Time, completedAt time.Time) error {
+	slotAt = slotAt.UTC()
+
+	err := d.q.UpsertDigestSlotRun(ctx, dbsql.UpsertDigestSlotRunParams{
+		HourUtc:     int64(slotAt.Hour()),
+		SlotAt:      slotAt,
+		CompletedAt: completedAt.UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("execute query: %w", err)
+	}
+
+	return nil
+}
+
+func (d *Database) GetDeliveredPostKeys(
+	ctx context.Context,
+	userID int64,
+	feedID int64,
+) (map[string]struct{}, error) {
+	rows, err := d.q.GetDeliveredPostKeys(ctx, dbsql.GetDeliveredPostKeysParams{
+		UserID: userID,
+		FeedID: feedID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("execute query: %w", err)
+	}
+
+	keys := make(map[string]struct{}, len(rows))
+	for _, r := range rows {
+		keys[r] = struct{}{}
+	}
+
+	return keys, nil
+}
+
+func (d *Database) AddDeliveredPost(
+	ctx context.Context,
+	userID int64,
+	feedID int64,
+	postKey string,
+	deliveredAt time.Time,
+) error {
+	postKey = strings.TrimSpace(postKey)
+	if postKey == "" {
+		return errors.New("post key is empty")
+	}
+
+	err := d.q.AddOrIgnoreDeliveredPost(ctx, dbsql.AddOrIgnoreDeliveredPostParams{
+		UserID:      userID,
+		FeedID:      feedID,
+		PostKey:     postKey,
+		DeliveredAt: deliveredAt.UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("execute query: %w", err)
+	}
+
+	return nil
+}
+
+func (d *Database) RemoveDeliveredPostsBefore(ctx context.Context, before time.Time) (int64, error) {
+	removed, err := d.q.RemoveDeliveredPostsBefore(ctx, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("execute query: %w", err)
+	}
+
+	return removed, nil
+}
+
+func (d *Database) AddFilterRule(ctx context.Context, rule *domain.FilterRule) error {
+	pattern := strings.TrimSpace(rule.Pattern)
+	if pattern == "" {
+		return errors.New("filter pattern is empty")
+	}
+
+	err := d.q.AddFilterRule(ctx, dbsql.AddFilterRuleParams{
+		UserID:         rule.UserID,
+		SubscriptionID: sql.NullInt64{Int64: rule.FeedID, Valid: rule.FeedID != 0},
+		Exclude:        boolToInt64(rule.Exclude),
+		IsRegex:        boolToInt64(rule.Regex),
+		CaseSensitive:  boolToInt64(rule.CaseSensitive),
+		Pattern:        pattern,
+	})
+	if err != nil {
+		return fmt.Errorf("execute query: %w", err)
+	}
+
+	return nil
+}
+
+func (d *Database) GetUserFilterRules(ctx context.Context, userID int64) ([]domain.FilterRule, error) {
+	rows, err := d.q.GetUserFilterRules(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("execute query: %w", err)
+	}
+
+	rules := make([]domain.FilterRule, 0, len(rows))
+	for _, r := range rows {
+		rules = append(rules, domain.FilterRule{
+			ID:            r.ID,
+			UserID:        r.UserID,
+			FeedID:        r.SubscriptionID.Int64,
+			FeedTitle:     strings.TrimSpace(r.FeedTitle),
+			Exclude:       r.Exclude != 0,
+			Regex:         r.IsRegex != 0,
+			CaseSensitive: r.CaseSensitive != 0,
+			Pattern:       r.Pattern,
+		})
+	}
+
+	return rules, nil
+}
+
+func (d *Database) RemoveFilterRule(ctx context.Context, userID int64, ruleID int64) error {
+	removed, err := d.q.RemoveFilterRule(ctx, dbsql.RemoveFilterRuleParams{
+		ID:     ruleID,
+		UserID: userID,
+	})
+	if err != nil {
+		return fmt.Errorf("execute query: %w", err)
+	}
+
+	if removed == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func boolToInt64(b bool) int64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+func hourFeeds(rows []dbsql.GetHourFeedsRow) []domain.UserFeed {
+	var feeds []domain.UserFeed
+	for _, r := range rows {
+		var f domain.UserFeed
+
+		f.ID = r.ID
+		f.SourceID = r.SourceID
+		f.URL = strings.TrimSpace(r.Url)
+		f.Title = strings.TrimSpace(r.Title)
+		f.UserID = r.UserID
+		f.SummarizeItems = r.Summarize != 0
+		f.TopN = r.TopN
+		f.CustomTitle = strings.TrimSpace(r.CustomTitle)
+		f.Position = r.Position
+
+		feeds = append(feeds, f)
+	}
+
+	return feeds
+}
+
+func userFeedWithHealth(r dbsql.GetUserFeedRow) domain.UserFeed {
+	return domain.UserFeed{
+		ID:       r.ID,
+		UserID:   r.UserID,
+		SourceID: r.SourceID,
+		URL:      strings.TrimSpace(r.Url),
+		Title:    strings.TrimSpace(r.Title),
+		Health: feedHealth(
+			r.LastSuccessAt,
+			r.LastError,
+			r.LastErrorAt,
+			r.ConsecutiveFailures,
+			r.LastHttpStatus,
+			r.FailingSince,
+		),
+		DeliveryMode:   domain.DeliveryMode(r.DeliveryMode),
+		SummarizeItems: r.Summarize != 0,
+		TopN:           r.TopN,
+		CustomTitle:    strings.TrimSpace(r.CustomTitle),
+		Position:       r.Position,
+	}
+}
+
+func feedHealth(
+	lastSuccessAt sql.NullTime,
+	lastError string,
+	lastErrorAt sql.NullTime,
+	consecutiveFailures int64,
+	lastHTTPStatus int64,
+	failingSince sql.NullTime,
+) domain.FeedHealth {
+	health := domain.FeedHealth{
+		LastError:           strings.TrimSpace(lastError),
+		ConsecutiveFailures: consecutiveFailures,
+		LastHTTPStatus:      lastHTTPStatus,
+	}
+
+	if lastSuccessAt.Valid {
+		health.LastSuccessAt = lastSuccessAt.Time
+	}
+	if lastErrorAt.Valid {
+		health.LastErrorAt = lastErrorAt.Time
+	}
+	if failingSince.Valid {
+		health.FailingSince = failingSince.Time
+	}
+
+	return health
+}
+
+// GetSummaryCacheEntry returns an unexpired summary, or an error wrapping
+// sql.ErrNoRows when there is none.
+func (d *Database) GetSummaryCacheEntry(
+	ctx context.Context,
+	key string,
+	now time.Time,
+) (*domain.SummaryCacheEntry, error) {
+	row, err := d.q.GetSummaryCacheEntry(ctx, dbsql.GetSummaryCacheEntryParams{
+		CacheKey:  key,
+		ExpiresAt: now.UTC(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("execute query: %w", err)
+	}
+
+	return &domain.SummaryCacheEntry{Key: key, Summary: row.Summary, ExpiresAt: row.ExpiresAt}, nil
+}
+
+// GetLatestSummaryCacheEntries returns up to limit unexpired summaries, the
+// latest expiring first.
+func (d *Database) GetLatestSummaryCacheEntries(
+	ctx context.Context,
+	now time.Time,
+	limit int64,
+) ([]domain.SummaryCacheEntry, error) {
+	rows, err := d.q.GetLatestSummaryCacheEntries(ctx, dbsql.GetLatestSummaryCacheEntriesParams{
+		ExpiresAt: now.UTC(),
+		Limit:     limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("execute query: %w", err)
+	}
+
+	entries := make([]domain.SummaryCacheEntry, 0, len(rows))
+	for _, r := range rows {
+		entries = append(entries, domain.SummaryCacheEntry{
+			Key:       r.CacheKey,
+			Summary:   r.Summary,
+			ExpiresAt: r.ExpiresAt,
+		})
+	}
+
+	return entries, nil
+}
+
+func (d *Database) UpsertSummaryCacheEntry(ctx context.Context, entry *domain.SummaryCacheEntry) error {
+	err := d.q.UpsertSummaryCacheEntry(ctx, dbsql.UpsertSummaryCacheEntryParams{
+		CacheKey:  entry.Key,
+		Summary:   entry.Summary,
+		ExpiresAt: entry.ExpiresAt.UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("execute query: %w", err)
+	}
+
+	return nil
+}
+
+func (d *Database) RemoveSummaryCacheEntriesBefore(ctx context.Context, before time.Time) (int64, error) {
+	removed, err := d.q.RemoveSummaryCacheEntriesBefore(ctx, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("execute query: %w", err)
+	}
+
+	return removed, nil
+}
+
+// AddOutboxMessages queues messages of a chat in one transaction, so a digest
+// split into several messages is queued either whole or not at all.
+func (d *Database) AddOutboxMessages(ctx context.Context, chatID int64, texts []string, now time.Time) error {
+	return d.withTx(ctx, func(q *dbsql.Queries) error {
+		for _, text := range texts {
+			err := q.AddOutboxMessage(ctx, dbsql.AddOutboxMessageParams{
+				ChatID: chatID,
+				Text:   text,
+				Now:    now.UTC(),
+			})
+			if err != nil {
+				return fmt.Errorf("execute query: %w", err)
+			}
+		}
+
+		return nil
+	})
+}
+
+// GetDueOutboxMessages returns up to limit pending messages due at now, oldest
+// first. A message is skipped while an earlier message of its chat waits for
+// a retry, so chats get their messages in order.
+func (d *Database) GetDueOutboxMessages(
+	ctx context.Context,
+	now time.Time,
+	limit int64,
+) ([]domain.OutboxMessage, error) {
+	rows, err := d.q.GetDueOutboxMessages(ctx, dbsql.GetDueOutboxMessagesParams{
+		Now:   now.UTC(),
+		Limit: limit,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("execute query: %w", err)
+	}
+
+	messages := make([]domain.OutboxMessage, 0, len(rows))
+	for _, r := range rows {
+		messages = append(messages, domain.OutboxMessage{
+			ID:       r.ID,
+			ChatID:   r.ChatID,
+			Text:     r.Text,
+			Attempts: r.Attempts,
+		})
+	}
+
+	return messages, nil
+}
+
+func (d *Database) MarkOutboxMessageSent(ctx context.Context, id int64, now time.Time) error {
+	err := d.q.MarkOutboxMessageSent(ctx, dbsql.MarkOutboxMessageSentParams{
+		Now: now.UTC(),
+		ID:  id,
+	})
+	if err != nil {
+		return fmt.Errorf("execute query: %w", err)
+	}
+
+	return nil
+}
+
+// RetryOutboxMessage counts a failed attempt and schedules the next one at
+// nextAttemptAt.
+func (d *Database) RetryOutboxMessage(
+	ctx context.Context,
+	id int64,
+	lastError string,
+	nextAttemptAt time.Time,
+	now time.Time,
+) error {
+	err := d.q.RetryOutboxMessage(ctx, dbsql.RetryOutboxMessageParams{
+		LastError:     lastError,
+		NextAttemptAt: nextAttemptAt.UTC(),
+		Now:           now.UTC(),
+		ID:            id,
+	})
+	if err != nil {
+		return fmt.Errorf("execute query: %w", err)
+	}
+
+	return nil
+}
+
+func (d *Database) MarkOutboxMessageDead(ctx context.Context, id int64, lastError string, now time.Time) error {
+	err := d.q.MarkOutboxMessageDead(ctx, dbsql.MarkOutboxMessageDeadParams{
+		LastError: lastError,
+		Now:       now.UTC(),
+		ID:        id,
 	})
 	if err != nil {
 		return fmt.Errorf("execute query: %w", err)
@@ -145,3 +911,56 @@ func (d *Database) UpsertUserSettings(ctx context.Context, userSettings *domain.
 
 	return nil
 }
+
+// MarkChatOutboxMessagesDead gives up on all pending messages of the chat and
+// returns how many there were.
+func (d *Database) MarkChatOutboxMessagesDead(
+	ctx context.Context,
+	chatID int64,
+	lastError string,
+	now time.Time,
+) (int64, error) {
+	marked, err := d.q.MarkChatOutboxMessagesDead(ctx, dbsql.MarkChatOutboxMessagesDeadParams{
+		LastError: lastError,
+		Now:       now.UTC(),
+		ChatID:    chatID,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("execute query: %w", err)
+	}
+
+	return marked, nil
+}
+
+// RemoveOutboxMessagesBefore removes sent and dead messages last updated
+// before the given time. Pending messages are kept.
+func (d *Database) RemoveOutboxMessagesBefore(ctx context.Context, before time.Time) (int64, error) {
+	removed, err := d.q.RemoveOutboxMessagesBefore(ctx, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("execute query: %w", err)
+	}
+
+	return removed, nil
+}
+
+// MarkUserInactive stops scheduled deliveries to the user until
+// MarkUserActive.
+func (d *Database) MarkUserInactive(ctx context.Context, userID int64, now time.Time) error {
+	err := d.q.AddOrIgnoreInactiveUser(ctx, dbsql.AddOrIgnoreInactiveUserParams{
+		UserID:        userID,
+		InactiveSince: now.UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("execute query: %w", err)
+	}
+
+	return nil
+}
+
+func (d *Database) MarkUserActive(ctx context.Context, userID int64) error {
+	if err := d.q.RemoveInactiveUser(ctx, userID); err != nil {
+		return fmt.Errorf("execute query: %w", err)
+	}
+
+	return nil
+}
@@ -4,14 +4,97 @@
 
 package sql
 
-type Feed struct {
-	ID     int64
-	UserID int64
-	Url    string
-	Title  string
+import (
+	"database/sql"
+	"time"
+)
+
+type DeliveredPost struct {
+	UserID      int64
+	FeedID      int64
+	PostKey     string
+	DeliveredAt time.Time
+}
+
+type DigestDelivery struct {
+	UserID      int64
+	DeliveredAt time.Time
+}
+
+type DigestSchedule struct {
+	ID       int64
+	UserID   int64
+	Hour     int64
+	Weekdays int64
+}
+
+type DigestSlotRun struct {
+	HourUtc     int64
+	SlotAt      time.Time
+	CompletedAt time.Time
+}
+
+type FilterRule struct {
+	ID             int64
+	UserID         int64
+	SubscriptionID sql.NullInt64
+	Exclude        int64
+	IsRegex        int64
+	CaseSensitive  int64
+	Pattern        string
+}
+
+type InactiveUser struct {
+	UserID        int64
+	InactiveSince time.Time
+}
+
+type Outbox struct {
+	ID            int64
+	ChatID        int64
+	Text          string
+	Status        string
+	Attempts      int64
+	LastError     string
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+type Source struct {
+	ID                  int64
+	Url                 string
+	Title               string
+	Etag                string
+	LastModified        string
+	BodyHash            string
+	LastSuccessAt       sql.NullTime
+	LastError           string
+	LastErrorAt         sql.NullTime
+	ConsecutiveFailures int64
+	LastHttpStatus      int64
+	FailingSince        sql.NullTime
+}
+
+type Subscription struct {
+	ID              int64
+	UserID          int64
+	SourceID        int64
+	HealthAlertSent int64
+	DeliveryMode    string
+	Summarize       int64
+	TopN            int64
+	CustomTitle     string
+	Position        int64
+}
+
+type SummaryCache struct {
+	CacheKey  string
+	Summary   string
+	ExpiresAt time.Time
 }
 
 type UserSetting struct {
-	UserID            int64
-	AutoDigestHourUtc int64
+	UserID   int64
+	Timezone string
 }
@@ -1,60 +1,348 @@
--- name: AddOrIgnoreFeed :exec
+-- name: AddOrIgnoreSource :exec
 insert or ignore into
-    feeds (user_id, url, title)
+    sources (url, title)
 values
-    (?, ?, ?);
+    (?, ?);
 
--- name: UpdateFeedTitle :exec
-update feeds
+-- name: GetSourceByURL :one
+select
+    id,
+    url,
+    title
+from
+    sources
+where
+    url = ?;
+
+-- name: UpdateSourceTitle :exec
+update sources
 set
     title = ?
 where
     id = ?;
 
--- name: RemoveFeed :exec
-delete from feeds
+-- name: GetSourceHTTPCache :one
+select
+    etag,
+    last_modified,
+    body_hash
+from
+    sources
+where
+    id = ?;
+
+-- name: UpdateSourceHTTPCache :exec
+update sources
+set
+    etag = ?,
+    last_modified = ?,
+    body_hash = ?
+where
+    id = ?;
+
+-- name: RecordSourceSuccess :exec
+update sources
+set
+    last_success_at = ?,
+    last_http_status = ?,
+    consecutive_failures = 0,
+    failing_since = null
+where
+    id = ?;
+
+-- name: RecordSourceFailure :exec
+update sources
+set
+    last_error = ?,
+    last_error_at = ?,
+    last_http_status = ?,
+    consecutive_failures = consecutive_failures + 1,
+    failing_since = coalesce(failing_since, ?)
+where
+    id = ?;
+
+-- name: RemoveOrphanSources :exec
+delete from sources
+where
+    id not in (
+        select
+            source_id
+        from
+            subscriptions
+    );
+
+-- name: AddOrIgnoreSubscription :exec
+insert or ignore into
+    subscriptions (user_id, source_id, position)
+values
+    (
+        sqlc.arg(user_id),
+        sqlc.arg(source_id),
+        (
+            select
+                coalesce(max(position), 0) + 1
+            from
+                subscriptions
+            where
+                user_id = sqlc.arg(user_id)
+        )
+    );
+
+-- name: ResetSubscriptionHealthAlerts :exec
+update subscriptions
+set
+    health_alert_sent = 0
+where
+    source_id = ?;
+
+-- name: MarkSubscriptionHealthAlertSent :exec
+update subscriptions
+set
+    health_alert_sent = 1
+where
+    id = ?;
+
+-- name: RemoveSubscription :exec
+delete from subscriptions
 where
     id = ?;
 
 -- name: GetUserFeeds :many
 select
-    id,
-    url,
-    title
+    sub.id,
+    src.id as source_id,
+    src.url,
+    src.title,
+    src.last_success_at,
+    src.last_error,
+    src.last_error_at,
+    src.consecutive_failures,
+    src.last_http_status,
+    src.failing_since,
+    sub.delivery_mode,
+    sub.summarize,
+    sub.top_n,
+    sub.custom_title,
+    sub.position
 from
-    feeds
+    subscriptions as sub
+    join sources as src on src.id = sub.source_id
 where
-    user_id = ?;
+    sub.user_id = ?
+order by
+    sub.position,
+    sub.id;
 
--- name: GetHourFeedsMidnightUTC :many
+-- name: GetUserFeed :one
 select
-    f.id,
-    f.user_id,
-    f.url,
-    f.title
+    sub.id,
+    sub.user_id,
+    src.id as source_id,
+    src.url,
+    src.title,
+    src.last_success_at,
+    src.last_error,
+    src.last_error_at,
+    src.consecutive_failures,
+    src.last_http_status,
+    src.failing_since,
+    sub.delivery_mode,
+    sub.summarize,
+    sub.top_n,
+    sub.custom_title,
+    sub.position
 from
-    feeds as f
-    left join user_settings as us on us.user_id = f.user_id
+    subscriptions as sub
+    join sources as src on src.id = sub.source_id
 where
-    us.user_id is null
-    or us.auto_digest_hour_utc = ?;
+    sub.id = ?
+    and sub.user_id = ?;
+
+-- name: GetFeedsForHealthAlert :many
+select
+    sub.id,
+    sub.user_id,
+    src.id as source_id,
+    src.url,
+    src.title,
+    src.last_success_at,
+    src.last_error,
+    src.last_error_at,
+    src.consecutive_failures,
+    src.last_http_status,
+    src.failing_since,
+    sub.delivery_mode,
+    sub.summarize,
+    sub.top_n,
+    sub.custom_title,
+    sub.position
+from
+    subscriptions as sub
+    join sources as src on src.id = sub.source_id
+where
+    src.failing_since is not null
+    and sub.health_alert_sent = 0
+    and sub.delivery_mode != 'muted'
+    and not exists (
+        select
+            1
+        from
+            inactive_users as iu
+        where
+            iu.user_id = sub.user_id
+    )
+order by
+    sub.id;
+
+-- name: GetDefaultScheduleFeeds :many
+select
+    sub.id,
+    sub.user_id,
+    src.id as source_id,
+    src.url,
+    src.title,
+    sub.summarize,
+    sub.top_n,
+    sub.custom_title,
+    sub.position
+from
+    subscriptions as sub
+    join sources as src on src.id = sub.source_id
+    left join user_settings as us on us.user_id = sub.user_id
+where
+    sub.delivery_mode = 'digest'
+    and coalesce(us.timezone, 'UTC') = ?
+    and not exists (
+        select
+            1
+        from
+            inactive_users as iu
+        where
+            iu.user_id = sub.user_id
+    )
+    and not exists (
+        select
+            1
+        from
+            digest_schedules as ds
+        where
+            ds.user_id = sub.user_id
+    );
 
 -- name: GetHourFeeds :many
 select
-    f.id,
-    f.user_id,
-    f.url,
-    f.title
+    sub.id,
+    sub.user_id,
+    src.id as source_id,
+    src.url,
+    src.title,
+    sub.summarize,
+    sub.top_n,
+    sub.custom_title,
+    sub.position
+from
+    subscriptions as sub
+    join sources as src on src.id = sub.source_id
+    left join user_settings as us on us.user_id = sub.user_id
+where
+    sub.delivery_mode = 'digest'
+    and coalesce(us.timezone, 'UTC') = sqlc.arg(timezone)
+    and not exists (
+        select
+            1
+        from
+            inactive_users as iu
+        where
+            iu.user_id = sub.user_id
+    )
+    and exists (
+        select
+            1
+        from
+            digest_schedules as ds
+        where
+            ds.user_id = sub.user_id
+            and ds.hour = sqlc.arg(hour)
+            and (ds.weekdays & sqlc.arg(weekday_mask)) != 0
+    );
+
+-- name: GetRealtimeFeeds :many
+select
+    sub.id,
+    sub.user_id,
+    src.id as source_id,
+    src.url,
+    src.title,
+    sub.summarize,
+    sub.top_n,
+    sub.custom_title,
+    sub.position
 from
-    feeds as f
-    left join user_settings as us on us.user_id = f.user_id
+    subscriptions as sub
+    join sources as src on src.id = sub.source_id
+where
+    sub.delivery_mode = 'realtime'
+    and not exists (
+        select
+            1
+        from
+            inactive_users as iu
+        where
+            iu.user_id = sub.user_id
+    )
+order by
+    sub.id;
+
+-- name: UpdateSubscriptionDeliveryMode :execrows
+update subscriptions
+set
+    delivery_mode = ?
+where
+    id = ?
+    and user_id = ?;
+
+-- name: UpdateSubscriptionSummarize :execrows
+update subscriptions
+set
+    summarize = ?
+where
+    id = ?
+    and user_id = ?;
+
+-- name: UpdateSubscriptionTopN :execrows
+update subscriptions
+set
+    top_n = ?
+where
+    id = ?
+    and user_id = ?;
+
+-- name: UpdateSubscriptionCustomTitle :execrows
+update subscriptions
+set
+    custom_title = ?
+where
+    id = ?
+    and user_id = ?;
+
+-- name: UpdateSubscriptionPosition :execrows
+update subscriptions
+set
+    position = ?
 where
-    us.auto_digest_hour_utc = ?;
+    id = ?
+    and user_id = ?;
+
+-- name: GetUserSettingsTimezones :many
+select distinct
+    timezone
+from
+    user_settings;
 
 -- name: GetUserSettings :one
 select
     user_id,
-    auto_digest_hour_utc
+    timezone
 from
     user_settings
 where
@@ -62,9 +350,269 @@ where
 
 -- name: UpsertUserSettings :exec
 insert into
-    user_settings (user_id, auto_digest_hour_utc)
+    user_settings (user_id, timezone)
+values
+    (?, ?)
+on conflict (user_id) do update
+set
+    timezone = excluded.timezone;
+
+-- name: GetUserDigestSchedules :many
+select
+    id,
+    user_id,
+    hour,
+    weekdays
+from
+    digest_schedules
+where
+    user_id = ?
+order by
+    hour,
+    weekdays desc;
+
+-- name: AddOrIgnoreDigestSchedule :exec
+insert or ignore into
+    digest_schedules (user_id, hour, weekdays)
+values
+    (?, ?, ?);
+
+-- name: RemoveDigestSchedule :execrows
+delete from digest_schedules
+where
+    id = ?
+    and user_id = ?;
+
+-- name: GetDigestDeliveredAt :one
+select
+    delivered_at
+from
+    digest_deliveries
+where
+    user_id = ?;
+
+-- name: UpsertDigestDelivery :exec
+insert into
+    digest_deliveries (user_id, delivered_at)
 values
     (?, ?)
 on conflict (user_id) do update
 set
-    auto_digest_hour_utc = excluded.auto_digest_hour_utc;
+    delivered_at = excluded.delivered_at;
+
+-- name: GetDigestSlotRuns :many
+select
+    hour_utc,
+    slot_at
+from
+    digest_slot_runs;
+
+-- name: UpsertDigestSlotRun :exec
+insert into
+    digest_slot_runs (hour_utc, slot_at, completed_at)
+values
+    (?, ?, ?)
+on conflict (hour_utc) do update
+set
+    slot_at = excluded.slot_at,
+    completed_at = excluded.completed_at
+where
+    excluded.slot_at > digest_slot_runs.slot_at;
+
+-- name: GetDeliveredPostKeys :many
+select
+    post_key
+from
+    delivered_posts
+where
+    user_id = ?
+    and feed_id = ?;
+
+-- name: AddOrIgnoreDeliveredPost :exec
+insert or ignore into
+    delivered_posts (user_id, feed_id, post_key, delivered_at)
+values
+    (?, ?, ?, ?);
+
+-- name: RemoveDeliveredPostsBefore :execrows
+delete from delivered_posts
+where
+    delivered_at < ?;
+
+-- name: AddFilterRule :exec
+insert into
+    filter_rules (
+        user_id,
+        subscription_id,
+        exclude,
+        is_regex,
+        case_sensitive,
+        pattern
+    )
+values
+    (?, ?, ?, ?, ?, ?);
+
+-- name: GetUserFilterRules :many
+select
+    fr.id,
+    fr.user_id,
+    fr.subscription_id,
+    fr.exclude,
+    fr.is_regex,
+    fr.case_sensitive,
+    fr.pattern,
+    coalesce(nullif(sub.custom_title, ''), src.title, '') as feed_title
+from
+    filter_rules as fr
+    left join subscriptions as sub on sub.id = fr.subscription_id
+    left join sources as src on src.id = sub.source_id
+where
+    fr.user_id = ?
+order by
+    fr.id;
+
+-- name: RemoveFilterRule :execrows
+delete from filter_rules
+where
+    id = ?
+    and user_id = ?;
+
+-- name: RemoveSubscriptionFilterRules :exec
+delete from filter_rules
+where
+    subscription_id = ?;
+
+-- name: GetSummaryCacheEntry :one
+select
+    summary,
+    expires_at
+from
+    summary_cache
+where
+    cache_key = ?
+    and expires_at > ?;
+
+-- name: GetLatestSummaryCacheEntries :many
+select
+    cache_key,
+    summary,
+    expires_at
+from
+    summary_cache
+where
+    expires_at > ?
+order by
+    expires_at desc
+limit
+    ?;
+
+-- name: UpsertSummaryCacheEntry :exec
+insert into
+    summary_cache (cache_key, summary, expires_at)
+values
+    (?, ?, ?)
+on conflict (cache_key) do update
+set
+    summary = excluded.summary,
+    expires_at = excluded.expires_at;
+
+-- name: RemoveSummaryCacheEntriesBefore :execrows
+delete from summary_cache
+where
+    expires_at <= ?;
+
+-- name: AddOutboxMessage :exec
+insert into
+    outbox (chat_id, text, next_attempt_at, created_at, updated_at)
+values
+    (
+        sqlc.arg(chat_id),
+        sqlc.arg(text),
+        sqlc.arg(now),
+        sqlc.arg(now),
+        sqlc.arg(now)
+    );
+
+-- name: GetDueOutboxMessages :many
+select
+    o.id,
+    o.chat_id,
+    o.text,
+    o.attempts
+from
+    outbox as o
+where
+    o.status = 'pending'
+    and o.next_attempt_at <= sqlc.arg(now)
+    and not exists (
+        select
+            1
+        from
+            outbox as prev
+        where
+            prev.chat_id = o.chat_id
+            and prev.status = 'pending'
+            and prev.id < o.id
+            and prev.next_attempt_at > sqlc.arg(now)
+    )
+order by
+    o.id
+limit
+    sqlc.arg(limit);
+
+-- name: MarkOutboxMessageSent :exec
+update outbox
+set
+    status = 'sent',
+    attempts = attempts + 1,
+    last_error = '',
+    updated_at = sqlc.arg(now)
+where
+    id = sqlc.arg(id);
+
+-- name: RetryOutboxMessage :exec
+update outbox
+set
+    attempts = attempts + 1,
+    last_error = sqlc.arg(last_error),
+    next_attempt_at = sqlc.arg(next_attempt_at),
+    updated_at = sqlc.arg(now)
+where
+    id = sqlc.arg(id);
+
+-- name: MarkOutboxMessageDead :exec
+update outbox
+set
+    status = 'dead',
+    attempts = attempts + 1,
+    last_error = sqlc.arg(last_error),
+    updated_at = sqlc.arg(now)
+where
+    id = sqlc.arg(id);
+
+-- name: MarkChatOutboxMessagesDead :execrows
+update outbox
+set
+    status = 'dead',
+    last_error = sqlc.arg(last_error),
+    updated_at = sqlc.arg(now)
+where
+    chat_id = sqlc.arg(chat_id)
+    and status = 'pending';
+
+-- name: RemoveOutboxMessagesBefore :execrows
+delete from outbox
+where
+    status != 'pending'
+    and updated_at < ?;
+
+-- name: AddOrIgnoreInactiveUser :exec
+insert or ignore into
+    inactive_users (user_id, inactive_since)
+values
+    (?, ?);
+
+-- name: RemoveInactiveUser :exec
+delete from inactive_users
+where
+    user_id = ?;
@@ -7,53 +7,494 @@ package sql
 
 import (
 	"context"
+	"database/sql"
+	"time"
 )
 
-const addOrIgnoreFeed = `-- name: AddOrIgnoreFeed :exec
+const addFilterRule = `-- name: AddFilterRule :exec
+insert into
+    filter_rules (
+        user_id,
+        subscription_id,
+        exclude,
+        is_regex,
+        case_sensitive,
+        pattern
+    )
+values
+    (?, ?, ?, ?, ?, ?)
+`
+
+type AddFilterRuleParams struct {
+	UserID         int64
+	SubscriptionID sql.NullInt64
+	Exclude        int64
+	IsRegex        int64
+	CaseSensitive  int64
+	Pattern        string
+}
+
+func (q *Queries) AddFilterRule(ctx context.Context, arg AddFilterRuleParams) error {
+	_, err := q.db.ExecContext(ctx, addFilterRule,
+		arg.UserID,
+		arg.SubscriptionID,
+		arg.Exclude,
+		arg.IsRegex,
+		arg.CaseSensitive,
+		arg.Pattern,
+	)
+	return err
+}
+
+const addOrIgnoreDeliveredPost = `-- name: AddOrIgnoreDeliveredPost :exec
+insert or ignore into
+    delivered_posts (user_id, feed_id, post_key, delivered_at)
+values
+    (?, ?, ?, ?)
+`
+
+type AddOrIgnoreDeliveredPostParams struct {
+	UserID      int64
+	FeedID      int64
+	PostKey     string
+	DeliveredAt time.Time
+}
+
+func (q *Queries) AddOrIgnoreDeliveredPost(ctx context.Context, arg AddOrIgnoreDeliveredPostParams) error {
+	_, err := q.db.ExecContext(ctx, addOrIgnoreDeliveredPost,
+		arg.UserID,
+		arg.FeedID,
+		arg.PostKey,
+		arg.DeliveredAt,
+	)
+	return err
+}
+
+const addOrIgnoreDigestSchedule = `-- name: AddOrIgnoreDigestSchedule :exec
 insert or ignore into
-    feeds (user_id, url, title)
+    digest_schedules (user_id, hour, weekdays)
 values
     (?, ?, ?)
 `
 
-type AddOrIgnoreFeedParams struct {
-	UserID int64
-	Url    string
-	Title  string
+type AddOrIgnoreDigestScheduleParams struct {
+	UserID   int64
+	Hour     int64
+	Weekdays int64
 }
 
-func (q *Queries) AddOrIgnoreFeed(ctx context.Context, arg AddOrIgnoreFeedParams) error {
-	_, err := q.db.ExecContext(ctx, addOrIgnoreFeed, arg.UserID, arg.Url, arg.Title)
+func (q *Queries) AddOrIgnoreDigestSchedule(ctx context.Context, arg AddOrIgnoreDigestScheduleParams) error {
+	_, err := q.db.ExecContext(ctx, addOrIgnoreDigestSchedule, arg.UserID, arg.Hour, arg.Weekdays)
 	return err
 }
 
-const getHourFeeds = `-- name: GetHourFeeds :many
+const addOrIgnoreInactiveUser = `-- name: AddOrIgnoreInactiveUser :exec
+insert or ignore into
+    inactive_users (user_id, inactive_since)
+values
+    (?, ?)
+`
+
+type AddOrIgnoreInactiveUserParams struct {
+	UserID        int64
+	InactiveSince time.Time
+}
+
+func (q *Queries) AddOrIgnoreInactiveUser(ctx context.Context, arg AddOrIgnoreInactiveUserParams) error {
+	_, err := q.db.ExecContext(ctx, addOrIgnoreInactiveUser, arg.UserID, arg.InactiveSince)
+	return err
+}
+
+const addOrIgnoreSource = `-- name: AddOrIgnoreSource :exec
+insert or ignore into
+    sources (url, title)
+values
+    (?, ?)
+`
+
+type AddOrIgnoreSourceParams struct {
+	Url   string
+	Title string
+}
+
+func (q *Queries) AddOrIgnoreSource(ctx context.Context, arg AddOrIgnoreSourceParams) error {
+	_, err := q.db.ExecContext(ctx, addOrIgnoreSource, arg.Url, arg.Title)
+	return err
+}
+
+const addOrIgnoreSubscription = `-- name: AddOrIgnoreSubscription :exec
+insert or ignore into
+    subscriptions (user_id, source_id, position)
+values
+    (
+        ?1,
+        ?2,
+        (
+            select
+                coalesce(max(position), 0) + 1
+            from
+                subscriptions
+            where
+                user_id = ?1
+        )
+    )
+`
+
+type AddOrIgnoreSubscriptionParams struct {
+	UserID   int64
+	SourceID int64
+}
+
+func (q *Queries) AddOrIgnoreSubscription(ctx context.Context, arg AddOrIgnoreSubscriptionParams) error {
+	_, err := q.db.ExecContext(ctx, addOrIgnoreSubscription, arg.UserID, arg.SourceID)
+	return err
+}
+
+const addOutboxMessage = `-- name: AddOutboxMessage :exec
+insert into
+    outbox (chat_id, text, next_attempt_at, created_at, updated_at)
+values
+    (
+        ?1,
+        ?2,
+        ?3,
+        ?3,
+        ?3
+    )
+`
+
+type AddOutboxMessageParams struct {
+	ChatID int64
+	Text   string
+	Now    time.Time
+}
+
+func (q *Queries) AddOutboxMessage(ctx context.Context, arg AddOutboxMessageParams) error {
+	_, err := q.db.ExecContext(ctx, addOutboxMessage, arg.ChatID, arg.Text, arg.Now)
+	return err
+}
+
+const getDefaultScheduleFeeds = `-- name: GetDefaultScheduleFeeds :many
+select
+    sub.id,
+    sub.user_id,
+    src.id as source_id,
+    src.url,
+    src.title,
+    sub.summarize,
+    sub.top_n,
+    sub.custom_title,
+    sub.position
+from
+    subscriptions as sub
+    join sources as src on src.id = sub.source_id
+    left join user_settings as us on us.user_id = sub.user_id
+where
+    sub.delivery_mode = 'digest'
+    and coalesce(us.timezone, 'UTC') = ?
+    and not exists (
+        select
+            1
+        from
+            inactive_users as iu
+        where
+            iu.user_id = sub.user_id
+    )
+    and not exists (
+        select
+            1
+        from
+            digest_schedules as ds
+        where
+            ds.user_id = sub.user_id
+    )
+`
+
+type GetDefaultScheduleFeedsRow struct {
+	ID          int64
+	UserID      int64
+	SourceID    int64
+	Url         string
+	Title       string
+	Summarize   int64
+	TopN        int64
+	CustomTitle string
+	Position    int64
+}
+
+func (q *Queries) GetDefaultScheduleFeeds(ctx context.Context, timezone string) ([]GetDefaultScheduleFeedsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getDefaultScheduleFeeds, timezone)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetDefaultScheduleFeedsRow
+	for rows.Next() {
+		var i GetDefaultScheduleFeedsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.SourceID,
+			&i.Url,
+			&i.Title,
+			&i.Summarize,
+			&i.TopN,
+			&i.CustomTitle,
+			&i.Position,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getDeliveredPostKeys = `-- name: GetDeliveredPostKeys :many
+select
+    post_key
+from
+    delivered_posts
+where
+    user_id = ?
+    and feed_id = ?
+`
+
+type GetDeliveredPostKeysParams struct {
+	UserID int64
+	FeedID int64
+}
+
+func (q *Queries) GetDeliveredPostKeys(ctx context.Context, arg GetDeliveredPostKeysParams) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getDeliveredPostKeys, arg.UserID, arg.FeedID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var post_key string
+		if err := rows.Scan(&post_key); err != nil {
+			return nil, err
+		}
+		items = append(items, post_key)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getDigestDeliveredAt = `-- name: GetDigestDeliveredAt :one
+select
+    delivered_at
+from
+    digest_deliveries
+where
+    user_id = ?
+`
+
+func (q *Queries) GetDigestDeliveredAt(ctx context.Context, userID int64) (time.Time, error) {
+	row := q.db.QueryRowContext(ctx, getDigestDeliveredAt, userID)
+	var delivered_at time.Time
+	err := row.Scan(&delivered_at)
+	return delivered_at, err
+}
+
+const getDigestSlotRuns = `-- name: GetDigestSlotRuns :many
+select
+    hour_utc,
+    slot_at
+from
+    digest_slot_runs
+`
+
+type GetDigestSlotRunsRow struct {
+	HourUtc int64
+	SlotAt  time.Time
+}
+
+func (q *Queries) GetDigestSlotRuns(ctx context.Context) ([]GetDigestSlotRunsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getDigestSlotRuns)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetDigestSlotRunsRow
+	for rows.Next() {
+		var i GetDigestSlotRunsRow
+		if err := rows.Scan(&i.HourUtc, &i.SlotAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getDueOutboxMessages = `-- name: GetDueOutboxMessages :many
+select
+    o.id,
+    o.chat_id,
+    o.text,
+    o.attempts
+from
+    outbox as o
+where
+    o.status = 'pending'
+    and o.next_attempt_at <= ?1
+    and not exists (
+        select
+            1
+        from
+            outbox as prev
+        where
+            prev.chat_id = o.chat_id
+            and prev.status = 'pending'
+            and prev.id < o.id
+            and prev.next_attempt_at > ?1
+    )
+order by
+    o.id
+limit
+    ?2
+`
+
+type GetDueOutboxMessagesParams struct {
+	Now   time.Time
+	Limit int64
+}
+
+type GetDueOutboxMessagesRow struct {
+	ID       int64
+	ChatID   int64
+	Text     string
+	Attempts int64
+}
+
+func (q *Queries) GetDueOutboxMessages(ctx context.Context, arg GetDueOutboxMessagesParams) ([]GetDueOutboxMessagesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getDueOutboxMessages, arg.Now, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetDueOutboxMessagesRow
+	for rows.Next() {
+		var i GetDueOutboxMessagesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.ChatID,
+			&i.Text,
+			&i.Attempts,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFeedsForHealthAlert = `-- name: GetFeedsForHealthAlert :many
 select
-    f.id,
-    f.user_id,
-    f.url,
-    f.title
+    sub.id,
+    sub.user_id,
+    src.id as source_id,
+    src.url,
+    src.title,
+    src.last_success_at,
+    src.last_error,
+    src.last_error_at,
+    src.consecutive_failures,
+    src.last_http_status,
+    src.failing_since,
+    sub.delivery_mode,
+    sub.summarize,
+    sub.top_n,
+    sub.custom_title,
+    sub.position
 from
-    feeds as f
-    left join user_settings as us on us.user_id = f.user_id
+    subscriptions as sub
+    join sources as src on src.id = sub.source_id
 where
-    us.auto_digest_hour_utc = ?
+    src.failing_since is not null
+    and sub.health_alert_sent = 0
+    and sub.delivery_mode != 'muted'
+    and not exists (
+        select
+            1
+        from
+            inactive_users as iu
+        where
+            iu.user_id = sub.user_id
+    )
+order by
+    sub.id
 `
 
-func (q *Queries) GetHourFeeds(ctx context.Context, autoDigestHourUtc int64) ([]Feed, error) {
-	rows, err := q.db.QueryContext(ctx, getHourFeeds, autoDigestHourUtc)
+type GetFeedsForHealthAlertRow struct {
+	ID                  int64
+	UserID              int64
+	SourceID            int64
+	Url                 string
+	Title               string
+	LastSuccessAt       sql.NullTime
+	LastError           string
+	LastErrorAt         sql.NullTime
+	ConsecutiveFailures int64
+	LastHttpStatus      int64
+	FailingSince        sql.NullTime
+	DeliveryMode        string
+	Summarize           int64
+	TopN                int64
+	CustomTitle         string
+	Position            int64
+}
+
+func (q *Queries) GetFeedsForHealthAlert(ctx context.Context) ([]GetFeedsForHealthAlertRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedsForHealthAlert)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Feed
+	var items []GetFeedsForHealthAlertRow
 	for rows.Next() {
-		var i Feed
+		var i GetFeedsForHealthAlertRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.UserID,
+			&i.SourceID,
 			&i.Url,
 			&i.Title,
+			&i.LastSuccessAt,
+			&i.LastError,
+			&i.LastErrorAt,
+			&i.ConsecutiveFailures,
+			&i.LastHttpStatus,
+			&i.FailingSince,
+			&i.DeliveryMode,
+			&i.Summarize,
+			&i.TopN,
+			&i.CustomTitle,
+			&i.Position,
 		); err != nil {
 			return nil, err
 		}
@@ -68,34 +509,81 @@ func (q *Queries) GetHourFeeds(ctx context.Context, autoDigestHourUtc int64) ([]
 	return items, nil
 }
 
-const getHourFeedsMidnightUTC = `-- name: GetHourFeedsMidnightUTC :many
+const getHourFeeds = `-- name: GetHourFeeds :many
 select
-    f.id,
-    f.user_id,
-    f.url,
-    f.title
+    sub.id,
+    sub.user_id,
+    src.id as source_id,
+    src.url,
+    src.title,
+    sub.summarize,
+    sub.top_n,
+    sub.custom_title,
+    sub.position
 from
-    feeds as f
-    left join user_settings as us on us.user_id = f.user_id
+    subscriptions as sub
+    join sources as src on src.id = sub.source_id
+    left join user_settings as us on us.user_id = sub.user_id
 where
-    us.user_id is null
-    or us.auto_digest_hour_utc = ?
+    sub.delivery_mode = 'digest'
+    and coalesce(us.timezone, 'UTC') = ?1
+    and not exists (
+        select
+            1
+        from
+            inactive_users as iu
+        where
+            iu.user_id = sub.user_id
+    )
+    and exists (
+        select
+            1
+        from
+            digest_schedules as ds
+        where
+            ds.user_id = sub.user_id
+            and ds.hour = ?2
+            and (ds.weekdays & ?3) != 0
+    )
 `
 
-func (q *Queries) GetHourFeedsMidnightUTC(ctx context.Context, autoDigestHourUtc int64) ([]Feed, error) {
-	rows, err := q.db.QueryContext(ctx, getHourFeedsMidnightUTC, autoDigestHourUtc)
+type GetHourFeedsParams struct {
+	Timezone    string
+	Hour        int64
+	WeekdayMask int64
+}
+
+type GetHourFeedsRow struct {
+	ID          int64
+	UserID      int64
+	SourceID    int64
+	Url         string
+	Title       string
+	Summarize   int64
+	TopN        int64
+	CustomTitle string
+	Position    int64
+}
+
+func (q *Queries) GetHourFeeds(ctx context.Context, arg GetHourFeedsParams) ([]GetHourFeedsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getHourFeeds, arg.Timezone, arg.Hour, arg.WeekdayMask)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Feed
+	var items []GetHourFeedsRow
 	for rows.Next() {
-		var i Feed
+		var i GetHourFeedsRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.UserID,
+			&i.SourceID,
 			&i.Url,
 			&i.Title,
+			&i.Summarize,
+			&i.TopN,
+			&i.CustomTitle,
+			&i.Position,
 		); err != nil {
 			return nil, err
 		}
@@ -110,33 +598,36 @@ func (q *Queries) GetHourFeedsMidnightUTC(ctx context.Context, autoDigestHourUtc
 	return items, nil
 }
 
-const getUserFeeds = `-- name: GetUserFeeds :many
+const getLatestSummaryCacheEntries = `-- name: GetLatestSummaryCacheEntries :many
 select
-    id,
-    url,
-    title
+    cache_key,
+    summary,
+    expires_at
 from
-    feeds
+    summary_cache
 where
-    user_id = ?
+    expires_at > ?
+order by
+    expires_at desc
+limit
+    ?
 `
 
-type GetUserFeedsRow struct {
-	ID    int64
-	Url   string
-	Title string
+type GetLatestSummaryCacheEntriesParams struct {
+	ExpiresAt time.Time
+	Limit     int64
 }
 
-func (q *Queries) GetUserFeeds(ctx context.Context, userID int64) ([]GetUserFeedsRow, error) {
-	rows, err := q.db.QueryContext(ctx, getUserFeeds, userID)
+func (q *Queries) GetLatestSummaryCacheEntries(ctx context.Context, arg GetLatestSummaryCacheEntriesParams) ([]SummaryCache, error) {
+	rows, err := q.db.QueryContext(ctx, getLatestSummaryCacheEntries, arg.ExpiresAt, arg.Limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []GetUserFeedsRow
+	var items []SummaryCache
 	for rows.Next() {
-		var i GetUserFeedsRow
-		if err := rows.Scan(&i.ID, &i.Url, &i.Title); err != nil {
+		var i SummaryCache
+		if err := rows.Scan(&i.CacheKey, &i.Summary, &i.ExpiresAt); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -150,68 +641,1015 @@ func (q *Queries) GetUserFeeds(ctx context.Context, userID int64) ([]GetUserFeed
 	return items, nil
 }
 
-const getUserSettings = `-- name: GetUserSettings :one
+const getRealtimeFeeds = `-- name: GetRealtimeFeeds :many
 select
-    user_id,
-    auto_digest_hour_utc
+    sub.id,
+    sub.user_id,
+    src.id as source_id,
+    src.url,
+    src.title,
+    sub.summarize,
+    sub.top_n,
+    sub.custom_title,
+    sub.position
 from
-    user_settings
+    subscriptions as sub
+    join sources as src on src.id = sub.source_id
 where
-    user_id = ?
+    sub.delivery_mode = 'realtime'
+    and not exists (
+        select
+            1
+        from
+            inactive_users as iu
+        where
+            iu.user_id = sub.user_id
+    )
+order by
+    sub.id
 `
 
-func (q *Queries) GetUserSettings(ctx context.Context, userID int64) (UserSetting, error) {
-	row := q.db.QueryRowContext(ctx, getUserSettings, userID)
-	var i UserSetting
-	err := row.Scan(&i.UserID, &i.AutoDigestHourUtc)
+type GetRealtimeFeedsRow struct {
+	ID          int64
+	UserID      int64
+	SourceID    int64
+	Url         string
+	Title       string
+	Summarize   int64
+	TopN        int64
+	CustomTitle string
+	Position    int64
+}
+
+func (q *Queries) GetRealtimeFeeds(ctx context.Context) ([]GetRealtimeFeedsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getRealtimeFeeds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetRealtimeFeedsRow
+	for rows.Next() {
+		var i GetRealtimeFeedsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.SourceID,
+			&i.Url,
+			&i.Title,
+			&i.Summarize,
+			&i.TopN,
+			&i.CustomTitle,
+			&i.Position,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getSourceByURL = `-- name: GetSourceByURL :one
+select
+    id,
+    url,
+    title
+from
+    sources
+where
+    url = ?
+`
+
+type GetSourceByURLRow struct {
+	ID    int64
+	Url   string
+	Title string
+}
+
+func (q *Queries) GetSourceByURL(ctx context.Context, url string) (GetSourceByURLRow, error) {
+	row := q.db.QueryRowContext(ctx, getSourceByURL, url)
+	var i GetSourceByURLRow
+	err := row.Scan(&i.ID, &i.Url, &i.Title)
 	return i, err
 }
 
-const removeFeed = `-- name: RemoveFeed :exec
-delete from feeds
+const getSourceHTTPCache = `-- name: GetSourceHTTPCache :one
+select
+    etag,
+    last_modified,
+    body_hash
+from
+    sources
 where
     id = ?
 `
 
-func (q *Queries) RemoveFeed(ctx context.Context, id int64) error {
-	_, err := q.db.ExecContext(ctx, removeFeed, id)
-	return err
+type GetSourceHTTPCacheRow struct {
+	Etag         string
+	LastModified string
+	BodyHash     string
 }
 
-const updateFeedTitle = `-- name: UpdateFeedTitle :exec
-update feeds
-set
-    title = ?
+func (q *Queries) GetSourceHTTPCache(ctx context.Context, id int64) (GetSourceHTTPCacheRow, error) {
+	row := q.db.QueryRowContext(ctx, getSourceHTTPCache, id)
+	var i GetSourceHTTPCacheRow
+	err := row.Scan(&i.Etag, &i.LastModified, &i.BodyHash)
+	return i, err
+}
+
+const getSummaryCacheEntry = `-- name: GetSummaryCacheEntry :one
+select
+    summary,
+    expires_at
+from
+    summary_cache
 where
-    id = ?
+    cache_key = ?
+    and expires_at > ?
 `
 
-type UpdateFeedTitleParams struct {
+type GetSummaryCacheEntryParams struct {
+	CacheKey  string
+	ExpiresAt time.Time
+}
+
+type GetSummaryCacheEntryRow struct {
+	Summary   string
+	ExpiresAt time.Time
+}
+
+func (q *Queries) GetSummaryCacheEntry(ctx context.Context, arg GetSummaryCacheEntryParams) (GetSummaryCacheEntryRow, error) {
+	row := q.db.QueryRowContext(ctx, getSummaryCacheEntry, arg.CacheKey, arg.ExpiresAt)
+	var i GetSummaryCacheEntryRow
+	err := row.Scan(&i.Summary, &i.ExpiresAt)
+	return i, err
+}
+
+const getUserDigestSchedules = `-- name: GetUserDigestSchedules :many
+select
+    id,
+    user_id,
+    hour,
+    weekdays
+from
+    digest_schedules
+where
+    user_id = ?
+order by
+    hour,
+    weekdays desc
+`
+
+func (q *Queries) GetUserDigestSchedules(ctx context.Context, userID int64) ([]DigestSchedule, error) {
+	rows, err := q.db.QueryContext(ctx, getUserDigestSchedules, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []DigestSchedule
+	for rows.Next() {
+		var i DigestSchedule
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.Hour,
+			&i.Weekdays,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserFeed = `-- name: GetUserFeed :one
+select
+    sub.id,
+    sub.user_id,
+    src.id as source_id,
+    src.url,
+    src.title,
+    src.last_success_at,
+    src.last_error,
+    src.last_error_at,
+    src.consecutive_failures,
+    src.last_http_status,
+    src.failing_since,
+    sub.delivery_mode,
+    sub.summarize,
+    sub.top_n,
+    sub.custom_title,
+    sub.position
+from
+    subscriptions as sub
+    join sources as src on src.id = sub.source_id
+where
+    sub.id = ?
+    and sub.user_id = ?
+`
+
+type GetUserFeedParams struct {
+	ID     int64
+	UserID int64
+}
+
+type GetUserFeedRow struct {
+	ID                  int64
+	UserID              int64
+	SourceID            int64
+	Url                 string
+	Title               string
+	LastSuccessAt       sql.NullTime
+	LastError           string
+	LastErrorAt         sql.NullTime
+	ConsecutiveFailures int64
+	LastHttpStatus      int64
+	FailingSince        sql.NullTime
+	DeliveryMode        string
+	Summarize           int64
+	TopN                int64
+	CustomTitle         string
+	Position            int64
+}
+
+func (q *Queries) GetUserFeed(ctx context.Context, arg GetUserFeedParams) (GetUserFeedRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserFeed, arg.ID, arg.UserID)
+	var i GetUserFeedRow
+	err := row.Scan(
+		&i.ID,
+		&i.UserID,
+		&i.SourceID,
+		&i.Url,
+		&i.Title,
+		&i.LastSuccessAt,
+		&i.LastError,
+		&i.LastErrorAt,
+		&i.ConsecutiveFailures,
+		&i.LastHttpStatus,
+		&i.FailingSince,
+		&i.DeliveryMode,
+		&i.Summarize,
+		&i.TopN,
+		&i.CustomTitle,
+		&i.Position,
+	)
+	return i, err
+}
+
+const getUserFeeds = `-- name: GetUserFeeds :many
+select
+    sub.id,
+    src.id as source_id,
+    src.url,
+    src.title,
+    src.last_success_at,
+    src.last_error,
+    src.last_error_at,
+    src.consecutive_failures,
+    src.last_http_status,
+    src.failing_since,
+    sub.delivery_mode,
+    sub.summarize,
+    sub.top_n,
+    sub.custom_title,
+    sub.position
+from
+    subscriptions as sub
+    join sources as src on src.id = sub.source_id
+where
+    sub.user_id = ?
+order by
+    sub.position,
+    sub.id
+`
+
+type GetUserFeedsRow struct {
+	ID                  int64
+	SourceID            int64
+	Url                 string
+	Title               string
+	LastSuccessAt       sql.NullTime
+	LastError           string
+	LastErrorAt         sql.NullTime
+	ConsecutiveFailures int64
+	LastHttpStatus      int64
+	FailingSince        sql.NullTime
+	DeliveryMode        string
+	Summarize           int64
+	TopN                int64
+	CustomTitle         string
+	Position            int64
+}
+
+func (q *Queries) GetUserFeeds(ctx context.Context, userID int64) ([]GetUserFeedsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUserFeeds, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUserFeedsRow
+	for rows.Next() {
+		var i GetUserFeedsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.SourceID,
+			&i.Url,
+			&i.Title,
+			&i.LastSuccessAt,
+			&i.LastError,
+			&i.LastErrorAt,
+			&i.ConsecutiveFailures,
+			&i.LastHttpStatus,
+			&i.FailingSince,
+			&i.DeliveryMode,
+			&i.Summarize,
+			&i.TopN,
+			&i.CustomTitle,
+			&i.Position,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserFilterRules = `-- name: GetUserFilterRules :many
+select
+    fr.id,
+    fr.user_id,
+    fr.subscription_id,
+    fr.exclude,
+    fr.is_regex,
+    fr.case_sensitive,
+    fr.pattern,
+    coalesce(nullif(sub.custom_title, ''), src.title, '') as feed_title
+from
+    filter_rules as fr
+    left join subscriptions as sub on sub.id = fr.subscription_id
+    left join sources as src on src.id = sub.source_id
+where
+    fr.user_id = ?
+order by
+    fr.id
+`
+
+type GetUserFilterRulesRow struct {
+	ID             int64
+	UserID         int64
+	SubscriptionID sql.NullInt64
+	Exclude        int64
+	IsRegex        int64
+	CaseSensitive  int64
+	Pattern        string
+	FeedTitle      string
+}
+
+func (q *Queries) GetUserFilterRules(ctx context.Context, userID int64) ([]GetUserFilterRulesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUserFilterRules, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUserFilterRulesRow
+	for rows.Next() {
+		var i GetUserFilterRulesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.UserID,
+			&i.SubscriptionID,
+			&i.Exclude,
+			&i.IsRegex,
+			&i.CaseSensitive,
+			&i.Pattern,
+			&i.FeedTitle,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserSettings = `-- name: GetUserSettings :one
+select
+    user_id,
+    timezone
+from
+    user_settings
+where
+    user_id = ?
+`
+
+func (q *Queries) GetUserSettings(ctx context.Context, userID int64) (UserSetting, error) {
+	row := q.db.QueryRowContext(ctx, getUserSettings, userID)
+	var i UserSetting
+	err := row.Scan(&i.UserID, &i.Timezone)
+	return i, err
+}
+
+const getUserSettingsTimezones = `-- name: GetUserSettingsTimezones :many
+select distinct
+    timezone
+from
+    user_settings
+`
+
+func (q *Queries) GetUserSettingsTimezones(ctx context.Context) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getUserSettingsTimezones)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var timezone string
+		if err := rows.Scan(&timezone); err != nil {
+			return nil, err
+		}
+		items = append(items, timezone)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markChatOutboxMessagesDead = `-- name: MarkChatOutboxMessagesDead :execrows
+update outbox
+set
+    status = 'dead',
+    last_error = ?1,
+    updated_at = ?2
+where
+    chat_id = ?3
+    and status = 'pending'
+`
+
+type MarkChatOutboxMessagesDeadParams struct {
+	LastError string
+	Now       time.Time
+	ChatID    int64
+}
+
+func (q *Queries) MarkChatOutboxMessagesDead(ctx context.Context, arg MarkChatOutboxMessagesDeadParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markChatOutboxMessagesDead, arg.LastError, arg.Now, arg.ChatID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const markOutboxMessageDead = `-- name: MarkOutboxMessageDead :exec
+update outbox
+set
+    status = 'dead',
+    attempts = attempts + 1,
+    last_error = ?1,
+    updated_at = ?2
+where
+    id = ?3
+`
+
+type MarkOutboxMessageDeadParams struct {
+	LastError string
+	Now       time.Time
+	ID        int64
+}
+
+func (q *Queries) MarkOutboxMessageDead(ctx context.Context, arg MarkOutboxMessageDeadParams) error {
+	_, err := q.db.ExecContext(ctx, markOutboxMessageDead, arg.LastError, arg.Now, arg.ID)
+	return err
+}
+
+const markOutboxMessageSent = `-- name: MarkOutboxMessageSent :exec
+update outbox
+set
+    status = 'sent',
+    attempts = attempts + 1,
+    last_error = '',
+    updated_at = ?1
+where
+    id = ?2
+`
+
+type MarkOutboxMessageSentParams struct {
+	Now time.Time
+	ID  int64
+}
+
+func (q *Queries) MarkOutboxMessageSent(ctx context.Context, arg MarkOutboxMessageSentParams) error {
+	_, err := q.db.ExecContext(ctx, markOutboxMessageSent, arg.Now, arg.ID)
+	return err
+}
+
+const markSubscriptionHealthAlertSent = `-- name: MarkSubscriptionHealthAlertSent :exec
+update subscriptions
+set
+    health_alert_sent = 1
+where
+    id = ?
+`
+
+func (q *Queries) MarkSubscriptionHealthAlertSent(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, markSubscriptionHealthAlertSent, id)
+	return err
+}
+
+const recordSourceFailure = `-- name: RecordSourceFailure :exec
+update sources
+set
+    last_error = ?,
+    last_error_at = ?,
+    last_http_status = ?,
+    consecutive_failures = consecutive_failures + 1,
+    failing_since = coalesce(failing_since, ?)
+where
+    id = ?
+`
+
+type RecordSourceFailureParams struct {
+	LastError      string
+	LastErrorAt    sql.NullTime
+	LastHttpStatus int64
+	FailingSince   sql.NullTime
+	ID             int64
+}
+
+func (q *Queries) RecordSourceFailure(ctx context.Context, arg RecordSourceFailureParams) error {
+	_, err := q.db.ExecContext(ctx, recordSourceFailure,
+		arg.LastError,
+		arg.LastErrorAt,
+		arg.LastHttpStatus,
+		arg.FailingSince,
+		arg.ID,
+	)
+	return err
+}
+
+const recordSourceSuccess = `-- name: RecordSourceSuccess :exec
+update sources
+set
+    last_success_at = ?,
+    last_http_status = ?,
+    consecutive_failures = 0,
+    failing_since = null
+where
+    id = ?
+`
+
+type RecordSourceSuccessParams struct {
+	LastSuccessAt  sql.NullTime
+	LastHttpStatus int64
+	ID             int64
+}
+
+func (q *Queries) RecordSourceSuccess(ctx context.Context, arg RecordSourceSuccessParams) error {
+	_, err := q.db.ExecContext(ctx, recordSourceSuccess, arg.LastSuccessAt, arg.LastHttpStatus, arg.ID)
+	return err
+}
+
+const removeDeliveredPostsBefore = `-- name: RemoveDeliveredPostsBefore :execrows
+delete from delivered_posts
+where
+    delivered_at < ?
+`
+
+func (q *Queries) RemoveDeliveredPostsBefore(ctx context.Context, deliveredAt time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, removeDeliveredPostsBefore, deliveredAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const removeDigestSchedule = `-- name: RemoveDigestSchedule :execrows
+delete from digest_schedules
+where
+    id = ?
+    and user_id = ?
+`
+
+type RemoveDigestScheduleParams struct {
+	ID     int64
+	UserID int64
+}
+
+func (q *Queries) RemoveDigestSchedule(ctx context.Context, arg RemoveDigestScheduleParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, removeDigestSchedule, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const removeFilterRule = `-- name: RemoveFilterRule :execrows
+delete from filter_rules
+where
+    id = ?
+    and user_id = ?
+`
+
+type RemoveFilterRuleParams struct {
+	ID     int64
+	UserID int64
+}
+
+func (q *Queries) RemoveFilterRule(ctx context.Context, arg RemoveFilterRuleParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, removeFilterRule, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const removeInactiveUser = `-- name: RemoveInactiveUser :exec
+delete from inactive_users
+where
+    user_id = ?
+`
+
+func (q *Queries) RemoveInactiveUser(ctx context.Context, userID int64) error {
+	_, err := q.db.ExecContext(ctx, removeInactiveUser, userID)
+	return err
+}
+
+const removeOrphanSources = `-- name: RemoveOrphanSources :exec
+delete from sources
+where
+    id not in (
+        select
+            source_id
+        from
+            subscriptions
+    )
+`
+
+func (q *Queries) RemoveOrphanSources(ctx context.Context) error {
+	_, err := q.db.ExecContext(ctx, removeOrphanSources)
+	return err
+}
+
+const removeOutboxMessagesBefore = `-- name: RemoveOutboxMessagesBefore :execrows
+delete from outbox
+where
+    status != 'pending'
+    and updated_at < ?
+`
+
+func (q *Queries) RemoveOutboxMessagesBefore(ctx context.Context, updatedAt time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, removeOutboxMessagesBefore, updatedAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const removeSubscription = `-- name: RemoveSubscription :exec
+delete from subscriptions
+where
+    id = ?
+`
+
+func (q *Queries) RemoveSubscription(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, removeSubscription, id)
+	return err
+}
+
+const removeSubscriptionFilterRules = `-- name: RemoveSubscriptionFilterRules :exec
+delete from filter_rules
+where
+    subscription_id = ?
+`
+
+func (q *Queries) RemoveSubscriptionFilterRules(ctx context.Context, subscriptionID sql.NullInt64) error {
+	_, err := q.db.ExecContext(ctx, removeSubscriptionFilterRules, subscriptionID)
+	return err
+}
+
+const removeSummaryCacheEntriesBefore = `-- name: RemoveSummaryCacheEntriesBefore :execrows
+delete from summary_cache
+where
+    expires_at <= ?
+`
+
+func (q *Queries) RemoveSummaryCacheEntriesBefore(ctx context.Context, expiresAt time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, removeSummaryCacheEntriesBefore, expiresAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const resetSubscriptionHealthAlerts = `-- name: ResetSubscriptionHealthAlerts :exec
+update subscriptions
+set
+    health_alert_sent = 0
+where
+    source_id = ?
+`
+
+func (q *Queries) ResetSubscriptionHealthAlerts(ctx context.Context, sourceID int64) error {
+	_, err := q.db.ExecContext(ctx, resetSubscriptionHealthAlerts, sourceID)
+	return err
+}
+
+const retryOutboxMessage = `-- name: RetryOutboxMessage :exec
+update outbox
+set
+    attempts = attempts + 1,
+    last_error = ?1,
+    next_attempt_at = ?2,
+    updated_at = ?3
+where
+    id = ?4
+`
+
+type RetryOutboxMessageParams struct {
+	LastError     string
+	NextAttemptAt time.Time
+	Now           time.Time
+	ID            int64
+}
+
+func (q *Queries) RetryOutboxMessage(ctx context.Context, arg RetryOutboxMessageParams) error {
+	_, err := q.db.ExecContext(ctx, retryOutboxMessage,
+		arg.LastError,
+		arg.NextAttemptAt,
+		arg.Now,
+		arg.ID,
+	)
+	return err
+}
+
+const updateSourceHTTPCache = `-- name: UpdateSourceHTTPCache :exec
+update sources
+set
+    etag = ?,
+    last_modified = ?,
+    body_hash = ?
+where
+    id = ?
+`
+
+type UpdateSourceHTTPCacheParams struct {
+	Etag         string
+	LastModified string
+	BodyHash     string
+	ID           int64
+}
+
+func (q *Queries) UpdateSourceHTTPCache(ctx context.Context, arg UpdateSourceHTTPCacheParams) error {
+	_, err := q.db.ExecContext(ctx, updateSourceHTTPCache,
+		arg.Etag,
+		arg.LastModified,
+		arg.BodyHash,
+		arg.ID,
+	)
+	return err
+}
+
+const updateSourceTitle = `-- name: UpdateSourceTitle :exec
+update sources
+set
+    title = ?
+where
+    id = ?
+`
+
+type UpdateSourceTitleParams struct {
 	Title string
 	ID    int64
 }
 
-func (q *Queries) UpdateFeedTitle(ctx context.Context, arg UpdateFeedTitleParams) error {
-	_, err := q.db.ExecContext(ctx, updateFeedTitle, arg.Title, arg.ID)
+func (q *Queries) UpdateSourceTitle(ctx context.Context, arg UpdateSourceTitleParams) error {
+	_, err := q.db.ExecContext(ctx, updateSourceTitle, arg.Title, arg.ID)
+	return err
+}
+
+const updateSubscriptionCustomTitle = `-- name: UpdateSubscriptionCustomTitle :execrows
+update subscriptions
+set
+    custom_title = ?
+where
+    id = ?
+    and user_id = ?
+`
+
+type UpdateSubscriptionCustomTitleParams struct {
+	CustomTitle string
+	ID          int64
+	UserID      int64
+}
+
+func (q *Queries) UpdateSubscriptionCustomTitle(ctx context.Context, arg UpdateSubscriptionCustomTitleParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateSubscriptionCustomTitle, arg.CustomTitle, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateSubscriptionDeliveryMode = `-- name: UpdateSubscriptionDeliveryMode :execrows
+update subscriptions
+set
+    delivery_mode = ?
+where
+    id = ?
+    and user_id = ?
+`
+
+type UpdateSubscriptionDeliveryModeParams struct {
+	DeliveryMode string
+	ID           int64
+	UserID       int64
+}
+
+func (q *Queries) UpdateSubscriptionDeliveryMode(ctx context.Context, arg UpdateSubscriptionDeliveryModeParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateSubscriptionDeliveryMode, arg.DeliveryMode, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateSubscriptionPosition = `-- name: UpdateSubscriptionPosition :execrows
+update subscriptions
+set
+    position = ?
+where
+    id = ?
+    and user_id = ?
+`
+
+type UpdateSubscriptionPositionParams struct {
+	Position int64
+	ID       int64
+	UserID   int64
+}
+
+func (q *Queries) UpdateSubscriptionPosition(ctx context.Context, arg UpdateSubscriptionPositionParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateSubscriptionPosition, arg.Position, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateSubscriptionSummarize = `-- name: UpdateSubscriptionSummarize :execrows
+update subscriptions
+set
+    summarize = ?
+where
+    id = ?
+    and user_id = ?
+`
+
+type UpdateSubscriptionSummarizeParams struct {
+	Summarize int64
+	ID        int64
+	UserID    int64
+}
+
+func (q *Queries) UpdateSubscriptionSummarize(ctx context.Context, arg UpdateSubscriptionSummarizeParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateSubscriptionSummarize, arg.Summarize, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateSubscriptionTopN = `-- name: UpdateSubscriptionTopN :execrows
+update subscriptions
+set
+    top_n = ?
+where
+    id = ?
+    and user_id = ?
+`
+
+type UpdateSubscriptionTopNParams struct {
+	TopN   int64
+	ID     int64
+	UserID int64
+}
+
+func (q *Queries) UpdateSubscriptionTopN(ctx context.Context, arg UpdateSubscriptionTopNParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateSubscriptionTopN, arg.TopN, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const upsertDigestDelivery = `-- name: UpsertDigestDelivery :exec
+insert into
+    digest_deliveries (user_id, delivered_at)
+values
+    (?, ?)
+on conflict (user_id) do update
+set
+    delivered_at = excluded.delivered_at
+`
+
+type UpsertDigestDeliveryParams struct {
+	UserID      int64
+	DeliveredAt time.Time
+}
+
+func (q *Queries) UpsertDigestDelivery(ctx context.Context, arg UpsertDigestDeliveryParams) error {
+	_, err := q.db.ExecContext(ctx, upsertDigestDelivery, arg.UserID, arg.DeliveredAt)
+	return err
+}
+
+const upsertDigestSlotRun = `-- name: UpsertDigestSlotRun :exec
+insert into
+    digest_slot_runs (hour_utc, slot_at, completed_at)
+values
+    (?, ?, ?)
+on conflict (hour_utc) do update
+set
+    slot_at = excluded.slot_at,
+    completed_at = excluded.completed_at
+where
+    excluded.slot_at > digest_slot_runs.slot_at
+`
+
+type UpsertDigestSlotRunParams struct {
+	HourUtc     int64
+	SlotAt      time.Time
+	CompletedAt time.Time
+}
+
+func (q *Queries) UpsertDigestSlotRun(ctx context.Context, arg UpsertDigestSlotRunParams) error {
+	_, err := q.db.ExecContext(ctx, upsertDigestSlotRun, arg.HourUtc, arg.SlotAt, arg.CompletedAt)
+	return err
+}
+
+const upsertSummaryCacheEntry = `-- name: UpsertSummaryCacheEntry :exec
+insert into
+    summary_cache (cache_key, summary, expires_at)
+values
+    (?, ?, ?)
+on conflict (cache_key) do update
+set
+    summary = excluded.summary,
+    expires_at = excluded.expires_at
+`
+
+type UpsertSummaryCacheEntryParams struct {
+	CacheKey  string
+	Summary   string
+	ExpiresAt time.Time
+}
+
+func (q *Queries) UpsertSummaryCacheEntry(ctx context.Context, arg UpsertSummaryCacheEntryParams) error {
+	_, err := q.db.ExecContext(ctx, upsertSummaryCacheEntry, arg.CacheKey, arg.Summary, arg.ExpiresAt)
 	return err
 }
 
 const upsertUserSettings = `-- name: UpsertUserSettings :exec
 insert into
-    user_settings (user_id, auto_digest_hour_utc)
+    user_settings (user_id, timezone)
 values
     (?, ?)
 on conflict (user_id) do update
 set
-    auto_digest_hour_utc = excluded.auto_digest_hour_utc
+    timezone = excluded.timezone
 `
 
 type UpsertUserSettingsParams struct {
-	UserID            int64
-	AutoDigestHourUtc int64
+	UserID   int64
+	Timezone string
 }
 
 func (q *Queries) UpsertUserSettings(ctx context.Context, arg UpsertUserSettingsParams) error {
-	_, err := q.db.ExecContext(ctx, upsertUserSettings, arg.UserID, arg.AutoDigestHourUtc)
+	_, err := q.db.ExecContext(ctx, upsertUserSettings, arg.UserID, arg.Timezone)
 	return err
 }
@@ -1,31 +1,178 @@
 package domain
 
+import "time"
+
+const (
+	DefaultTimezone       = "UTC"
+	DefaultAutoDigestHour = 0
+
+	// Digest schedule weekday masks use time.Weekday as the bit index.
+	EveryDayMask = 0b1111111
+	WorkdaysMask = 0b0111110
+	WeekendsMask = 0b1000001
+)
+
+// DeliveryMode controls how posts of a subscription reach the user.
+type DeliveryMode string
+
+const (
+	DeliveryModeDigest   DeliveryMode = "digest"
+	DeliveryModeRealtime DeliveryMode = "realtime"
+	DeliveryModeMuted    DeliveryMode = "muted"
+)
+
+// MediaKind is the attachment of a Telegram post, empty for text posts.
+type MediaKind string
+
+const (
+	MediaKindPhoto    MediaKind = "photo"
+	MediaKindVideo    MediaKind = "video"
+	MediaKindDocument MediaKind = "document"
+	MediaKindPoll     MediaKind = "poll"
+	MediaKindVoice    MediaKind = "voice"
+)
+
+func WeekdayMask(weekday time.Weekday) int64 {
+	return 1 << weekday
+}
+
 type Feed struct {
 	URL   string
 	Title string
 }
 
+type FeedChoice struct {
+	PageURL string
+	Feeds   []Feed
+}
+
+type Source struct {
+	ID    int64
+	URL   string
+	Title string
+	// SummarizeItems is set when a subscriber wants summaries of feed items.
+	SummarizeItems bool
+}
+
+type SourceHTTPCache struct {
+	ETag         string
+	LastModified string
+	BodyHash     string
+}
+
+type FeedHealth struct {
+	LastSuccessAt       time.Time
+	LastError           string
+	LastErrorAt         time.Time
+	ConsecutiveFailures int64
+	LastHTTPStatus      int64
+	FailingSince        time.Time
+}
+
 type UserFeed struct {
-	ID     int64
-	UserID int64
-	URL    string
-	Title  string
+	ID           int64
+	UserID       int64
+	SourceID     int64
+	URL          string
+	Title        string
+	Health       FeedHealth
+	DeliveryMode DeliveryMode
+	// SummarizeItems enables summaries of RSS, Atom, and JSON feed items.
+	SummarizeItems bool
+	// TopN caps the feed's digest to its N most engaging posts; 0 shows all.
+	TopN int64
+	// CustomTitle is the user's label for the feed, kept over the source title.
+	CustomTitle string
+	// Position orders feeds in the list and digest groups.
+	Position int64
+}
+
+// DisplayTitle returns the custom title of the feed, falling back to the
+// source title and then the URL.
+func (f UserFeed) DisplayTitle() string {
+	if f.CustomTitle != "" {
+		return f.CustomTitle
+	}
+	if f.Title != "" {
+		return f.Title
+	}
+
+	return f.URL
+}
+
+// TelegramPostMeta is what the channel preview page shows about a Telegram
+// post besides its text. It's empty for feed items.
+type TelegramPostMeta struct {
+	MediaKind MediaKind
+	Views     int64
+	// ForwardedFrom is the name of the channel or user the post was forwarded from.
+	ForwardedFrom string
+	// ReplyTo is the text of the post this one replies to.
+	ReplyTo          string
+	LinkPreviewTitle string
+	Edited           bool
+	// Links are URLs linked from the post text and its link preview.
+	Links []string
 }
 
 type Post struct {
-	Title     string
+	Title string
+	// Summary is a one-line summary of a feed item; Telegram posts keep their
+	// summary in Title as they have no title.
+	Summary   string
+	Text      string
 	URL       string
+	GUID      string
+	Published time.Time
 	FeedID    int64
 	FeedTitle string
 	FeedURL   string
+	// FeedPosition is UserFeed.Position of the subscription the post belongs to.
+	FeedPosition int64
+	Telegram     TelegramPostMeta
 }
 
 type UserSettings struct {
-	UserID            int64
-	AutoDigestHourUTC int64
+	UserID   int64
+	Timezone string
+}
+
+type DigestSchedule struct {
+	ID       int64
+	UserID   int64
+	Hour     int64
+	Weekdays int64
 }
 
 type UserPosts struct {
 	UserID int64
 	Posts  []Post
+	// Hidden are posts left out of the digest by UserFeed.TopN.
+	Hidden   []Post
+	Filtered []Post
+}
+
+type FilterRule struct {
+	ID            int64
+	UserID        int64
+	FeedID        int64
+	FeedTitle     string
+	Exclude       bool
+	Regex         bool
+	CaseSensitive bool
+	Pattern       string
+}
+
+type SummaryCacheEntry struct {
+	Key       string
+	Summary   string
+	ExpiresAt time.Time
+}
+
+// OutboxMessage is a rendered digest message waiting to be sent.
+type OutboxMessage struct {
+	ID       int64
+	ChatID   int64
+	Text     string
+	Attempts int64
 }
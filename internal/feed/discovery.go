@@ -0,0 +1,168 @@
+package feed
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"telekilogram/internal/domain"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// FindFeedsAtURL validates rawURL as a feed and, when it turns out to be an
+// ordinary web page, discovers the feeds it links to. Unlike FindValidFeeds,
+// it takes a single URL of either HTTP scheme, as OPML outlines have.
+func (f *Fetcher) FindFeedsAtURL(ctx context.Context, rawURL string) ([]domain.Feed, error) {
+	if ok, _ := isTelegramChannelURL(rawURL); ok {
+		feed, err := f.validateFeed(ctx, rawURL)
+		if err != nil {
+			return nil, fmt.Errorf("validate feed: %w", err)
+		}
+
+		return []domain.Feed{*feed}, nil
+	}
+
+	pageURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse URL: %w", err)
+	}
+
+	resp, err := f.parser.fetchFeed(ctx, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetch feed (URL = %s): %w", rawURL, err)
+	}
+
+	feed, parseErr := f.feedFromBody(ctx, rawURL, resp.body)
+	if parseErr == nil {
+		return []domain.Feed{*feed}, nil
+	}
+
+	candidates := discoverFeedLinks(resp.body, pageURL)
+	feeds, err := f.validateFeedCandidates(ctx, candidates)
+
+	// Pages may advertise stale or broken feed links, so common feed paths
+	// are tried when none of the advertised ones is a feed.
+	if len(feeds) == 0 {
+		commonCandidates := commonFeedURLs(pageURL)
+		candidates = append(candidates, commonCandidates...)
+
+		var commonErr error
+		feeds, commonErr = f.validateFeedCandidates(ctx, commonCandidates)
+		err = errors.Join(err, commonErr)
+	}
+
+	if len(feeds) == 0 {
+		return nil, errors.Join(fmt.Errorf("parse feed: %w", parseErr), err)
+	}
+
+	f.log.InfoContext(ctx, "Feeds are discovered",
+		"pageURL", rawURL,
+		"candidateCount", len(candidates),
+		"feedCount", len(feeds))
+
+	return feeds, nil
+}
+
+func (f *Fetcher) validateFeedCandidates(ctx context.Context, candidates []string) ([]domain.Feed, error) {
+	var feeds []domain.Feed
+	var errs []error
+	seen := make(map[string]struct{}, len(candidates))
+
+	for _, candidate := range candidates {
+		feed, err := f.validateFeed(ctx, candidate)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("validate feed: %w", err))
+			continue
+		}
+
+		if _, ok := seen[feed.URL]; ok {
+			continue
+		}
+
+		seen[feed.URL] = struct{}{}
+		feeds = append(feeds, *feed)
+	}
+
+	return feeds, errors.Join(errs...)
+}
+
+func discoverFeedLinks(body []byte, pageURL *url.URL) []string {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil
+	}
+
+	baseURL := pageURL
+	if href, ok := doc.Find("base[href]").First().Attr("href"); ok {
+		if parsed, parseErr := pageURL.Parse(strings.TrimSpace(href)); parseErr == nil {
+			baseURL = parsed
+		}
+	}
+
+	var links []string
+	seen := make(map[string]struct{})
+
+	doc.Find("link[rel][href]").Each(func(_ int, s *goquery.Selection) {
+		if !hasRelAlternate(s.AttrOr("rel", "")) {
+			return
+		}
+
+		linkType := strings.ToLower(strings.TrimSpace(s.AttrOr("type", "")))
+		if mediaType, _, found := strings.Cut(linkType, ";"); found {
+			linkType = strings.TrimSpace(mediaType)
+		}
+		if !isDiscoverableFeedType(linkType) {
+			return
+		}
+
+		resolved, parseErr := baseURL.Parse(strings.TrimSpace(s.AttrOr("href", "")))
+		if parseErr != nil || (resolved.Scheme != "https" && resolved.Scheme != "http") {
+			return
+		}
+
+		link := resolved.String()
+		if _, ok := seen[link]; ok {
+			return
+		}
+
+		seen[link] = struct{}{}
+		links = append(links, link)
+	})
+
+	return links
+}
+
+func hasRelAlternate(rel string) bool {
+	for _, value := range strings.Fields(rel) {
+		if strings.EqualFold(value, "alternate") {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isDiscoverableFeedType(linkType string) bool {
+	switch linkType {
+	case "application/rss+xml", "application/atom+xml", "application/feed+json":
+		return true
+	default:
+		return false
+	}
+}
+
+// commonFeedURLs returns well-known feed locations on the site root, which are
+// tried when a page doesn't advertise its feeds.
+func commonFeedURLs(pageURL *url.URL) []string {
+	paths := []string{"/feed", "/rss.xml", "/atom.xml", "/index.xml"}
+	urls := make([]string, 0, len(paths))
+
+	for _, path := range paths {
+		urls = append(urls, (&url.URL{Scheme: pageURL.Scheme, Host: pageURL.Host, Path: path}).String())
+	}
+
+	return urls
+}
@@ -0,0 +1,208 @@
+package feed
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+)
+
+func newTestDiscoveryFetcher() *Fetcher {
+	return &Fetcher{
+		parser:    newTestHTTPParser(0),
+		libParser: gofeed.NewParser(),
+		log:       slog.Default(),
+	}
+}
+
+func TestDiscoverFeedLinks(t *testing.T) {
+	pageURL, err := url.Parse("https://example.com/blog/post")
+	if err != nil {
+		t.Fatalf("parse URL: %v", err)
+	}
+
+	body := []byte(`<html><head>
+<link rel="alternate" type="application/rss+xml" href="/feed.xml">
+<link rel="Alternate" type="application/atom+xml; charset=utf-8" href="atom.xml">
+<link rel="alternate" type="application/feed+json" href="https://cdn.example.com/feed.json">
+<link rel="alternate" type="application/rss+xml" href="/feed.xml">
+<link rel="alternate" type="text/html" href="/en">
+<link rel="stylesheet" type="application/rss+xml" href="/style.css">
+<link rel="alternate" type="application/rss+xml" href="javascript:alert(1)">
+</head></html>`)
+
+	got := discoverFeedLinks(body, pageURL)
+	want := []string{
+		"https://example.com/feed.xml",
+		"https://example.com/blog/atom.xml",
+		"https://cdn.example.com/feed.json",
+	}
+
+	if !slices.Equal(got, want) {
+		t.Fatalf("discoverFeedLinks() = %v, want %v", got, want)
+	}
+}
+
+func TestDiscoverFeedLinksHonorsBaseHref(t *testing.T) {
+	pageURL, err := url.Parse("https://example.com/blog/post")
+	if err != nil {
+		t.Fatalf("parse URL: %v", err)
+	}
+
+	body := []byte(`<html><head>
+<base href="https://static.example.com/site/">
+<link rel="alternate" type="application/rss+xml" href="rss.xml">
+</head></html>`)
+
+	got := discoverFeedLinks(body, pageURL)
+	want := []string{"https://static.example.com/site/rss.xml"}
+
+	if !slices.Equal(got, want) {
+		t.Fatalf("discoverFeedLinks() = %v, want %v", got, want)
+	}
+}
+
+func TestCommonFeedURLs(t *testing.T) {
+	pageURL, err := url.Parse("https://example.com/blog/post?x=1")
+	if err != nil {
+		t.Fatalf("parse URL: %v", err)
+	}
+
+	got := commonFeedURLs(pageURL)
+	want := []string{
+		"https://example.com/feed",
+		"https://example.com/rss.xml",
+		"https://example.com/atom.xml",
+		"https://example.com/index.xml",
+	}
+
+	if !slices.Equal(got, want) {
+		t.Fatalf("commonFeedURLs() = %v, want %v", got, want)
+	}
+}
+
+func TestFindFeedsAtURLReturnsDirectFeed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(testRSSFeed))
+	}))
+	defer server.Close()
+
+	feeds, err := newTestDiscoveryFetcher().FindFeedsAtURL(t.Context(), server.URL)
+	if err != nil {
+		t.Fatalf("FindFeedsAtURL() error = %v", err)
+	}
+
+	if len(feeds) != 1 || feeds[0].URL != server.URL {
+		t.Fatalf("FindFeedsAtURL() = %+v, want the feed itself", feeds)
+	}
+}
+
+func TestFindFeedsAtURLDiscoversLinkedFeeds(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`<html><head>
+<link rel="alternate" type="application/rss+xml" href="/posts.xml">
+<link rel="alternate" type="application/atom+xml" href="/comments.xml">
+<link rel="alternate" type="application/rss+xml" href="/missing.xml">
+</head></html>`))
+	})
+	mux.HandleFunc("/posts.xml", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(testRSSFeed))
+	})
+	mux.HandleFunc("/comments.xml", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(testRSSFeed))
+	})
+	mux.HandleFunc("/missing.xml", http.NotFound)
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	feeds, err := newTestDiscoveryFetcher().FindFeedsAtURL(t.Context(), server.URL+"/")
+	if err != nil {
+		t.Fatalf("FindFeedsAtURL() error = %v", err)
+	}
+
+	var got []string
+	for _, f := range feeds {
+		got = append(got, f.URL)
+	}
+
+	want := []string{server.URL + "/posts.xml", server.URL + "/comments.xml"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("FindFeedsAtURL() = %v, want %v", got, want)
+	}
+}
+
+func TestFindFeedsAtURLFallsBackToCommonPaths(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/about" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`<html><head><title>No feeds here</title></head></html>`))
+	})
+	mux.HandleFunc("/index.xml", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(testRSSFeed))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	feeds, err := newTestDiscoveryFetcher().FindFeedsAtURL(t.Context(), server.URL+"/about")
+	if err != nil {
+		t.Fatalf("FindFeedsAtURL() error = %v", err)
+	}
+
+	if len(feeds) != 1 || feeds[0].URL != server.URL+"/index.xml" {
+		t.Fatalf("FindFeedsAtURL() = %+v, want %s/index.xml", feeds, server.URL)
+	}
+}
+
+func TestFindFeedsAtURLFallsBackToCommonPathsWhenLinkedFeedsFail(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`<html><head>
+<link rel="alternate" type="application/rss+xml" href="/old-feed.xml">
+</head></html>`))
+	})
+	mux.HandleFunc("/feed", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(testRSSFeed))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	feeds, err := newTestDiscoveryFetcher().FindFeedsAtURL(t.Context(), server.URL+"/")
+	if err != nil {
+		t.Fatalf("FindFeedsAtURL() error = %v", err)
+	}
+
+	if len(feeds) != 1 || feeds[0].URL != server.URL+"/feed" {
+		t.Fatalf("FindFeedsAtURL() = %+v, want %s/feed", feeds, server.URL)
+	}
+}
+
+func TestFindFeedsAtURLReturnsErrorWithoutFeeds(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`<html><head><title>No feeds here</title></head></html>`))
+	}))
+	defer server.Close()
+
+	feeds, err := newTestDiscoveryFetcher().FindFeedsAtURL(t.Context(), server.URL)
+	if err == nil {
+		t.Fatalf("FindFeedsAtURL() = %+v, want error", feeds)
+	}
+}
@@ -1,6 +1,7 @@
 package feed
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -8,12 +9,15 @@ import (
 	"net/http"
 	"net/url"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
 	"telekilogram/internal/config"
 	"telekilogram/internal/domain"
+	"time"
 
 	"telekilogram/internal/database"
+	"telekilogram/internal/metrics"
 	"telekilogram/internal/summarizer"
 
 	"github.com/mmcdole/gofeed"
@@ -24,6 +28,7 @@ type Fetcher struct {
 	db             *database.Database
 	parser         *Parser
 	libParser      *gofeed.Parser
+	feedClient     *http.Client
 	telegramClient *http.Client
 	log            *slog.Logger
 }
@@ -31,17 +36,20 @@ type Fetcher struct {
 func NewFetcher(
 	db *database.Database,
 	s summarizer.Summarizer,
+	m *metrics.Metrics,
 	feedCfg config.FeedConfig,
 	telegramCfg config.TelegramConfig,
 	log *slog.Logger,
 ) *Fetcher {
 	libParser := gofeed.NewParser()
+	feedClient := &http.Client{Timeout: feedCfg.ClientTimeout}
 	telegramClient := &http.Client{Timeout: telegramCfg.ClientTimeout}
 
 	return &Fetcher{
 		db:             db,
-		parser:         NewParser(db, s, libParser, telegramClient, feedCfg, telegramCfg, log),
+		parser:         NewParser(db, s, m, libParser, feedClient, telegramClient, feedCfg, telegramCfg, log),
 		libParser:      libParser,
+		feedClient:     feedClient,
 		telegramClient: telegramClient,
 		log:            log,
 	}
@@ -50,7 +58,7 @@ func NewFetcher(
 func (f *Fetcher) FindValidFeeds(
 	ctx context.Context,
 	text string,
-) ([]domain.Feed, error) {
+) ([]domain.Feed, []domain.FeedChoice, error) {
 	text = strings.TrimSpace(text)
 
 	var slugs []string
@@ -69,35 +77,42 @@ func (f *Fetcher) FindValidFeeds(
 
 	httpsURLRe, err := xurls.StrictMatchingScheme("https://")
 	if err != nil {
-		return nil, fmt.Errorf("create regexp: %w", err)
+		return nil, nil, fmt.Errorf("create regexp: %w", err)
 	}
 
 	urls := httpsURLRe.FindAllString(text, -1)
 	urls = append(urls, findTelegramChannelURLCandidates(text)...)
 
 	feeds := make([]domain.Feed, 0, len(urls)+len(slugs))
+	var choices []domain.FeedChoice
 	seen := make(map[string]struct{}, len(urls)+len(slugs))
 	var errs []error
 
 	for _, u := range urls {
 		trimmed := strings.TrimSpace(u)
 
-		feed, validateFeedErr := f.validateFeed(ctx, trimmed)
-		if validateFeedErr != nil {
-			errs = append(errs, fmt.Errorf("validate feed: %w", validateFeedErr))
+		found, findErr := f.FindFeedsAtURL(ctx, trimmed)
+		if findErr != nil {
+			errs = append(errs, fmt.Errorf("find feeds at URL: %w", findErr))
 			continue
 		}
-		if feed == nil {
-			errs = append(errs, errors.New("validate feed"))
-			continue
+
+		var unseen []domain.Feed
+		for _, feed := range found {
+			if _, ok := seen[feed.URL]; ok {
+				continue
+			}
+
+			unseen = append(unseen, feed)
+			seen[feed.URL] = struct{}{}
 		}
 
-		if _, ok := seen[feed.URL]; ok {
+		if len(unseen) > 1 {
+			choices = append(choices, domain.FeedChoice{PageURL: trimmed, Feeds: unseen})
 			continue
 		}
 
-		feeds = append(feeds, *feed)
-		seen[feed.URL] = struct{}{}
+		feeds = append(feeds, unseen...)
 	}
 
 	for _, slug := range slugs {
@@ -121,31 +136,130 @@ func (f *Fetcher) FindValidFeeds(
 		seen[feed.URL] = struct{}{}
 	}
 
-	return feeds, errors.Join(errs...)
+	return feeds, choices, errors.Join(errs...)
 }
 
+// FetchHourFeeds returns undelivered posts of feeds with a digest slot on the
+// hourly tick at. A non-zero until ends the digest window there, so a slot
+// caught up after downtime leaves later posts for the next digest.
 func (f *Fetcher) FetchHourFeeds(
 	ctx context.Context,
-	hourUTC int64,
-) (map[int64][]domain.Post, error) {
-	feeds, err := f.db.GetHourFeeds(ctx, hourUTC)
+	at time.Time,
+	until time.Time,
+) (map[int64]domain.UserPosts, error) {
+	var errs []error
+
+	feeds, err := f.digestFeeds(ctx, at)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("get digest feeds: %w", err))
+	}
+
+	userPosts, err := f.fetchFeeds(ctx, feeds, until)
 	if err != nil {
-		return nil, fmt.Errorf("get hour feeds: %w", err)
+		errs = append(errs, err)
 	}
 
-	return f.fetchFeeds(ctx, feeds)
+	// Users without new posts are kept, so their digest slot is still recorded.
+	for _, feed := range feeds {
+		if _, ok := userPosts[feed.UserID]; !ok {
+			userPosts[feed.UserID] = domain.UserPosts{UserID: feed.UserID}
+		}
+	}
+
+	return userPosts, errors.Join(errs...)
 }
 
 func (f *Fetcher) FetchUserFeeds(
 	ctx context.Context,
 	userID int64,
-) (map[int64][]domain.Post, error) {
+) (map[int64]domain.UserPosts, error) {
 	feeds, err := f.db.GetUserFeeds(ctx, userID)
 	if err != nil {
 		return nil, fmt.Errorf("get user feeds: %w", err)
 	}
 
-	return f.fetchFeeds(ctx, feeds)
+	feeds = slices.DeleteFunc(feeds, func(feed domain.UserFeed) bool {
+		return feed.DeliveryMode == domain.DeliveryModeMuted
+	})
+
+	return f.fetchFeeds(ctx, feeds, time.Time{})
+}
+
+// FetchRealtimeFeeds returns undelivered posts of feeds in real-time mode.
+func (f *Fetcher) FetchRealtimeFeeds(ctx context.Context) (map[int64]domain.UserPosts, error) {
+	feeds, err := f.db.GetRealtimeFeeds(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get realtime feeds: %w", err)
+	}
+
+	// Real-time posts are sent one by one, so the digest cap doesn't apply.
+	for i := range feeds {
+		feeds[i].TopN = 0
+	}
+
+	return f.fetchFeeds(ctx, feeds, time.Time{})
+}
+
+func (f *Fetcher) RetryFeed(ctx context.Context, feed *domain.UserFeed) error {
+	_, err := f.parser.ParseFeed(ctx, &domain.Source{
+		ID:    feed.SourceID,
+		URL:   feed.URL,
+		Title: feed.Title,
+	}, time.Time{})
+	if err != nil {
+		return fmt.Errorf("parse feed: %w", err)
+	}
+
+	return nil
+}
+
+func (f *Fetcher) MarkPostsDelivered(
+	ctx context.Context,
+	userID int64,
+	posts []domain.Post,
+) error {
+	deliveredAt := time.Now().UTC()
+
+	var errs []error
+	for _, post := range posts {
+		key := deliveredPostKey(post)
+		if key == "" {
+			continue
+		}
+
+		if err := f.db.AddDeliveredPost(ctx, userID, post.FeedID, key, deliveredAt); err != nil {
+			errs = append(errs, fmt.Errorf("add delivered post (feedID = %d, key = %s): %w", post.FeedID, key, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (f *Fetcher) RecordDigestDelivery(ctx context.Context, userID int64, deliveredAt time.Time) error {
+	if err := f.db.UpsertDigestDelivery(ctx, userID, deliveredAt); err != nil {
+		return fmt.Errorf("upsert digest delivery: %w", err)
+	}
+
+	return nil
+}
+
+func (f *Fetcher) PruneDeliveredPosts(ctx context.Context) (int64, error) {
+	before := time.Now().UTC().Add(-f.parser.feedCfg.DeliveredPostsRetention)
+
+	removed, err := f.db.RemoveDeliveredPostsBefore(ctx, before)
+	if err != nil {
+		return 0, fmt.Errorf("remove delivered posts before: %w", err)
+	}
+
+	return removed, nil
+}
+
+func (f *Fetcher) WarmSummaryCache(ctx context.Context) (int, error) {
+	return f.parser.WarmSummaryCache(ctx)
+}
+
+func (f *Fetcher) PruneSummaryCache(ctx context.Context) (int64, error) {
+	return f.parser.PruneSummaryCache(ctx)
 }
 
 func (f *Fetcher) validateFeed(
@@ -187,7 +301,20 @@ func (f *Fetcher) validateFeed(
 		}, nil
 	}
 
-	parsed, err := f.libParser.ParseURLWithContext(feedURL, ctx)
+	resp, err := f.parser.fetchFeed(ctx, feedURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetch feed (URL = %s): %w", feedURL, err)
+	}
+
+	return f.feedFromBody(ctx, feedURL, resp.body)
+}
+
+func (f *Fetcher) feedFromBody(
+	ctx context.Context,
+	feedURL string,
+	body []byte,
+) (*domain.Feed, error) {
+	parsed, err := f.libParser.Parse(bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("parse feed (URL = %s): %w", feedURL, err)
 	}
@@ -207,51 +334,275 @@ func (f *Fetcher) validateFeed(
 func (f *Fetcher) fetchFeeds(
 	ctx context.Context,
 	feeds []domain.UserFeed,
-) (map[int64][]domain.Post, error) {
+	until time.Time,
+) (map[int64]domain.UserPosts, error) {
 	var writeWg sync.WaitGroup
 
-	concurrency := min(runtime.NumCPU()*f.parser.feedCfg.FetchFeedsMaxConcurrencyGrowthFactor, len(feeds))
-	semCh := make(chan struct{}, concurrency)
+	var errs []error
 
-	userPostCh := make(chan domain.UserPosts, concurrency)
-	errCh := make(chan error, concurrency)
+	sources, subscribers := groupFeedsBySource(feeds)
 
-	for _, feed := range feeds {
-		writeWg.Add(1)
-		semCh <- struct{}{}
+	run := &fetchRun{until: until}
 
-		go func(copiedFeed domain.UserFeed) {
-			defer writeWg.Done()
-
-			posts, err := f.parser.ParseFeed(ctx, &copiedFeed)
-			if err != nil {
-				errCh <- fmt.Errorf("parse feed: %w", err)
-			}
+	windowEnd := time.Now()
+	if !until.IsZero() {
+		windowEnd = until
+	}
 
-			if len(posts) != 0 {
-				userPostCh <- domain.UserPosts{UserID: copiedFeed.UserID, Posts: posts}
-			}
+	var err error
+	run.filterRules, err = f.userFilterRules(ctx, feeds)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("get user filter rules: %w", err))
+	}
 
-			<-semCh
-		}(feed)
+	run.since, err = f.digestSince(ctx, feeds, windowEnd)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("get digest since: %w", err))
 	}
 
+	concurrency := min(runtime.NumCPU()*f.parser.feedCfg.FetchFeedsMaxConcurrencyGrowthFactor, len(sources))
+	semCh := make(chan struct{}, concurrency)
+
+	userPostCh := make(chan domain.UserPosts, concurrency)
+	errCh := make(chan error, concurrency)
+
+	// Spawning runs alongside the readers below, as a single source can emit a
+	// result per subscriber and would otherwise block on the buffered channels.
 	go func() {
+		for _, source := range sources {
+			writeWg.Add(1)
+			semCh <- struct{}{}
+
+			go func(copiedSource domain.Source) {
+				defer writeWg.Done()
+				defer func() { <-semCh }()
+
+				f.fetchSource(ctx, &copiedSource, subscribers[copiedSource.ID], run, userPostCh, errCh)
+			}(source)
+		}
+
 		writeWg.Wait()
 		close(semCh)
 		close(userPostCh)
 		close(errCh)
 	}()
 
-	userPostsMap := make(map[int64][]domain.Post)
-	for userPosts := range userPostCh {
-		userPostsMap[userPosts.UserID] = append(userPostsMap[userPosts.UserID], userPosts.Posts...)
-	}
+	userPostsMap := make(map[int64]domain.UserPosts)
 
-	var errs []error
-	for err := range errCh {
-		errs = append(errs, err)
+	userPostRecvCh, errRecvCh := userPostCh, errCh
+	for userPostRecvCh != nil || errRecvCh != nil {
+		select {
+		case userPosts, ok := <-userPostRecvCh:
+			if !ok {
+				userPostRecvCh = nil
+				continue
+			}
+			merged := userPostsMap[userPosts.UserID]
+			merged.UserID = userPosts.UserID
+			merged.Posts = append(merged.Posts, userPosts.Posts...)
+			merged.Hidden = append(merged.Hidden, userPosts.Hidden...)
+			merged.Filtered = append(merged.Filtered, userPosts.Filtered...)
+			userPostsMap[userPosts.UserID] = merged
+		case err, ok := <-errRecvCh:
+			if !ok {
+				errRecvCh = nil
+				continue
+			}
+			errs = append(errs, err)
+		}
 	}
 
 	return userPostsMap, errors.Join(errs...)
 }
+
+func (f *Fetcher) fetchSource(
+	ctx context.Context,
+	source *domain.Source,
+	feeds []domain.UserFeed,
+	run *fetchRun,
+	userPostCh chan<- domain.UserPosts,
+	errCh chan<- error,
+) {
+	posts, err := f.parser.ParseFeed(ctx, source, run.earliestSince(feeds))
+	if err != nil {
+		errCh <- fmt.Errorf("parse feed: %w", err)
+	}
+
+	if len(posts) == 0 {
+		return
+	}
+
+	grace := f.parser.feedCfg.ParseFeedGracePeriod
+
+	for _, feed := range feeds {
+		windowPosts := postsSince(subscriberPosts(posts, feed), run.since[feed.UserID].Add(-grace))
+		windowPosts = postsUntil(windowPosts, run.until)
+
+		feedPosts, filterErr := f.undeliveredPosts(ctx, feed.UserID, feed.ID, windowPosts)
+		if filterErr != nil {
+			errCh <- fmt.Errorf("filter undelivered posts: %w", filterErr)
+		}
+
+		kept, filtered := applyFilterRules(feedPosts, run.filterRules[feed.UserID])
+		kept, hidden := topPosts(kept, feed.TopN)
+
+		if len(kept) != 0 || len(filtered) != 0 {
+			userPostCh <- domain.UserPosts{UserID: feed.UserID, Posts: kept, Hidden: hidden, Filtered: filtered}
+		}
+	}
+}
+
+// fetchRun holds per-user state loaded once for a fetch run.
+type fetchRun struct {
+	filterRules map[int64][]compiledFilterRule
+	since       map[int64]time.Time
+	until       time.Time
+}
+
+// earliestSince returns the widest digest window among the subscribers of a
+// source, as the source is parsed once for all of them.
+func (r *fetchRun) earliestSince(feeds []domain.UserFeed) time.Time {
+	var earliest time.Time
+
+	for _, feed := range feeds {
+		since, ok := r.since[feed.UserID]
+		if !ok {
+			continue
+		}
+
+		if earliest.IsZero() || since.Before(earliest) {
+			earliest = since
+		}
+	}
+
+	return earliest
+}
+
+// postsSince drops posts published before since. Posts without a date keep
+// the parse time as Published, so they always pass.
+func postsSince(posts []domain.Post, since time.Time) []domain.Post {
+	if since.IsZero() {
+		return posts
+	}
+
+	result := make([]domain.Post, 0, len(posts))
+	for _, post := range posts {
+		if !post.Published.IsZero() && post.Published.Before(since) {
+			continue
+		}
+
+		result = append(result, post)
+	}
+
+	return result
+}
+
+// postsUntil drops posts published after until. Posts without a date have the
+// parse time as Published, so they are left for a digest without until.
+func postsUntil(posts []domain.Post, until time.Time) []domain.Post {
+	if until.IsZero() {
+		return posts
+	}
+
+	return slices.DeleteFunc(slices.Clone(posts), func(post domain.Post) bool {
+		return post.Published.After(until)
+	})
+}
+
+func groupFeedsBySource(feeds []domain.UserFeed) ([]domain.Source, map[int64][]domain.UserFeed) {
+	var sources []domain.Source
+	subscribers := make(map[int64][]domain.UserFeed)
+
+	indexes := make(map[int64]int)
+
+	for _, feed := range feeds {
+		if _, ok := subscribers[feed.SourceID]; !ok {
+			indexes[feed.SourceID] = len(sources)
+			sources = append(sources, domain.Source{
+				ID:    feed.SourceID,
+				URL:   feed.URL,
+				Title: feed.Title,
+			})
+		}
+
+		// A source is summarized once for all subscribers when any of them opted in.
+		if feed.SummarizeItems {
+			sources[indexes[feed.SourceID]].SummarizeItems = true
+		}
+
+		subscribers[feed.SourceID] = append(subscribers[feed.SourceID], feed)
+	}
+
+	return sources, subscribers
+}
+
+func subscriberPosts(posts []domain.Post, feed domain.UserFeed) []domain.Post {
+	result := make([]domain.Post, len(posts))
+	for i, post := range posts {
+		post.FeedID = feed.ID
+		post.FeedPosition = feed.Position
+		if feed.CustomTitle != "" {
+			post.FeedTitle = feed.CustomTitle
+		}
+		if !feed.SummarizeItems {
+			post.Summary = ""
+		}
+		result[i] = post
+	}
+
+	return result
+}
+
+func (f *Fetcher) undeliveredPosts(
+	ctx context.Context,
+	userID int64,
+	feedID int64,
+	posts []domain.Post,
+) ([]domain.Post, error) {
+	if len(posts) == 0 {
+		return posts, nil
+	}
+
+	delivered, err := f.db.GetDeliveredPostKeys(ctx, userID, feedID)
+	if err != nil {
+		// Repeating posts is better than silently losing them.
+		return posts, fmt.Errorf("get delivered post keys: %w", err)
+	}
+
+	return filterUndeliveredPosts(posts, delivered), nil
+}
+
+func filterUndeliveredPosts(posts []domain.Post, delivered map[string]struct{}) []domain.Post {
+	result := make([]domain.Post, 0, len(posts))
+	seen := make(map[string]struct{}, len(posts))
+
+	for _, post := range posts {
+		key := deliveredPostKey(post)
+		if key != "" {
+			if _, ok := delivered[key]; ok {
+				continue
+			}
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+		}
+
+		result = append(result, post)
+	}
+
+	return result
+}
+
+func deliveredPostKey(post domain.Post) string {
+	if guid := strings.TrimSpace(post.GUID); guid != "" {
+		return guid
+	}
+
+	postURL := strings.TrimSpace(post.URL)
+	if ok, _ := isTelegramChannelURL(post.FeedURL); ok {
+		return TelegramMessageCanonicalURL(postURL)
+	}
+
+	return postURL
+}
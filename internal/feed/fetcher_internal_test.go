@@ -0,0 +1,141 @@
+package feed
+
+import (
+	"telekilogram/internal/domain"
+	"testing"
+)
+
+func TestDeliveredPostKeyPrefersGUID(t *testing.T) {
+	post := domain.Post{
+		URL:     "https://example.com/posts/1?utm_source=rss",
+		GUID:    " urn:example:1 ",
+		FeedURL: "https://example.com/feed.xml",
+	}
+
+	if got := deliveredPostKey(post); got != "urn:example:1" {
+		t.Fatalf("expected GUID key, got %q", got)
+	}
+}
+
+func TestDeliveredPostKeyKeepsRSSQuery(t *testing.T) {
+	post := domain.Post{
+		URL:     "https://example.com/?p=123",
+		FeedURL: "https://example.com/feed.xml",
+	}
+
+	if got := deliveredPostKey(post); got != "https://example.com/?p=123" {
+		t.Fatalf("expected RSS post URL to be kept verbatim, got %q", got)
+	}
+}
+
+func TestDeliveredPostKeyCanonicalizesTelegramURL(t *testing.T) {
+	post := domain.Post{
+		URL:     "https://t.me/example_channel/123?single=1",
+		FeedURL: "https://t.me/s/example_channel",
+	}
+
+	if got := deliveredPostKey(post); got != "https://t.me/example_channel/123" {
+		t.Fatalf("expected canonical Telegram post URL, got %q", got)
+	}
+}
+
+func TestFilterUndeliveredPostsSkipsDeliveredAndDuplicates(t *testing.T) {
+	posts := []domain.Post{
+		{URL: "https://example.com/1", FeedURL: "https://example.com/feed.xml"},
+		{URL: "https://example.com/2", FeedURL: "https://example.com/feed.xml"},
+		{URL: "https://example.com/2", FeedURL: "https://example.com/feed.xml"},
+		{URL: "https://example.com/3", FeedURL: "https://example.com/feed.xml"},
+	}
+	delivered := map[string]struct{}{"https://example.com/1": {}}
+
+	got := filterUndeliveredPosts(posts, delivered)
+
+	if len(got) != 2 {
+		t.Fatalf("expected 2 undelivered posts, got %d", len(got))
+	}
+	if got[0].URL != "https://example.com/2" || got[1].URL != "https://example.com/3" {
+		t.Fatalf("unexpected undelivered posts: %+v", got)
+	}
+}
+
+func TestGroupFeedsBySourceFetchesSharedSourceOnce(t *testing.T) {
+	feeds := []domain.UserFeed{
+		{ID: 1, UserID: 10, SourceID: 100, URL: "https://t.me/s/example_channel", Title: "Example"},
+		{ID: 2, UserID: 20, SourceID: 100, URL: "https://t.me/s/example_channel", Title: "Example"},
+		{ID: 3, UserID: 20, SourceID: 200, URL: "https://example.com/feed.xml", Title: "Blog"},
+	}
+
+	sources, subscribers := groupFeedsBySource(feeds)
+
+	if len(sources) != 2 {
+		t.Fatalf("expected 2 unique sources, got %d", len(sources))
+	}
+	if sources[0].ID != 100 || sources[1].ID != 200 {
+		t.Fatalf("expected sources in first-seen order, got %+v", sources)
+	}
+	if len(subscribers[100]) != 2 || len(subscribers[200]) != 1 {
+		t.Fatalf("unexpected subscribers: %+v", subscribers)
+	}
+}
+
+func TestSubscriberPostsUsesSubscriptionID(t *testing.T) {
+	posts := []domain.Post{
+		{URL: "https://example.com/1"},
+		{URL: "https://example.com/2"},
+	}
+
+	got := subscriberPosts(posts, domain.UserFeed{ID: 42, UserID: 7})
+
+	for i, post := range got {
+		if post.FeedID != 42 {
+			t.Fatalf("post %d: expected feed ID 42, got %d", i, post.FeedID)
+		}
+	}
+	if posts[0].FeedID != 0 {
+		t.Fatal("source posts should not be mutated")
+	}
+}
+
+func TestSubscriberPostsUsesCustomTitleAndPosition(t *testing.T) {
+	posts := []domain.Post{{URL: "https://example.com/1", FeedTitle: "Remote title"}}
+
+	got := subscriberPosts(posts, domain.UserFeed{ID: 42, CustomTitle: "Short", Position: 3})
+	if got[0].FeedTitle != "Short" || got[0].FeedPosition != 3 {
+		t.Fatalf("expected custom title and position, got %+v", got[0])
+	}
+
+	got = subscriberPosts(posts, domain.UserFeed{ID: 42, Title: "Stored title"})
+	if got[0].FeedTitle != "Remote title" {
+		t.Fatalf("feed without custom title should keep the parsed title, got %q", got[0].FeedTitle)
+	}
+
+	sources, _ := groupFeedsBySource([]domain.UserFeed{
+		{ID: 1, SourceID: 100, URL: "https://example.com/feed.xml", Title: "Stored title", CustomTitle: "Short"},
+	})
+	if sources[0].Title != "Stored title" {
+		t.Fatalf("custom title shouldn't reach the shared source, got %q", sources[0].Title)
+	}
+}
+
+func TestGroupFeedsBySourceSummarizesWhenAnySubscriberOptsIn(t *testing.T) {
+	feeds := []domain.UserFeed{
+		{ID: 1, UserID: 10, SourceID: 100, URL: "https://example.com/feed.xml"},
+		{ID: 2, UserID: 20, SourceID: 100, URL: "https://example.com/feed.xml", SummarizeItems: true},
+		{ID: 3, UserID: 20, SourceID: 200, URL: "https://example.org/feed.xml"},
+	}
+
+	sources, _ := groupFeedsBySource(feeds)
+
+	if !sources[0].SummarizeItems || sources[1].SummarizeItems {
+		t.Fatalf("unexpected summarize flags: %+v", sources)
+	}
+
+	posts := []domain.Post{{URL: "https://example.com/1", Summary: "Summary"}}
+
+	if got := subscriberPosts(posts, feeds[0]); got[0].Summary != "" {
+		t.Fatalf("subscriber without opt-in got summary %q", got[0].Summary)
+	}
+	if got := subscriberPosts(posts, feeds[1]); got[0].Summary != "Summary" {
+		t.Fatalf("subscriber with opt-in got summary %q", got[0].Summary)
+	}
+}
@@ -0,0 +1,139 @@
+package feed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"telekilogram/internal/domain"
+)
+
+type compiledFilterRule struct {
+	feedID  int64
+	exclude bool
+	re      *regexp.Regexp
+}
+
+// CompileFilterPattern turns a filter pattern into a regexp. Keywords match as
+// literal substrings; both kinds ignore case unless caseSensitive is set.
+func CompileFilterPattern(pattern string, regex bool, caseSensitive bool) (*regexp.Regexp, error) {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return nil, errors.New("pattern is empty")
+	}
+
+	if !regex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if !caseSensitive {
+		pattern = "(?i)" + pattern
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("compile regexp: %w", err)
+	}
+
+	return re, nil
+}
+
+func compileFilterRules(rules []domain.FilterRule) ([]compiledFilterRule, error) {
+	compiled := make([]compiledFilterRule, 0, len(rules))
+	var errs []error
+
+	for _, rule := range rules {
+		re, err := CompileFilterPattern(rule.Pattern, rule.Regex, rule.CaseSensitive)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("compile filter pattern (ruleID = %d): %w", rule.ID, err))
+			continue
+		}
+
+		compiled = append(compiled, compiledFilterRule{
+			feedID:  rule.FeedID,
+			exclude: rule.Exclude,
+			re:      re,
+		})
+	}
+
+	return compiled, errors.Join(errs...)
+}
+
+func (f *Fetcher) userFilterRules(
+	ctx context.Context,
+	feeds []domain.UserFeed,
+) (map[int64][]compiledFilterRule, error) {
+	rulesByUser := make(map[int64][]compiledFilterRule)
+	var errs []error
+
+	for _, feed := range feeds {
+		if _, ok := rulesByUser[feed.UserID]; ok {
+			continue
+		}
+
+		rules, err := f.db.GetUserFilterRules(ctx, feed.UserID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("get user filter rules (userID = %d): %w", feed.UserID, err))
+		}
+
+		compiled, err := compileFilterRules(rules)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("compile filter rules (userID = %d): %w", feed.UserID, err))
+		}
+
+		rulesByUser[feed.UserID] = compiled
+	}
+
+	return rulesByUser, errors.Join(errs...)
+}
+
+// applyFilterRules splits posts into kept and filtered ones. A post is dropped
+// when any exclude rule matches it, or when include rules apply to its feed and
+// none of them matches.
+func applyFilterRules(posts []domain.Post, rules []compiledFilterRule) ([]domain.Post, []domain.Post) {
+	if len(rules) == 0 {
+		return posts, nil
+	}
+
+	kept := make([]domain.Post, 0, len(posts))
+	var filtered []domain.Post
+
+	for _, post := range posts {
+		if postPassesFilterRules(post, rules) {
+			kept = append(kept, post)
+		} else {
+			filtered = append(filtered, post)
+		}
+	}
+
+	return kept, filtered
+}
+
+func postPassesFilterRules(post domain.Post, rules []compiledFilterRule) bool {
+	text := post.Title
+	if strings.TrimSpace(post.Text) != "" {
+		text = post.Text
+	}
+
+	hasInclude := false
+	included := false
+
+	for _, rule := range rules {
+		if rule.feedID != 0 && rule.feedID != post.FeedID {
+			continue
+		}
+
+		matched := rule.re.MatchString(text)
+		if rule.exclude {
+			if matched {
+				return false
+			}
+			continue
+		}
+
+		hasInclude = true
+		included = included || matched
+	}
+
+	return !hasInclude || included
+}
@@ -0,0 +1,114 @@
+package feed
+
+import (
+	"telekilogram/internal/domain"
+	"testing"
+)
+
+func mustCompileFilterRules(t *testing.T, rules []domain.FilterRule) []compiledFilterRule {
+	t.Helper()
+
+	compiled, err := compileFilterRules(rules)
+	if err != nil {
+		t.Fatalf("compileFilterRules() error = %v", err)
+	}
+
+	return compiled
+}
+
+func TestApplyFilterRules(t *testing.T) {
+	allURLs := []string{
+		"https://example.com/go",
+		"https://example.com/ad",
+		"https://example.com/rust",
+		"https://t.me/s/channel/1",
+	}
+	posts := []domain.Post{
+		{FeedID: 1, Title: "Go 1.25 is released", URL: "https://example.com/go"},
+		{FeedID: 1, Title: "Sponsored: buy now", URL: "https://example.com/ad"},
+		{FeedID: 2, Title: "Rust news", URL: "https://example.com/rust"},
+		{FeedID: 3, Text: "GOLANG meetup tonight", URL: "https://t.me/s/channel/1"},
+	}
+
+	tests := []struct {
+		name         string
+		rules        []domain.FilterRule
+		wantKept     []string
+		wantFiltered []string
+	}{
+		{
+			name:     "no rules",
+			wantKept: allURLs,
+		},
+		{
+			name:         "exclude keyword ignores case",
+			rules:        []domain.FilterRule{{Exclude: true, Pattern: "sponsored"}},
+			wantKept:     []string{"https://example.com/go", "https://example.com/rust", "https://t.me/s/channel/1"},
+			wantFiltered: []string{"https://example.com/ad"},
+		},
+		{
+			name:         "case-sensitive keyword",
+			rules:        []domain.FilterRule{{Exclude: true, Pattern: "golang", CaseSensitive: true}},
+			wantKept:     allURLs,
+			wantFiltered: nil,
+		},
+		{
+			name:         "include regex matches Telegram text",
+			rules:        []domain.FilterRule{{Regex: true, Pattern: `\bgo(lang)?\b`}},
+			wantKept:     []string{"https://example.com/go", "https://t.me/s/channel/1"},
+			wantFiltered: []string{"https://example.com/ad", "https://example.com/rust"},
+		},
+		{
+			name:         "feed-scoped include leaves other feeds alone",
+			rules:        []domain.FilterRule{{FeedID: 1, Pattern: "go"}},
+			wantKept:     []string{"https://example.com/go", "https://example.com/rust", "https://t.me/s/channel/1"},
+			wantFiltered: []string{"https://example.com/ad"},
+		},
+		{
+			name: "exclude wins over include",
+			rules: []domain.FilterRule{
+				{Pattern: "go"},
+				{Exclude: true, Pattern: "meetup"},
+			},
+			wantKept:     []string{"https://example.com/go"},
+			wantFiltered: []string{"https://example.com/ad", "https://example.com/rust", "https://t.me/s/channel/1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, filtered := applyFilterRules(posts, mustCompileFilterRules(t, tt.rules))
+
+			assertPostURLs(t, "kept", kept, tt.wantKept)
+			assertPostURLs(t, "filtered", filtered, tt.wantFiltered)
+		})
+	}
+}
+
+func TestCompileFilterRulesSkipsInvalidRegex(t *testing.T) {
+	compiled, err := compileFilterRules([]domain.FilterRule{
+		{ID: 1, Regex: true, Pattern: "("},
+		{ID: 2, Pattern: "("},
+	})
+	if err == nil {
+		t.Fatalf("compileFilterRules() should report invalid regex")
+	}
+
+	if len(compiled) != 1 || !compiled[0].re.MatchString("a ( b") {
+		t.Fatalf("compileFilterRules() should keep the literal keyword rule, got %+v", compiled)
+	}
+}
+
+func assertPostURLs(t *testing.T, name string, posts []domain.Post, want []string) {
+	t.Helper()
+
+	if len(posts) != len(want) {
+		t.Fatalf("%s posts = %+v, want URLs %v", name, posts, want)
+	}
+
+	for i, post := range posts {
+		if post.URL != want[i] {
+			t.Fatalf("%s[%d].URL = %q, want %q", name, i, post.URL, want[i])
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package feed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const maxSourceErrorLength = 500
+
+type statusError struct {
+	statusCode int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status: %d", e.statusCode)
+}
+
+func statusCodeFromError(err error) int {
+	var statusErr *statusError
+	if errors.As(err, &statusErr) {
+		return statusErr.statusCode
+	}
+
+	return 0
+}
+
+func (p *Parser) recordSourceHealth(
+	ctx context.Context,
+	sourceID int64,
+	statusCode int,
+	fetchErr error,
+) {
+	if p.db == nil {
+		return
+	}
+
+	now := time.Now().UTC()
+
+	var err error
+	if fetchErr == nil {
+		err = p.db.RecordSourceSuccess(ctx, sourceID, int64(statusCode), now)
+	} else {
+		lastError := []rune(fetchErr.Error())
+		if len(lastError) > maxSourceErrorLength {
+			lastError = append(lastError[:maxSourceErrorLength-3], []rune("...")...)
+		}
+
+		err = p.db.RecordSourceFailure(ctx, sourceID, int64(statusCode), string(lastError), now)
+	}
+
+	if err != nil {
+		p.log.WarnContext(ctx, "Failed to record source health",
+			"error", err,
+			"sourceID", sourceID,
+			"statusCode", statusCode,
+			"fetchError", fetchErr)
+	}
+}
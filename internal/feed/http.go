@@ -0,0 +1,286 @@
+package feed
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"telekilogram/internal/domain"
+
+	"github.com/mmcdole/gofeed"
+)
+
+type feedResponse struct {
+	body        []byte
+	notModified bool
+	statusCode  int
+	httpCache   domain.SourceHTTPCache
+}
+
+type parsedFeedCacheEntry struct {
+	sourceID  int64
+	httpCache domain.SourceHTTPCache
+	feed      *gofeed.Feed
+}
+
+// parsedFeedCache keeps the last parsed feed per source, so a 304 response or an
+// unchanged body can reuse it for subscribers whose digest runs at another hour.
+// The least recently used feeds are evicted beyond maxEntries, so unfollowed
+// sources don't pile up; a 304 response for an evicted feed fetches it again.
+type parsedFeedCache struct {
+	mu         sync.Mutex
+	entries    map[int64]*list.Element
+	order      *list.List
+	maxEntries int
+}
+
+func newParsedFeedCache(maxEntries int) *parsedFeedCache {
+	if maxEntries <= 0 {
+		return nil
+	}
+
+	return &parsedFeedCache{
+		entries:    make(map[int64]*list.Element, maxEntries),
+		order:      list.New(),
+		maxEntries: maxEntries,
+	}
+}
+
+func (c *parsedFeedCache) get(sourceID int64) (parsedFeedCacheEntry, bool) {
+	if c == nil {
+		return parsedFeedCacheEntry{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[sourceID]
+	if !ok {
+		return parsedFeedCacheEntry{}, false
+	}
+
+	entry, ok := elem.Value.(*parsedFeedCacheEntry)
+	if !ok {
+		return parsedFeedCacheEntry{}, false
+	}
+
+	c.order.MoveToFront(elem)
+	return *entry, true
+}
+
+func (c *parsedFeedCache) set(sourceID int64, httpCache domain.SourceHTTPCache, feed *gofeed.Feed) {
+	if c == nil || feed == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &parsedFeedCacheEntry{sourceID: sourceID, httpCache: httpCache, feed: feed}
+
+	if elem, ok := c.entries[sourceID]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[sourceID] = c.order.PushFront(entry)
+
+	for len(c.entries) > c.maxEntries {
+		elem := c.order.Back()
+		if elem == nil {
+			return
+		}
+
+		c.order.Remove(elem)
+		if evicted, ok := elem.Value.(*parsedFeedCacheEntry); ok {
+			delete(c.entries, evicted.sourceID)
+		}
+	}
+}
+
+func (p *Parser) fetchFeed(
+	ctx context.Context,
+	feedURL string,
+	httpCache *domain.SourceHTTPCache,
+) (*feedResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", p.feedCfg.UserAgent)
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/feed+json, "+
+		"application/json, application/xml, text/xml;q=0.9, */*;q=0.8")
+
+	if httpCache != nil {
+		if etag := strings.TrimSpace(httpCache.ETag); etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		if lastModified := strings.TrimSpace(httpCache.LastModified); lastModified != "" {
+			req.Header.Set("If-Modified-Since", lastModified)
+		}
+	}
+
+	resp, err := p.feedClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	defer func() {
+		if err = resp.Body.Close(); err != nil {
+			p.log.ErrorContext(ctx, "Failed to close response body",
+				"error", err,
+				"feedURL", feedURL,
+				"operation", "fetchFeed")
+		}
+	}()
+
+	result := &feedResponse{statusCode: resp.StatusCode}
+
+	if resp.StatusCode == http.StatusNotModified {
+		result.notModified = true
+		if httpCache != nil {
+			result.httpCache = *httpCache
+		}
+
+		return result, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return result, fmt.Errorf("do request: %w", &statusError{statusCode: resp.StatusCode})
+	}
+
+	body, err := readLimitedBody(resp.Body, p.feedCfg.MaxBodySize)
+	if err != nil {
+		return result, fmt.Errorf("read body: %w", err)
+	}
+
+	hash := sha256.Sum256(body)
+
+	result.body = body
+	result.httpCache = domain.SourceHTTPCache{
+		ETag:         strings.TrimSpace(resp.Header.Get("ETag")),
+		LastModified: strings.TrimSpace(resp.Header.Get("Last-Modified")),
+		BodyHash:     hex.EncodeToString(hash[:]),
+	}
+
+	return result, nil
+}
+
+// fetchAndParseFeed downloads a feed with conditional request headers and only
+// parses the body when it changed since the last parse kept in memory.
+// Validators and the body hash are stored with the source, so they survive
+// restarts.
+func (p *Parser) fetchAndParseFeed(
+	ctx context.Context,
+	source *domain.Source,
+	feedURL string,
+) (*gofeed.Feed, error) {
+	cached, hasCached := p.parsedFeeds.get(source.ID)
+
+	httpCache := p.storedHTTPCache(ctx, source.ID, feedURL)
+	if httpCache == nil && hasCached {
+		httpCache = &cached.httpCache
+	}
+
+	resp, err := p.fetchFeed(ctx, feedURL, httpCache)
+	if err == nil && resp.notModified && !hasCached {
+		// There's no parsed feed to reuse after a restart or an eviction, so
+		// the body is fetched again.
+		p.log.DebugContext(ctx, "Feed is not modified, but isn't parsed yet",
+			"sourceID", source.ID,
+			"feedURL", feedURL)
+
+		resp, err = p.fetchFeed(ctx, feedURL, nil)
+	}
+	if err != nil {
+		statusCode := statusCodeFromError(err)
+		if resp != nil {
+			statusCode = resp.statusCode
+		}
+
+		p.recordSourceHealth(ctx, source.ID, statusCode, err)
+
+		return nil, fmt.Errorf("fetch feed: %w", err)
+	}
+
+	if resp.notModified {
+		p.log.DebugContext(ctx, "Feed is not modified",
+			"sourceID", source.ID,
+			"feedURL", feedURL)
+
+		p.recordSourceHealth(ctx, source.ID, resp.statusCode, nil)
+
+		return cached.feed, nil
+	}
+
+	parsed := cached.feed
+	if !hasCached || cached.httpCache.BodyHash != resp.httpCache.BodyHash {
+		parsed, err = p.libParser.Parse(bytes.NewReader(resp.body))
+		if err != nil {
+			p.recordSourceHealth(ctx, source.ID, resp.statusCode, err)
+
+			return nil, fmt.Errorf("parse feed: %w", err)
+		}
+	}
+
+	// Validators are refreshed even for an unchanged body, as servers may
+	// rotate them.
+	p.parsedFeeds.set(source.ID, resp.httpCache, parsed)
+
+	if p.db != nil {
+		if err = p.db.UpdateSourceHTTPCache(ctx, source.ID, &resp.httpCache); err != nil {
+			p.log.WarnContext(ctx, "Failed to update source HTTP cache",
+				"error", err,
+				"sourceID", source.ID,
+				"feedURL", feedURL)
+		}
+	}
+
+	p.recordSourceHealth(ctx, source.ID, resp.statusCode, nil)
+
+	return parsed, nil
+}
+
+// storedHTTPCache returns the validators stored with the source, or nil when
+// there's no database or the read fails.
+func (p *Parser) storedHTTPCache(ctx context.Context, sourceID int64, feedURL string) *domain.SourceHTTPCache {
+	if p.db == nil {
+		return nil
+	}
+
+	stored, err := p.db.GetSourceHTTPCache(ctx, sourceID)
+	if err != nil {
+		p.log.WarnContext(ctx, "Failed to get source HTTP cache",
+			"error", err,
+			"sourceID", sourceID,
+			"feedURL", feedURL)
+
+		return nil
+	}
+
+	return stored
+}
+
+func readLimitedBody(body io.Reader, maxBodySize int64) ([]byte, error) {
+	if maxBodySize <= 0 {
+		return io.ReadAll(body)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, maxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > maxBodySize {
+		return nil, fmt.Errorf("body exceeds %d bytes", maxBodySize)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,267 @@
+package feed
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"telekilogram/internal/config"
+	"telekilogram/internal/domain"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+const testRSSFeed = `<?xml version="1.0"?>
+<rss version="2.0"><channel><title>Example</title>
+<item><title>First</title><link>https://example.com/1</link></item>
+</channel></rss>`
+
+func newTestHTTPParser(maxBodySize int64) *Parser {
+	return NewParser(nil, nil, nil, gofeed.NewParser(), &http.Client{Timeout: 5 * time.Second}, nil, config.FeedConfig{
+		UserAgent:                 "test-agent",
+		MaxBodySize:               maxBodySize,
+		ParsedFeedCacheMaxEntries: 16,
+	}, config.TelegramConfig{}, slog.Default())
+}
+
+func TestFetchFeedSendsConditionalHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != "test-agent" {
+			t.Errorf("unexpected user agent: %q", r.Header.Get("User-Agent"))
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` &&
+			r.Header.Get("If-Modified-Since") == "Mon, 01 Jan 2025 00:00:00 GMT" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(testRSSFeed))
+	}))
+	defer server.Close()
+
+	parser := newTestHTTPParser(0)
+
+	resp, err := parser.fetchFeed(t.Context(), server.URL, &domain.SourceHTTPCache{
+		ETag:         `"v1"`,
+		LastModified: "Mon, 01 Jan 2025 00:00:00 GMT",
+	})
+	if err != nil {
+		t.Fatalf("fetchFeed() error = %v", err)
+	}
+	if !resp.notModified {
+		t.Fatalf("expected not modified response, got status %d", resp.statusCode)
+	}
+	if resp.httpCache.ETag != `"v1"` {
+		t.Fatalf("expected previous ETag to be kept, got %q", resp.httpCache.ETag)
+	}
+}
+
+func TestFetchFeedStoresValidatorsAndBodyHash(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("ETag", `"v2"`)
+		w.Header().Set("Last-Modified", "Tue, 02 Jan 2025 00:00:00 GMT")
+		_, _ = w.Write([]byte(testRSSFeed))
+	}))
+	defer server.Close()
+
+	parser := newTestHTTPParser(0)
+
+	resp, err := parser.fetchFeed(t.Context(), server.URL, nil)
+	if err != nil {
+		t.Fatalf("fetchFeed() error = %v", err)
+	}
+	if resp.notModified {
+		t.Fatal("expected full response")
+	}
+	if resp.httpCache.ETag != `"v2"` || resp.httpCache.LastModified != "Tue, 02 Jan 2025 00:00:00 GMT" {
+		t.Fatalf("unexpected validators: %+v", resp.httpCache)
+	}
+	if len(resp.httpCache.BodyHash) != 64 {
+		t.Fatalf("expected sha256 hex body hash, got %q", resp.httpCache.BodyHash)
+	}
+}
+
+func TestFetchAndParseFeedReusesParsedFeedForUnchangedBody(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write([]byte(testRSSFeed))
+	}))
+	defer server.Close()
+
+	parser := newTestHTTPParser(0)
+	source := &domain.Source{ID: 1, URL: server.URL}
+
+	first, err := parser.fetchAndParseFeed(t.Context(), source, server.URL)
+	if err != nil {
+		t.Fatalf("fetchAndParseFeed() error = %v", err)
+	}
+
+	second, err := parser.fetchAndParseFeed(t.Context(), source, server.URL)
+	if err != nil {
+		t.Fatalf("fetchAndParseFeed() error = %v", err)
+	}
+
+	if first != second {
+		t.Fatal("expected unchanged body to reuse the parsed feed")
+	}
+	if got := requests.Load(); got != 2 {
+		t.Fatalf("expected 2 requests, got %d", got)
+	}
+}
+
+func TestFetchAndParseFeedSendsValidatorsOfParsedFeed(t *testing.T) {
+	var conditional atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			conditional.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(testRSSFeed))
+	}))
+	defer server.Close()
+
+	parser := newTestHTTPParser(0)
+	source := &domain.Source{ID: 1, URL: server.URL}
+
+	first, err := parser.fetchAndParseFeed(t.Context(), source, server.URL)
+	if err != nil {
+		t.Fatalf("fetchAndParseFeed() error = %v", err)
+	}
+	if conditional.Load() != 0 {
+		t.Fatal("expected the first fetch to be unconditional")
+	}
+
+	second, err := parser.fetchAndParseFeed(t.Context(), source, server.URL)
+	if err != nil {
+		t.Fatalf("fetchAndParseFeed() error = %v", err)
+	}
+	if conditional.Load() != 1 || first != second {
+		t.Fatalf("expected a 304 response to reuse the parsed feed, conditional requests = %d", conditional.Load())
+	}
+}
+
+func TestFetchAndParseFeedRefetchesNotModifiedFeedAfterRestart(t *testing.T) {
+	var requests, conditional atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			conditional.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write([]byte(testRSSFeed))
+	}))
+	defer server.Close()
+
+	ctx := t.Context()
+	db := newSummaryCacheTestDB(t)
+
+	if err := db.AddFeed(ctx, 1, server.URL, "Example"); err != nil {
+		t.Fatalf("add feed: %v", err)
+	}
+	feeds, err := db.GetUserFeeds(ctx, 1)
+	if err != nil || len(feeds) != 1 {
+		t.Fatalf("get user feeds: %v, %+v", err, feeds)
+	}
+	source := &domain.Source{ID: feeds[0].SourceID, URL: server.URL}
+
+	newParser := func() *Parser {
+		return NewParser(db, nil, nil, gofeed.NewParser(), server.Client(), nil, config.FeedConfig{
+			ParsedFeedCacheMaxEntries: 16,
+		}, config.TelegramConfig{}, slog.Default())
+	}
+
+	if _, err = newParser().fetchAndParseFeed(ctx, source, server.URL); err != nil {
+		t.Fatalf("fetchAndParseFeed() error = %v", err)
+	}
+
+	stored, err := db.GetSourceHTTPCache(ctx, source.ID)
+	if err != nil || stored.ETag != `"v1"` || stored.BodyHash == "" {
+		t.Fatalf("expected validators to be stored, got %+v, %v", stored, err)
+	}
+
+	// A new parser has no parsed feed, so the 304 response is followed by an
+	// unconditional request.
+	parsed, err := newParser().fetchAndParseFeed(ctx, source, server.URL)
+	if err != nil {
+		t.Fatalf("fetchAndParseFeed() after restart error = %v", err)
+	}
+	if parsed == nil || parsed.Title != "Example" {
+		t.Fatalf("expected the refetched feed, got %+v", parsed)
+	}
+	if requests.Load() != 3 || conditional.Load() != 1 {
+		t.Fatalf("expected 3 requests with 1 conditional, got %d and %d", requests.Load(), conditional.Load())
+	}
+}
+
+func TestParsedFeedCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newParsedFeedCache(2)
+
+	cache.set(1, domain.SourceHTTPCache{ETag: `"1"`}, &gofeed.Feed{Title: "One"})
+	cache.set(2, domain.SourceHTTPCache{ETag: `"2"`}, &gofeed.Feed{Title: "Two"})
+
+	if _, ok := cache.get(1); !ok {
+		t.Fatal("expected source 1 to be cached")
+	}
+
+	cache.set(3, domain.SourceHTTPCache{ETag: `"3"`}, &gofeed.Feed{Title: "Three"})
+
+	if _, ok := cache.get(2); ok {
+		t.Fatal("expected the least recently used source to be evicted")
+	}
+	for _, sourceID := range []int64{1, 3} {
+		if _, ok := cache.get(sourceID); !ok {
+			t.Fatalf("expected source %d to be cached", sourceID)
+		}
+	}
+}
+
+func TestFetchFeedRejectsOversizedBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("a", 100)))
+	}))
+	defer server.Close()
+
+	parser := newTestHTTPParser(10)
+
+	if _, err := parser.fetchFeed(t.Context(), server.URL, nil); err == nil {
+		t.Fatal("expected oversized body to be rejected")
+	}
+}
+
+func TestFetchFeedRejectsUnexpectedStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	parser := newTestHTTPParser(0)
+
+	resp, err := parser.fetchFeed(t.Context(), server.URL, nil)
+	if err == nil {
+		t.Fatal("expected error for unexpected status")
+	}
+	if resp == nil || resp.statusCode != http.StatusNotFound {
+		t.Fatalf("expected status code to be reported, got %+v", resp)
+	}
+}
+
+func TestStatusCodeFromError(t *testing.T) {
+	err := fmt.Errorf("fetch feed: %w", fmt.Errorf("do request: %w", &statusError{statusCode: http.StatusGone}))
+
+	if got := statusCodeFromError(err); got != http.StatusGone {
+		t.Fatalf("expected status %d, got %d", http.StatusGone, got)
+	}
+	if got := statusCodeFromError(errors.New("dial tcp: timeout")); got != 0 {
+		t.Fatalf("expected no status for transport errors, got %d", got)
+	}
+}
@@ -0,0 +1,140 @@
+package feed
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"telekilogram/internal/domain"
+	"time"
+)
+
+const opmlVersion = "2.0"
+
+type opmlDocument struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    opmlHead `xml:"head"`
+	Body    opmlBody `xml:"body"`
+}
+
+type opmlHead struct {
+	Title       string `xml:"title,omitempty"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+type opmlBody struct {
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+type opmlOutline struct {
+	Text     string        `xml:"text,attr"`
+	Title    string        `xml:"title,attr,omitempty"`
+	Type     string        `xml:"type,attr,omitempty"`
+	XMLURL   string        `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string        `xml:"htmlUrl,attr,omitempty"`
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+// EncodeOPML renders feeds as an OPML 2.0 subscription list under their display
+// titles, so renamed feeds keep their names on import. Telegram channels
+// are exported by their canonical https://t.me/s/<slug> URL, which FindFeedsAtURL
+// accepts back on import.
+func EncodeOPML(title string, feeds []domain.UserFeed, created time.Time) ([]byte, error) {
+	doc := opmlDocument{
+		Version: opmlVersion,
+		Head: opmlHead{
+			Title:       title,
+			DateCreated: created.UTC().Format(time.RFC1123Z),
+		},
+	}
+
+	for _, f := range feeds {
+		feedURL := strings.TrimSpace(f.URL)
+		if feedURL == "" {
+			continue
+		}
+
+		if ok, slug := isTelegramChannelURL(feedURL); ok {
+			feedURL = TelegramChannelCanonicalURL(slug)
+		}
+
+		feedTitle := strings.TrimSpace(f.DisplayTitle())
+		if feedTitle == "" {
+			feedTitle = feedURL
+		}
+
+		doc.Body.Outlines = append(doc.Body.Outlines, opmlOutline{
+			Text:   feedTitle,
+			Title:  feedTitle,
+			Type:   "rss",
+			XMLURL: feedURL,
+		})
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+
+	encoder := xml.NewEncoder(&buf)
+	encoder.Indent("", "  ")
+
+	if err := encoder.Encode(doc); err != nil {
+		return nil, fmt.Errorf("encode OPML: %w", err)
+	}
+
+	buf.WriteByte('\n')
+
+	return buf.Bytes(), nil
+}
+
+// ParseOPML returns the feeds listed in an OPML document, flattening nested
+// outlines and skipping outlines without an xmlUrl.
+func ParseOPML(r io.Reader) ([]domain.Feed, error) {
+	var doc opmlDocument
+
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		if strings.EqualFold(charset, "utf-8") || strings.EqualFold(charset, "us-ascii") {
+			return input, nil
+		}
+
+		return nil, fmt.Errorf("unsupported charset: %s", charset)
+	}
+
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode OPML: %w", err)
+	}
+
+	var feeds []domain.Feed
+	seen := make(map[string]struct{})
+
+	var walk func(outlines []opmlOutline)
+	walk = func(outlines []opmlOutline) {
+		for _, o := range outlines {
+			feedURL := strings.TrimSpace(o.XMLURL)
+			if _, ok := seen[feedURL]; feedURL == "" || ok {
+				walk(o.Outlines)
+				continue
+			}
+
+			title := strings.TrimSpace(o.Title)
+			if title == "" {
+				title = strings.TrimSpace(o.Text)
+			}
+
+			seen[feedURL] = struct{}{}
+			feeds = append(feeds, domain.Feed{URL: feedURL, Title: title})
+
+			walk(o.Outlines)
+		}
+	}
+	walk(doc.Body.Outlines)
+
+	if len(feeds) == 0 {
+		return nil, errors.New("OPML has no feed outlines")
+	}
+
+	return feeds, nil
+}
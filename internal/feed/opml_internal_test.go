@@ -0,0 +1,100 @@
+package feed
+
+import (
+	"bytes"
+	"strings"
+	"telekilogram/internal/domain"
+	"testing"
+	"time"
+)
+
+func TestEncodeOPMLRoundTrip(t *testing.T) {
+	feeds := []domain.UserFeed{
+		{ID: 1, URL: "https://example.com/rss.xml", Title: "Example & Co"},
+		{ID: 2, URL: "https://t.me/s/durov", Title: "Durov's Channel"},
+		{ID: 3, URL: "https://example.com/untitled.xml"},
+		{ID: 4, URL: "https://example.com/renamed.xml", Title: "Original", CustomTitle: "Renamed"},
+	}
+
+	data, err := EncodeOPML("Subscriptions", feeds, time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("EncodeOPML() error = %v", err)
+	}
+
+	for _, want := range []string{
+		`<opml version="2.0">`,
+		`<title>Subscriptions</title>`,
+		`xmlUrl="https://t.me/s/durov"`,
+		`text="Example &amp; Co"`,
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Fatalf("EncodeOPML() should contain %q, got:\n%s", want, data)
+		}
+	}
+
+	got, err := ParseOPML(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ParseOPML() error = %v", err)
+	}
+
+	want := []domain.Feed{
+		{URL: "https://example.com/rss.xml", Title: "Example & Co"},
+		{URL: "https://t.me/s/durov", Title: "Durov's Channel"},
+		{URL: "https://example.com/untitled.xml", Title: "https://example.com/untitled.xml"},
+		{URL: "https://example.com/renamed.xml", Title: "Renamed"},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("ParseOPML() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("ParseOPML()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseOPMLFlattensNestedOutlines(t *testing.T) {
+	data := `<?xml version="1.0" encoding="UTF-8"?>
+<opml version="1.0">
+  <head><title>Reader export</title></head>
+  <body>
+    <outline text="Tech">
+      <outline text="Blog" type="rss" xmlUrl="https://example.com/feed"/>
+      <outline text="Duplicate" type="rss" xmlUrl="https://example.com/feed"/>
+    </outline>
+    <outline text="News" title="News title" type="rss" xmlUrl=" https://news.example.com/rss "/>
+    <outline text="Bookmark" htmlUrl="https://example.com"/>
+  </body>
+</opml>`
+
+	got, err := ParseOPML(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("ParseOPML() error = %v", err)
+	}
+
+	want := []domain.Feed{
+		{URL: "https://example.com/feed", Title: "Blog"},
+		{URL: "https://news.example.com/rss", Title: "News title"},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("ParseOPML() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("ParseOPML()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestParseOPMLRejectsDocumentsWithoutFeeds(t *testing.T) {
+	for name, data := range map[string]string{
+		"not xml":     "hello",
+		"no outlines": `<opml version="2.0"><head/><body/></opml>`,
+	} {
+		if _, err := ParseOPML(strings.NewReader(data)); err == nil {
+			t.Fatalf("ParseOPML(%s) should fail", name)
+		}
+	}
+}
@@ -12,22 +12,36 @@ import (
 	"telekilogram/internal/config"
 	"telekilogram/internal/database"
 	"telekilogram/internal/domain"
+	"telekilogram/internal/metrics"
 	"telekilogram/internal/summarizer"
 	"time"
 
+	"github.com/PuerkitoBio/goquery"
 	"github.com/mmcdole/gofeed"
 )
 
+// feedItemSummaryInputMaxChars caps the text of a feed item sent to the
+// summarizer, as item content can hold a whole article.
+const feedItemSummaryInputMaxChars = 8000
+
 type telegramSummarizationCandidate struct {
 	postIndex int
 	item      channelItem
 }
 
+type feedItemSummarizationCandidate struct {
+	postIndex int
+	text      string
+}
+
 type Parser struct {
 	db             *database.Database
 	summarizer     summarizer.Summarizer
+	metrics        *metrics.Metrics
 	summaryCache   *telegramSummaryCache
 	libParser      *gofeed.Parser
+	parsedFeeds    *parsedFeedCache
+	feedClient     *http.Client
 	telegramClient *http.Client
 	feedCfg        config.FeedConfig
 	telegramCfg    config.TelegramConfig
@@ -37,7 +51,9 @@ type Parser struct {
 func NewParser(
 	db *database.Database,
 	s summarizer.Summarizer,
+	m *metrics.Metrics,
 	libParser *gofeed.Parser,
+	feedClient *http.Client,
 	telegramClient *http.Client,
 	feedCfg config.FeedConfig,
 	telegramCfg config.TelegramConfig,
@@ -46,8 +62,11 @@ func NewParser(
 	return &Parser{
 		db:             db,
 		summarizer:     s,
+		metrics:        m,
 		summaryCache:   newTelegramSummaryCache(feedCfg.TelegramSummaryCacheMaxEntries),
 		libParser:      libParser,
+		parsedFeeds:    newParsedFeedCache(feedCfg.ParsedFeedCacheMaxEntries),
+		feedClient:     feedClient,
 		telegramClient: telegramClient,
 		feedCfg:        feedCfg,
 		telegramCfg:    telegramCfg,
@@ -55,36 +74,42 @@ func NewParser(
 	}
 }
 
+// ParseFeed returns posts published after since, or within the lookback period
+// when since is zero.
 func (p *Parser) ParseFeed(
 	ctx context.Context,
-	feed *domain.UserFeed,
+	source *domain.Source,
+	since time.Time,
 ) ([]domain.Post, error) {
-	normalizedFeedURL := strings.TrimSpace(feed.URL)
-	normalizedFeedTitle := strings.TrimSpace(feed.Title)
+	normalizedFeedURL := strings.TrimSpace(source.URL)
+	normalizedFeedTitle := strings.TrimSpace(source.Title)
 
 	if ok, slug := isTelegramChannelURL(normalizedFeedURL); ok {
-		return p.parseTelegramChannelFeed(ctx, feed, slug, normalizedFeedTitle)
+		return p.parseTelegramChannelFeed(ctx, source, slug, normalizedFeedTitle, since)
 	}
 
-	parsed, err := p.libParser.ParseURLWithContext(normalizedFeedURL, ctx)
+	fetchStart := time.Now()
+	parsed, err := p.fetchAndParseFeed(ctx, source, normalizedFeedURL)
+	p.metrics.ObserveFeedFetch(metrics.SourceTypeRSS, time.Since(fetchStart), err)
 	if err != nil {
-		return nil, fmt.Errorf("parse feed (URL = %s): %w", normalizedFeedURL, err)
+		return nil, fmt.Errorf("fetch and parse feed (URL = %s): %w", normalizedFeedURL, err)
 	}
 
 	parsedTitle := strings.TrimSpace(parsed.Title)
 
 	var updateTitleErr error
 	if parsedTitle != "" && parsedTitle != normalizedFeedTitle {
-		if err = p.db.UpdateFeedTitle(ctx, feed.ID, parsedTitle); err != nil {
-			updateTitleErr = fmt.Errorf("update feed title: %w", err)
+		if err = p.db.UpdateSourceTitle(ctx, source.ID, parsedTitle); err != nil {
+			updateTitleErr = fmt.Errorf("update source title: %w", err)
 		} else {
 			normalizedFeedTitle = parsedTitle
 		}
 	}
 
 	var newPosts []domain.Post
+	var candidates []feedItemSummarizationCandidate
 	now := time.Now().Round(time.Hour)
-	cutoffTime := now.Add(-24*time.Hour - p.feedCfg.ParseFeedGracePeriod)
+	cutoffTime := p.cutoffTime(now, since)
 
 	for _, item := range parsed.Items {
 		post, ok := p.parseFeedItem(
@@ -94,19 +119,39 @@ func (p *Parser) ParseFeed(
 			normalizedFeedTitle,
 			normalizedFeedURL,
 			parsedTitle,
-			feed.ID,
 			item,
 		)
 		if !ok {
 			continue
 		}
 
+		if source.SummarizeItems {
+			if text := feedItemText(item); text != "" {
+				candidates = append(candidates, feedItemSummarizationCandidate{postIndex: len(newPosts), text: text})
+			}
+		}
+
 		newPosts = append(newPosts, post)
 	}
 
+	summaries := p.summarizeInParallel(len(candidates), func(i int) string {
+		return p.summarizeFeedItem(ctx, newPosts[candidates[i].postIndex], candidates[i].text)
+	})
+	for i, candidate := range candidates {
+		newPosts[candidate.postIndex].Summary = strings.TrimSpace(summaries[i])
+	}
+
 	return newPosts, updateTitleErr
 }
 
+func (p *Parser) cutoffTime(now time.Time, since time.Time) time.Time {
+	if since.IsZero() {
+		since = now.Add(-p.feedCfg.PostLookbackPeriod)
+	}
+
+	return since.Add(-p.feedCfg.ParseFeedGracePeriod)
+}
+
 func (p *Parser) parseFeedItem(
 	ctx context.Context,
 	now time.Time,
@@ -114,7 +159,6 @@ func (p *Parser) parseFeedItem(
 	normalizedFeedTitle string,
 	normalizedFeedURL string,
 	parsedTitle string,
-	feedID int64,
 	item *gofeed.Item,
 ) (domain.Post, bool) {
 	publishedTime := now
@@ -148,7 +192,8 @@ func (p *Parser) parseFeedItem(
 		return domain.Post{
 			Title:     postTitle,
 			URL:       postURL,
-			FeedID:    feedID,
+			GUID:      strings.TrimSpace(item.GUID),
+			Published: publishedTime,
 			FeedTitle: feedTitle,
 			FeedURL:   normalizedFeedURL,
 		}, true
@@ -159,21 +204,31 @@ func (p *Parser) parseFeedItem(
 
 func (p *Parser) parseTelegramChannelFeed(
 	ctx context.Context,
-	feed *domain.UserFeed,
+	source *domain.Source,
 	slug string,
 	normalizedFeedTitle string,
+	since time.Time,
 ) ([]domain.Post, error) {
-	items, channelTitle, err := p.fetchTelegramChannelPosts(ctx, slug)
+	now := time.Now().Round(time.Hour)
+	cutoffTime := p.cutoffTime(now, since)
+
+	fetchStart := time.Now()
+	items, channelTitle, err := p.fetchTelegramChannelPosts(ctx, slug, cutoffTime)
+	p.metrics.ObserveFeedFetch(metrics.SourceTypeTelegram, time.Since(fetchStart), err)
 	if err != nil {
+		p.recordSourceHealth(ctx, source.ID, statusCodeFromError(err), err)
+
 		return nil, fmt.Errorf("fetch Telegram channel items: %w", err)
 	}
 
+	p.recordSourceHealth(ctx, source.ID, http.StatusOK, nil)
+
 	channelTitle = strings.TrimSpace(channelTitle)
 
 	var updateTitleErr error
 	if channelTitle != "" && channelTitle != normalizedFeedTitle {
-		if err = p.db.UpdateFeedTitle(ctx, feed.ID, channelTitle); err != nil {
-			updateTitleErr = fmt.Errorf("update feed title: %w", err)
+		if err = p.db.UpdateSourceTitle(ctx, source.ID, channelTitle); err != nil {
+			updateTitleErr = fmt.Errorf("update source title: %w", err)
 		} else {
 			normalizedFeedTitle = channelTitle
 		}
@@ -181,8 +236,6 @@ func (p *Parser) parseTelegramChannelFeed(
 
 	var (
 		newPosts     []domain.Post
-		now          = time.Now().Round(time.Hour)
-		cutoffTime   = now.Add(-24*time.Hour - p.feedCfg.ParseFeedGracePeriod)
 		candidates   []telegramSummarizationCandidate
 		canonicalURL = TelegramChannelCanonicalURL(slug)
 	)
@@ -208,7 +261,6 @@ func (p *Parser) parseTelegramChannelFeed(
 			slug,
 			it,
 			len(newPosts),
-			feed.ID,
 			feedTitle,
 		)
 		if !ok {
@@ -240,7 +292,6 @@ func (p *Parser) parseTelegramChannelPost(
 	slug string,
 	item channelItem,
 	processedPostCount int,
-	feedID int64,
 	feedTitle string,
 ) (domain.Post, telegramSummarizationCandidate, bool) {
 	publishedTime := item.published
@@ -260,10 +311,12 @@ func (p *Parser) parseTelegramChannelPost(
 		}
 
 		return domain.Post{
+			Text:      item.text,
 			URL:       postURL,
-			FeedID:    feedID,
+			Published: publishedTime,
 			FeedTitle: feedTitle,
 			FeedURL:   canonicalURL,
+			Telegram:  item.meta,
 		}, telegramSummarizationCandidate{postIndex: processedPostCount, item: item}, true
 	}
 
@@ -274,8 +327,16 @@ func (p *Parser) summarizeTelegramPosts(
 	ctx context.Context,
 	candidates []telegramSummarizationCandidate,
 ) []string {
-	summaries := make([]string, len(candidates))
-	if len(candidates) == 0 {
+	return p.summarizeInParallel(len(candidates), func(i int) string {
+		return p.summarizeTelegramPost(ctx, candidates[i].item)
+	})
+}
+
+// summarizeInParallel calls summarize for indexes [0, n) with at most
+// TelegramSummariesMaxParallelism calls at once and keeps results in order.
+func (p *Parser) summarizeInParallel(n int, summarize func(i int) string) []string {
+	summaries := make([]string, n)
+	if n == 0 {
 		return summaries
 	}
 
@@ -283,31 +344,23 @@ func (p *Parser) summarizeTelegramPosts(
 	if workerCount <= 0 {
 		workerCount = 1
 	}
-	if workerCount > len(candidates) {
-		workerCount = len(candidates)
-	}
-
-	type task struct {
-		resultIndex int
-		candidate   telegramSummarizationCandidate
+	if workerCount > n {
+		workerCount = n
 	}
 
-	tasks := make(chan task)
+	tasks := make(chan int)
 	var wg sync.WaitGroup
 
 	for range workerCount {
 		wg.Go(func() {
-			for t := range tasks {
-				summaries[t.resultIndex] = p.summarizeTelegramPost(ctx, t.candidate.item)
+			for i := range tasks {
+				summaries[i] = summarize(i)
 			}
 		})
 	}
 
-	for i := range candidates {
-		tasks <- task{
-			resultIndex: i,
-			candidate:   candidates[i],
-		}
+	for i := range n {
+		tasks <- i
 	}
 
 	close(tasks)
@@ -321,52 +374,109 @@ func (p *Parser) summarizeTelegramPost(
 	item channelItem,
 ) string {
 	text := strings.TrimSpace(item.text)
+	label := telegramMediaLabel(item.meta.MediaKind)
+
 	if text == "" {
-		return item.URL
+		switch {
+		case label != "":
+			return label
+		case item.meta.LinkPreviewTitle != "":
+			return "Link: " + item.meta.LinkPreviewTitle
+		default:
+			return item.URL
+		}
+	}
+
+	summary := p.summarize(ctx, text, item.URL, item.published, telegramSummaryCacheKey(item.URL, text))
+	if label == "" {
+		return summary
 	}
 
+	return label + ": " + summary
+}
+
+// telegramMediaLabel names the media of a post for its summary, so a
+// media-only post reads "Photo" and a captioned one "Photo: <caption>".
+func telegramMediaLabel(kind domain.MediaKind) string {
+	switch kind {
+	case domain.MediaKindPhoto:
+		return "Photo"
+	case domain.MediaKindVideo:
+		return "Video"
+	case domain.MediaKindDocument:
+		return "Document"
+	case domain.MediaKindPoll:
+		return "Poll"
+	case domain.MediaKindVoice:
+		return "Voice message"
+	default:
+		return ""
+	}
+}
+
+// summarizeFeedItem returns a summary of a feed item text, or an empty string
+// when the item has no text.
+func (p *Parser) summarizeFeedItem(
+	ctx context.Context,
+	post domain.Post,
+	text string,
+) string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return ""
+	}
+
+	return p.summarize(ctx, text, post.URL, post.Published, feedItemSummaryCacheKey(post.URL, text))
+}
+
+// summarize returns a cached or new summary of text, falling back to the
+// truncated text when there is no summarizer or it fails.
+func (p *Parser) summarize(
+	ctx context.Context,
+	text string,
+	itemURL string,
+	published time.Time,
+	cacheKey string,
+) string {
 	now := time.Now().UTC()
-	cacheKey := telegramSummaryCacheKey(item.URL, text)
 
-	if cacheKey != "" && p.summaryCache != nil {
-		if summary, ok := p.summaryCache.get(cacheKey, now); ok {
-			return summary
-		}
+	if summary, ok := p.cachedSummary(ctx, cacheKey, now); ok {
+		return summary
 	}
 
 	if p.summarizer == nil {
-		return p.fallbackTelegramSummary(text, item.URL)
+		p.metrics.ObserveSummaryFallback(metrics.FallbackNoSummarizer)
+		return p.fallbackTelegramSummary(text, itemURL)
 	}
 
 	summary, err := p.summarizer.Summarize(ctx, summarizer.Input{
 		Text:      text,
-		SourceURL: item.URL,
+		SourceURL: itemURL,
 	})
 	if err != nil {
-		p.log.ErrorContext(ctx, "Failed to summarize Telegram channel post",
+		p.log.ErrorContext(ctx, "Failed to summarize post",
 			"error", err,
-			"url", item.URL,
+			"url", itemURL,
 			"fallback", true,
 			"cacheKey", cacheKey,
 			"textLen", len(text))
 
-		return p.fallbackTelegramSummary(text, item.URL)
+		p.metrics.ObserveSummaryFallback(metrics.FallbackError)
+		return p.fallbackTelegramSummary(text, itemURL)
 	}
 
 	summary = strings.TrimSpace(summary)
 	if summary == "" {
-		return p.fallbackTelegramSummary(text, item.URL)
+		p.metrics.ObserveSummaryFallback(metrics.FallbackEmpty)
+		return p.fallbackTelegramSummary(text, itemURL)
 	}
 
-	published := item.published
 	if published.IsZero() {
 		published = now
 	}
 
-	expiresAt := published.Add(24*time.Hour + p.feedCfg.ParseFeedGracePeriod)
-	if expiresAt.After(now) && cacheKey != "" && p.summaryCache != nil {
-		p.summaryCache.set(cacheKey, summary, expiresAt, now)
-	}
+	expiresAt := published.Add(p.feedCfg.PostLookbackPeriod + p.feedCfg.ParseFeedGracePeriod)
+	p.storeSummary(ctx, cacheKey, summary, expiresAt, now)
 
 	return summary
 }
@@ -386,6 +496,52 @@ func telegramSummaryCacheKey(rawURL string, text string) string {
 	return canonicalURL + "|" + hex.EncodeToString(hash[:])
 }
 
+func feedItemSummaryCacheKey(itemURL string, text string) string {
+	itemURL = strings.TrimSpace(itemURL)
+	normalizedText := strings.TrimSpace(text)
+	if itemURL == "" || normalizedText == "" {
+		return ""
+	}
+
+	hash := sha256.Sum256([]byte(normalizedText))
+	return itemURL + "|" + hex.EncodeToString(hash[:])
+}
+
+// feedItemText returns the plain text of a feed item, preferring the full
+// content over the description.
+func feedItemText(item *gofeed.Item) string {
+	for _, raw := range []string{item.Content, item.Description} {
+		text := htmlToText(raw)
+		if text == "" {
+			continue
+		}
+
+		if runes := []rune(text); len(runes) > feedItemSummaryInputMaxChars {
+			text = string(runes[:feedItemSummaryInputMaxChars])
+		}
+
+		return text
+	}
+
+	return ""
+}
+
+func htmlToText(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return ""
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(raw))
+	if err != nil {
+		return strings.Join(strings.Fields(raw), " ")
+	}
+
+	doc.Find("script, style").Remove()
+
+	return strings.Join(strings.Fields(doc.Text()), " ")
+}
+
 func (p *Parser) fallbackTelegramSummary(text string, itemURL string) string {
 	normalized := strings.Join(strings.Fields(text), " ")
 	if normalized == "" {
@@ -3,11 +3,17 @@ package feed
 import (
 	"context"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"sync"
 	"telekilogram/internal/config"
+	"telekilogram/internal/domain"
 	"telekilogram/internal/summarizer"
 	"testing"
 	"time"
+
+	"github.com/mmcdole/gofeed"
 )
 
 const editedSummary = "edited summary"
@@ -74,7 +80,7 @@ func TestTelegramSummaryCacheKey(t *testing.T) {
 
 func TestParserSummarizeTelegramPostUsesCache(t *testing.T) {
 	stub := &stubSummarizer{summary: "cached summary"}
-	parser := NewParser(nil, stub, nil, nil, config.FeedConfig{
+	parser := NewParser(nil, stub, nil, nil, nil, nil, config.FeedConfig{
 		TelegramSummaryCacheMaxEntries:  1024,
 		TelegramSummariesMaxParallelism: 4,
 		ParseFeedGracePeriod:            10 * time.Minute,
@@ -105,9 +111,61 @@ func TestParserSummarizeTelegramPostUsesCache(t *testing.T) {
 	}
 }
 
+func TestParserSummarizeTelegramPostLabelsMedia(t *testing.T) {
+	stub := &stubSummarizer{summary: "launch recap"}
+	parser := NewParser(nil, stub, nil, nil, nil, nil, config.FeedConfig{
+		TelegramSummaryCacheMaxEntries: 1024,
+	}, config.TelegramConfig{}, slog.Default())
+
+	tests := []struct {
+		name string
+		item channelItem
+		want string
+	}{
+		{
+			name: "media only",
+			item: channelItem{
+				URL:  "https://t.me/example/1",
+				meta: domain.TelegramPostMeta{MediaKind: domain.MediaKindPhoto},
+			},
+			want: "Photo",
+		},
+		{
+			name: "media with caption",
+			item: channelItem{
+				URL:  "https://t.me/example/2",
+				text: "Launch recap video",
+				meta: domain.TelegramPostMeta{MediaKind: domain.MediaKindVideo},
+			},
+			want: "Video: launch recap",
+		},
+		{
+			name: "link preview only",
+			item: channelItem{
+				URL:  "https://t.me/example/3",
+				meta: domain.TelegramPostMeta{LinkPreviewTitle: "Launch day"},
+			},
+			want: "Link: Launch day",
+		},
+		{
+			name: "nothing to show",
+			item: channelItem{URL: "https://t.me/example/4"},
+			want: "https://t.me/example/4",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parser.summarizeTelegramPost(t.Context(), tt.item); got != tt.want {
+				t.Fatalf("summarizeTelegramPost() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestParserSummarizeTelegramPostEditedTextBypassesCache(t *testing.T) {
 	stub := &stubSummarizer{summary: "original summary"}
-	parser := NewParser(nil, stub, nil, nil, config.FeedConfig{
+	parser := NewParser(nil, stub, nil, nil, nil, nil, config.FeedConfig{
 		TelegramSummaryCacheMaxEntries:  1024,
 		TelegramSummariesMaxParallelism: 4,
 		ParseFeedGracePeriod:            10 * time.Minute,
@@ -154,7 +212,7 @@ func TestParserSummarizeTelegramPostEditedTextBypassesCache(t *testing.T) {
 
 func TestParserSummarizeTelegramPostsPreservesOrder(t *testing.T) {
 	echo := &echoCountingSummarizer{}
-	parser := NewParser(nil, echo, nil, nil, config.FeedConfig{
+	parser := NewParser(nil, echo, nil, nil, nil, nil, config.FeedConfig{
 		TelegramSummaryCacheMaxEntries:  1024,
 		TelegramSummariesMaxParallelism: 4,
 		ParseFeedGracePeriod:            10 * time.Minute,
@@ -202,3 +260,86 @@ func TestParserSummarizeTelegramPostsPreservesOrder(t *testing.T) {
 		}
 	}
 }
+
+func TestFeedItemTextStripsHTMLAndPrefersContent(t *testing.T) {
+	item := &gofeed.Item{
+		Description: "<p>Short description</p>",
+		Content:     "<div><p>Full   <b>article</b> text</p><script>alert(1)</script></div>",
+	}
+
+	if got := feedItemText(item); got != "Full article text" {
+		t.Fatalf("feedItemText() = %q", got)
+	}
+
+	item.Content = "<p> </p>"
+	if got := feedItemText(item); got != "Short description" {
+		t.Fatalf("feedItemText() without content = %q", got)
+	}
+
+	item = &gofeed.Item{Description: strings.Repeat("a", feedItemSummaryInputMaxChars+10)}
+	if got := feedItemText(item); len(got) != feedItemSummaryInputMaxChars {
+		t.Fatalf("feedItemText() kept %d chars, want %d", len(got), feedItemSummaryInputMaxChars)
+	}
+}
+
+func TestParseFeedSummarizesItemsWhenEnabled(t *testing.T) {
+	published := time.Now().UTC().Format(time.RFC1123Z)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`<?xml version="1.0"?>
+<rss version="2.0"><channel><title>Example</title>
+<item><title>Update</title><link>https://example.com/1</link><pubDate>` + published + `</pubDate>
+<description>&lt;p&gt;We shipped &lt;b&gt;dark mode&lt;/b&gt;.&lt;/p&gt;</description></item>
+<item><title>Empty</title><link>https://example.com/2</link><pubDate>` + published + `</pubDate></item>
+</channel></rss>`))
+	}))
+	defer server.Close()
+
+	echo := &echoCountingSummarizer{}
+	feedCfg := config.FeedConfig{
+		TelegramSummaryCacheMaxEntries:  1024,
+		TelegramSummariesMaxParallelism: 2,
+		PostLookbackPeriod:              time.Hour,
+		FallbackTelegramSummaryMaxChars: 200,
+	}
+	feedClient := &http.Client{Timeout: 5 * time.Second}
+	parser := NewParser(
+		nil,
+		echo,
+		nil,
+		gofeed.NewParser(),
+		feedClient,
+		nil,
+		feedCfg,
+		config.TelegramConfig{},
+		slog.Default(),
+	)
+
+	source := &domain.Source{ID: 1, URL: server.URL, Title: "Example"}
+
+	posts, err := parser.ParseFeed(t.Context(), source, time.Time{})
+	if err != nil {
+		t.Fatalf("ParseFeed() error = %v", err)
+	}
+	if len(posts) != 2 || posts[0].Summary != "" || echo.callCount() != 0 {
+		t.Fatalf("ParseFeed() without opt-in = %+v, %d summarizer calls", posts, echo.callCount())
+	}
+
+	source.SummarizeItems = true
+
+	posts, err = parser.ParseFeed(t.Context(), source, time.Time{})
+	if err != nil {
+		t.Fatalf("ParseFeed() error = %v", err)
+	}
+	if len(posts) != 2 {
+		t.Fatalf("ParseFeed() returned %d posts, want 2", len(posts))
+	}
+	if posts[0].Title != "Update" || posts[0].Summary != "We shipped dark mode." {
+		t.Fatalf("summarized post = %+v", posts[0])
+	}
+	if posts[1].Summary != "" {
+		t.Fatalf("post without text got summary %q", posts[1].Summary)
+	}
+	if got := echo.callCount(); got != 1 {
+		t.Fatalf("expected summarizer to be called once, got %d", got)
+	}
+}
@@ -0,0 +1,93 @@
+package feed
+
+import (
+	"cmp"
+	"slices"
+	"telekilogram/internal/domain"
+)
+
+// halves splits sorted view counts at the median.
+const halves = 2
+
+// topPosts keeps the topN most engaging posts of a feed in their original
+// order and returns the rest as hidden. Telegram posts are ranked by views
+// relative to the channel's median, and feed items by recency, then position.
+// A topN of 0 keeps all posts.
+func topPosts(posts []domain.Post, topN int64) ([]domain.Post, []domain.Post) {
+	if topN <= 0 || int64(len(posts)) <= topN {
+		return posts, nil
+	}
+
+	scores := engagementScores(posts)
+
+	order := make([]int, len(posts))
+	for i := range order {
+		order[i] = i
+	}
+
+	slices.SortStableFunc(order, func(a, b int) int {
+		if c := cmp.Compare(scores[b], scores[a]); c != 0 {
+			return c
+		}
+
+		return posts[b].Published.Compare(posts[a].Published)
+	})
+
+	keep := make(map[int]struct{}, topN)
+	for _, i := range order[:topN] {
+		keep[i] = struct{}{}
+	}
+
+	top := make([]domain.Post, 0, topN)
+	hidden := make([]domain.Post, 0, int64(len(posts))-topN)
+
+	for i, post := range posts {
+		if _, ok := keep[i]; ok {
+			top = append(top, post)
+		} else {
+			hidden = append(hidden, post)
+		}
+	}
+
+	return top, hidden
+}
+
+// engagementScores returns views of Telegram posts divided by the median views
+// of the channel. Feed items have no engagement data and all score 0.
+func engagementScores(posts []domain.Post) []float64 {
+	scores := make([]float64, len(posts))
+
+	median := medianViews(posts)
+	if median == 0 {
+		return scores
+	}
+
+	for i, post := range posts {
+		scores[i] = float64(post.Telegram.Views) / median
+	}
+
+	return scores
+}
+
+// medianViews returns the median of known view counts, 0 when there are none.
+func medianViews(posts []domain.Post) float64 {
+	var views []int64
+	for _, post := range posts {
+		if post.Telegram.Views > 0 {
+			views = append(views, post.Telegram.Views)
+		}
+	}
+
+	if len(views) == 0 {
+		return 0
+	}
+
+	slices.Sort(views)
+
+	mid := len(views) / halves
+	if len(views)%halves == 1 {
+		return float64(views[mid])
+	}
+
+	return float64(views[mid-1]+views[mid]) / halves
+}
@@ -0,0 +1,129 @@
+package feed
+
+import (
+	"telekilogram/internal/domain"
+	"testing"
+	"time"
+)
+
+func TestTopPosts(t *testing.T) {
+	base := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	telegramPost := func(id string, views int64, minutes int) domain.Post {
+		return domain.Post{
+			URL:       "https://t.me/channel/" + id,
+			FeedURL:   "https://t.me/s/channel",
+			Published: base.Add(time.Duration(minutes) * time.Minute),
+			Telegram:  domain.TelegramPostMeta{Views: views},
+		}
+	}
+	feedItem := func(id string, minutes int) domain.Post {
+		return domain.Post{
+			URL:       "https://example.com/" + id,
+			FeedURL:   "https://example.com/feed.xml",
+			Published: base.Add(time.Duration(minutes) * time.Minute),
+		}
+	}
+
+	channelPosts := []domain.Post{
+		telegramPost("1", 900, 0),
+		telegramPost("2", 15_000, 10),
+		telegramPost("3", 1_000, 20),
+		telegramPost("4", 4_000, 30),
+		telegramPost("5", 1_100, 40),
+	}
+	feedItems := []domain.Post{
+		feedItem("a", 30),
+		feedItem("b", 10),
+		feedItem("c", 20),
+	}
+	undatedItems := []domain.Post{
+		feedItem("x", 0),
+		feedItem("y", 0),
+		feedItem("z", 0),
+	}
+
+	tests := []struct {
+		name       string
+		posts      []domain.Post
+		topN       int64
+		wantTop    []string
+		wantHidden []string
+	}{
+		{
+			name:    "zero keeps all posts",
+			posts:   channelPosts,
+			wantTop: postURLs(channelPosts),
+		},
+		{
+			name:    "fewer posts than top N",
+			posts:   feedItems,
+			topN:    5,
+			wantTop: postURLs(feedItems),
+		},
+		{
+			name:       "Telegram posts by views in original order",
+			posts:      channelPosts,
+			topN:       2,
+			wantTop:    []string{"https://t.me/channel/2", "https://t.me/channel/4"},
+			wantHidden: []string{"https://t.me/channel/1", "https://t.me/channel/3", "https://t.me/channel/5"},
+		},
+		{
+			name:       "feed items by recency",
+			posts:      feedItems,
+			topN:       2,
+			wantTop:    []string{"https://example.com/a", "https://example.com/c"},
+			wantHidden: []string{"https://example.com/b"},
+		},
+		{
+			name:       "undated feed items by position",
+			posts:      undatedItems,
+			topN:       1,
+			wantTop:    []string{"https://example.com/x"},
+			wantHidden: []string{"https://example.com/y", "https://example.com/z"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			top, hidden := topPosts(tt.posts, tt.topN)
+
+			assertPostURLs(t, "top", top, tt.wantTop)
+			assertPostURLs(t, "hidden", hidden, tt.wantHidden)
+		})
+	}
+}
+
+func TestMedianViews(t *testing.T) {
+	tests := []struct {
+		name  string
+		views []int64
+		want  float64
+	}{
+		{name: "no views", views: []int64{0, 0}, want: 0},
+		{name: "odd count", views: []int64{300, 100, 200}, want: 200},
+		{name: "even count ignores unknown views", views: []int64{400, 0, 100, 200, 300}, want: 250},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			posts := make([]domain.Post, 0, len(tt.views))
+			for _, views := range tt.views {
+				posts = append(posts, domain.Post{Telegram: domain.TelegramPostMeta{Views: views}})
+			}
+
+			if got := medianViews(posts); got != tt.want {
+				t.Fatalf("medianViews() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func postURLs(posts []domain.Post) []string {
+	urls := make([]string, 0, len(posts))
+	for _, post := range posts {
+		urls = append(urls, post.URL)
+	}
+
+	return urls
+}
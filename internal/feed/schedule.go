@@ -0,0 +1,194 @@
+package feed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"telekilogram/internal/domain"
+	"time"
+)
+
+const hoursPerDay = 24
+
+// digestFeeds returns feeds of users with a digest slot on the hourly tick at,
+// resolving every user time zone again so DST shifts apply.
+func (f *Fetcher) digestFeeds(ctx context.Context, at time.Time) ([]domain.UserFeed, error) {
+	var feeds []domain.UserFeed
+	var errs []error
+
+	timezones, err := f.db.GetUserSettingsTimezones(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get user settings timezones: %w", err)
+	}
+	if !slices.Contains(timezones, domain.DefaultTimezone) {
+		timezones = append(timezones, domain.DefaultTimezone)
+	}
+
+	seen := make(map[int64]struct{})
+	appendUnseen := func(hourFeeds []domain.UserFeed) {
+		for _, feed := range hourFeeds {
+			if _, ok := seen[feed.ID]; ok {
+				continue
+			}
+
+			seen[feed.ID] = struct{}{}
+			feeds = append(feeds, feed)
+		}
+	}
+
+	for _, timezone := range timezones {
+		loc, loadErr := time.LoadLocation(timezone)
+		if loadErr != nil {
+			errs = append(errs, fmt.Errorf("load location (timezone = %s): %w", timezone, loadErr))
+			continue
+		}
+
+		weekday := at.In(loc).Weekday()
+
+		for _, hour := range localDigestHours(at, loc) {
+			hourFeeds, getErr := f.db.GetHourFeeds(ctx, timezone, hour, weekday)
+			if getErr != nil {
+				errs = append(errs, fmt.Errorf("get hour feeds (timezone = %s, hour = %d): %w", timezone, hour, getErr))
+				continue
+			}
+
+			appendUnseen(hourFeeds)
+
+			if hour != domain.DefaultAutoDigestHour {
+				continue
+			}
+
+			defaultFeeds, getErr := f.db.GetDefaultScheduleFeeds(ctx, timezone)
+			if getErr != nil {
+				errs = append(errs, fmt.Errorf("get default schedule feeds (timezone = %s): %w", timezone, getErr))
+				continue
+			}
+
+			appendUnseen(defaultFeeds)
+		}
+	}
+
+	return feeds, errors.Join(errs...)
+}
+
+// digestSince returns the start of each user's digest window: the previous
+// delivery, but never later than the lookback period, so slow feeds still get
+// through, and never earlier than delivered posts are remembered.
+func (f *Fetcher) digestSince(
+	ctx context.Context,
+	feeds []domain.UserFeed,
+	now time.Time,
+) (map[int64]time.Time, error) {
+	sinceByUser := make(map[int64]time.Time)
+	var errs []error
+
+	floor := time.Time{}
+	if f.parser.feedCfg.DeliveredPostsRetention > 0 {
+		floor = now.Add(-f.parser.feedCfg.DeliveredPostsRetention)
+	}
+
+	for _, feed := range feeds {
+		if _, ok := sinceByUser[feed.UserID]; ok {
+			continue
+		}
+
+		since := now.Add(-f.parser.feedCfg.PostLookbackPeriod)
+
+		deliveredAt, err := f.db.GetDigestDeliveredAt(ctx, feed.UserID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("get digest delivered at (userID = %d): %w", feed.UserID, err))
+		} else if !deliveredAt.IsZero() && deliveredAt.Before(since) {
+			since = deliveredAt
+		}
+
+		if since.Before(floor) {
+			since = floor
+		}
+
+		sinceByUser[feed.UserID] = since
+	}
+
+	return sinceByUser, errors.Join(errs...)
+}
+
+// DueDigestSlots returns the hourly digest slots that haven't completed, from
+// maxLookback before now up to the slot of now, oldest first. Before any slot
+// has completed, only the slot of now is due, so the first run doesn't catch
+// up a whole day.
+func (f *Fetcher) DueDigestSlots(
+	ctx context.Context,
+	now time.Time,
+	maxLookback time.Duration,
+) ([]time.Time, error) {
+	runs, err := f.db.GetDigestSlotRuns(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get digest slot runs: %w", err)
+	}
+
+	return dueDigestSlots(runs, now, maxLookback), nil
+}
+
+// RecordDigestSlotRun records that the digest of the slot is done, so it isn't
+// caught up later.
+func (f *Fetcher) RecordDigestSlotRun(ctx context.Context, slotAt time.Time) error {
+	if err := f.db.UpsertDigestSlotRun(ctx, slotAt, time.Now()); err != nil {
+		return fmt.Errorf("upsert digest slot run: %w", err)
+	}
+
+	return nil
+}
+
+func dueDigestSlots(runs map[int64]time.Time, now time.Time, maxLookback time.Duration) []time.Time {
+	current := now.UTC().Truncate(time.Hour)
+	if len(runs) == 0 {
+		return []time.Time{current}
+	}
+
+	first := current
+	earliest := now.UTC().Add(-maxLookback)
+	for first.Add(-time.Hour).Compare(earliest) >= 0 {
+		first = first.Add(-time.Hour)
+	}
+
+	var latest time.Time
+	for _, slotAt := range runs {
+		if slotAt.After(latest) {
+			latest = slotAt
+		}
+	}
+
+	// A slot before the latest completed one is due only when its hour has
+	// completed on an earlier day, as a run that stopped halfway. Hours that
+	// never completed predate the first run.
+	var slots []time.Time
+	for slot := first; !slot.After(current); slot = slot.Add(time.Hour) {
+		lastRun, ok := runs[int64(slot.Hour())]
+
+		switch {
+		case ok && !lastRun.Before(slot):
+			continue
+		case !ok && !slot.After(latest):
+			continue
+		}
+
+		slots = append(slots, slot)
+	}
+
+	return slots
+}
+
+// localDigestHours returns the local hours reached in loc since the previous
+// hourly tick. It returns two hours when a DST jump skips one, and none when
+// the clock falls back and repeats an hour, so each digest runs once a day.
+func localDigestHours(at time.Time, loc *time.Location) []int64 {
+	hour := at.In(loc).Hour()
+	prevHour := at.Add(-time.Hour).In(loc).Hour()
+
+	var hours []int64
+	for h := (prevHour + 1) % hoursPerDay; h != (hour+1)%hoursPerDay; h = (h + 1) % hoursPerDay {
+		hours = append(hours, int64(h))
+	}
+
+	return hours
+}
@@ -0,0 +1,201 @@
+package feed
+
+import (
+	"slices"
+	"telekilogram/internal/domain"
+	"testing"
+	"time"
+)
+
+func TestLocalDigestHours(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		at   time.Time
+		loc  *time.Location
+		want []int64
+	}{
+		{
+			name: "UTC",
+			at:   time.Date(2025, 1, 10, 7, 0, 0, 0, time.UTC),
+			loc:  time.UTC,
+			want: []int64{7},
+		},
+		{
+			name: "winter offset",
+			at:   time.Date(2025, 1, 10, 7, 0, 0, 0, time.UTC),
+			loc:  berlin,
+			want: []int64{8},
+		},
+		{
+			name: "summer offset",
+			at:   time.Date(2025, 7, 10, 7, 0, 0, 0, time.UTC),
+			loc:  berlin,
+			want: []int64{9},
+		},
+		{
+			name: "spring forward runs the skipped hour too",
+			at:   time.Date(2025, 3, 30, 1, 0, 0, 0, time.UTC),
+			loc:  berlin,
+			want: []int64{2, 3},
+		},
+		{
+			name: "fall back doesn't repeat the hour",
+			at:   time.Date(2025, 10, 26, 1, 0, 0, 0, time.UTC),
+			loc:  berlin,
+			want: nil,
+		},
+		{
+			name: "half-hour offset",
+			at:   time.Date(2025, 1, 10, 18, 0, 0, 0, time.UTC),
+			loc:  kolkata,
+			want: []int64{23},
+		},
+		{
+			name: "midnight wraps around",
+			at:   time.Date(2025, 1, 10, 23, 0, 0, 0, time.UTC),
+			loc:  berlin,
+			want: []int64{0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := localDigestHours(tt.at, tt.loc); !slices.Equal(got, tt.want) {
+				t.Fatalf("localDigestHours() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostsSince(t *testing.T) {
+	since := time.Date(2025, 1, 10, 8, 0, 0, 0, time.UTC)
+	posts := []domain.Post{
+		{URL: "old", Published: since.Add(-time.Minute)},
+		{URL: "edge", Published: since},
+		{URL: "new", Published: since.Add(time.Hour)},
+		{URL: "undated"},
+	}
+
+	var got []string
+	for _, post := range postsSince(posts, since) {
+		got = append(got, post.URL)
+	}
+
+	if want := []string{"edge", "new", "undated"}; !slices.Equal(got, want) {
+		t.Fatalf("postsSince() = %v, want %v", got, want)
+	}
+
+	if got := postsSince(posts, time.Time{}); len(got) != len(posts) {
+		t.Fatalf("postsSince() with zero since kept %d posts, want %d", len(got), len(posts))
+	}
+}
+
+func TestFetchRunEarliestSince(t *testing.T) {
+	weekAgo := time.Date(2025, 1, 3, 8, 0, 0, 0, time.UTC)
+	dayAgo := time.Date(2025, 1, 9, 8, 0, 0, 0, time.UTC)
+
+	run := &fetchRun{since: map[int64]time.Time{1: dayAgo, 2: weekAgo}}
+
+	got := run.earliestSince([]domain.UserFeed{{UserID: 1}, {UserID: 2}, {UserID: 3}})
+	if !got.Equal(weekAgo) {
+		t.Fatalf("earliestSince() = %v, want %v", got, weekAgo)
+	}
+
+	if got = run.earliestSince([]domain.UserFeed{{UserID: 3}}); !got.IsZero() {
+		t.Fatalf("earliestSince() for unknown users = %v, want zero", got)
+	}
+}
+
+func TestPostsUntil(t *testing.T) {
+	until := time.Date(2025, 1, 10, 8, 0, 0, 0, time.UTC)
+	posts := []domain.Post{
+		{URL: "old", Published: until.Add(-time.Hour)},
+		{URL: "edge", Published: until},
+		{URL: "new", Published: until.Add(time.Minute)},
+	}
+
+	var got []string
+	for _, post := range postsUntil(posts, until) {
+		got = append(got, post.URL)
+	}
+
+	if want := []string{"old", "edge"}; !slices.Equal(got, want) {
+		t.Fatalf("postsUntil() = %v, want %v", got, want)
+	}
+
+	if got := postsUntil(posts, time.Time{}); len(got) != len(posts) {
+		t.Fatalf("postsUntil() with zero until kept %d posts, want %d", len(got), len(posts))
+	}
+}
+
+func TestDueDigestSlots(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 30, 0, 0, time.UTC)
+	slot := func(day, hour int) time.Time { return time.Date(2025, 1, day, hour, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name        string
+		runs        map[int64]time.Time
+		maxLookback time.Duration
+		want        []time.Time
+	}{
+		{
+			name:        "first run",
+			runs:        map[int64]time.Time{},
+			maxLookback: 24 * time.Hour,
+			want:        []time.Time{slot(10, 12)},
+		},
+		{
+			name:        "current slot done",
+			runs:        map[int64]time.Time{11: slot(10, 11), 12: slot(10, 12)},
+			maxLookback: 24 * time.Hour,
+			want:        nil,
+		},
+		{
+			name:        "slots missed after the last run",
+			runs:        map[int64]time.Time{9: slot(10, 9), 10: slot(9, 10), 11: slot(9, 11)},
+			maxLookback: 24 * time.Hour,
+			want:        []time.Time{slot(10, 10), slot(10, 11), slot(10, 12)},
+		},
+		{
+			name:        "stopped run between completed ones",
+			runs:        map[int64]time.Time{10: slot(10, 10), 11: slot(9, 11), 12: slot(10, 12)},
+			maxLookback: 24 * time.Hour,
+			want:        []time.Time{slot(10, 11)},
+		},
+		{
+			name:        "hours that never completed predate the first run",
+			runs:        map[int64]time.Time{11: slot(10, 11)},
+			maxLookback: 24 * time.Hour,
+			want:        []time.Time{slot(10, 12)},
+		},
+		{
+			name:        "lookback limits catch-up",
+			runs:        map[int64]time.Time{2: slot(10, 2)},
+			maxLookback: 2 * time.Hour,
+			want:        []time.Time{slot(10, 11), slot(10, 12)},
+		},
+		{
+			name:        "zero lookback keeps the current slot",
+			runs:        map[int64]time.Time{2: slot(10, 2)},
+			maxLookback: 0,
+			want:        []time.Time{slot(10, 12)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dueDigestSlots(tt.runs, now, tt.maxLookback); !slices.Equal(got, tt.want) {
+				t.Fatalf("dueDigestSlots() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,10 +2,19 @@ package feed
 
 import (
 	"container/list"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
 	"sync"
+	"telekilogram/internal/domain"
+	"telekilogram/internal/metrics"
 	"time"
 )
 
+// telegramSummaryCache is the in-memory tier of the summary cache, in front of
+// the summary_cache table that keeps summaries across restarts.
 type telegramSummaryCache struct {
 	mu         sync.Mutex
 	entries    map[string]*list.Element
@@ -133,3 +142,101 @@ func (c *telegramSummaryCache) removeElement(elem *list.Element) {
 	delete(c.entries, entry.key)
 	c.order.Remove(elem)
 }
+
+// cachedSummary looks a summary up in memory first and in the database next,
+// copying database hits into memory.
+func (p *Parser) cachedSummary(ctx context.Context, key string, now time.Time) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+
+	if summary, ok := p.summaryCache.get(key, now); ok {
+		p.metrics.ObserveSummaryCacheLookup(metrics.CacheHitMemory)
+		return summary, true
+	}
+
+	if p.db == nil {
+		p.metrics.ObserveSummaryCacheLookup(metrics.CacheMiss)
+		return "", false
+	}
+
+	entry, err := p.db.GetSummaryCacheEntry(ctx, key, now)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			p.log.WarnContext(ctx, "Failed to get summary cache entry",
+				"error", err,
+				"cacheKey", key)
+		}
+
+		p.metrics.ObserveSummaryCacheLookup(metrics.CacheMiss)
+		return "", false
+	}
+
+	p.summaryCache.set(key, entry.Summary, entry.ExpiresAt, now)
+	p.metrics.ObserveSummaryCacheLookup(metrics.CacheHitDatabase)
+
+	return entry.Summary, true
+}
+
+func (p *Parser) storeSummary(
+	ctx context.Context,
+	key string,
+	summary string,
+	expiresAt time.Time,
+	now time.Time,
+) {
+	if key == "" || summary == "" || !expiresAt.After(now) {
+		return
+	}
+
+	p.summaryCache.set(key, summary, expiresAt, now)
+
+	if p.db == nil {
+		return
+	}
+
+	if err := p.db.UpsertSummaryCacheEntry(ctx, &domain.SummaryCacheEntry{
+		Key:       key,
+		Summary:   summary,
+		ExpiresAt: expiresAt,
+	}); err != nil {
+		p.log.WarnContext(ctx, "Failed to store summary cache entry",
+			"error", err,
+			"cacheKey", key)
+	}
+}
+
+// WarmSummaryCache loads the latest unexpired summaries from the database into
+// memory and returns how many were loaded.
+func (p *Parser) WarmSummaryCache(ctx context.Context) (int, error) {
+	if p.db == nil || p.summaryCache == nil {
+		return 0, nil
+	}
+
+	now := time.Now().UTC()
+
+	entries, err := p.db.GetLatestSummaryCacheEntries(ctx, now, int64(p.summaryCache.maxEntries))
+	if err != nil {
+		return 0, fmt.Errorf("get latest summary cache entries: %w", err)
+	}
+
+	// Entries expiring last are set last, so eviction drops the others first.
+	for _, entry := range slices.Backward(entries) {
+		p.summaryCache.set(entry.Key, entry.Summary, entry.ExpiresAt, now)
+	}
+
+	return len(entries), nil
+}
+
+func (p *Parser) PruneSummaryCache(ctx context.Context) (int64, error) {
+	if p.db == nil {
+		return 0, nil
+	}
+
+	removed, err := p.db.RemoveSummaryCacheEntriesBefore(ctx, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("remove summary cache entries before: %w", err)
+	}
+
+	return removed, nil
+}
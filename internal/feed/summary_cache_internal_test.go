@@ -1,10 +1,36 @@
 package feed
 
 import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"telekilogram/internal/config"
+	"telekilogram/internal/database"
 	"testing"
 	"time"
 )
 
+func newSummaryCacheTestDB(t *testing.T) *database.Database {
+	t.Helper()
+
+	db, err := database.New(context.Background(), filepath.Join(t.TempDir(), "test.db"), slog.Default())
+	if err != nil {
+		t.Fatalf("new database: %v", err)
+	}
+
+	return db
+}
+
+func newSummaryCacheTestParser(db *database.Database, s *stubSummarizer) *Parser {
+	return NewParser(db, s, nil, nil, nil, nil, config.FeedConfig{
+		TelegramSummaryCacheMaxEntries:  1024,
+		TelegramSummariesMaxParallelism: 4,
+		PostLookbackPeriod:              24 * time.Hour,
+		ParseFeedGracePeriod:            10 * time.Minute,
+		FallbackTelegramSummaryMaxChars: 200,
+	}, config.TelegramConfig{}, slog.Default())
+}
+
 func TestTelegramSummaryCacheGetSet(t *testing.T) {
 	cache := newTelegramSummaryCache(2)
 	if cache == nil {
@@ -64,3 +90,79 @@ func TestTelegramSummaryCacheEvictsLeastRecentlyUsed(t *testing.T) {
 		t.Fatalf("expected entry c to be cached")
 	}
 }
+
+func TestParserSummaryCacheSurvivesRestart(t *testing.T) {
+	db := newSummaryCacheTestDB(t)
+	ctx := context.Background()
+
+	item := channelItem{
+		URL:       "https://t.me/example/123",
+		text:      "Example post text",
+		published: time.Now().UTC(),
+	}
+
+	first := &stubSummarizer{summary: "stored summary"}
+	if got := newSummaryCacheTestParser(db, first).summarizeTelegramPost(ctx, item); got != "stored summary" {
+		t.Fatalf("unexpected first summary: %q", got)
+	}
+
+	second := &stubSummarizer{summary: "new summary"}
+	if got := newSummaryCacheTestParser(db, second).summarizeTelegramPost(ctx, item); got != "stored summary" {
+		t.Fatalf("expected summary from database, got %q", got)
+	}
+
+	if got := second.callCount(); got != 0 {
+		t.Fatalf("expected summarizer not to be called after restart, got %d", got)
+	}
+}
+
+func TestParserWarmSummaryCacheLoadsEntries(t *testing.T) {
+	db := newSummaryCacheTestDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	stored := newSummaryCacheTestParser(db, &stubSummarizer{})
+	stored.storeSummary(ctx, "fresh", "fresh summary", now.Add(time.Hour), now)
+	stored.storeSummary(ctx, "stale", "stale summary", now.Add(time.Second), now)
+
+	parser := newSummaryCacheTestParser(db, &stubSummarizer{})
+	parser.summaryCache.maxEntries = 1
+
+	warmed, err := parser.WarmSummaryCache(ctx)
+	if err != nil {
+		t.Fatalf("warm summary cache: %v", err)
+	}
+
+	if warmed != 1 {
+		t.Fatalf("expected 1 warmed entry, got %d", warmed)
+	}
+
+	if summary, ok := parser.summaryCache.get("fresh", now); !ok || summary != "fresh summary" {
+		t.Fatalf("expected latest entry in memory, got %q (ok = %t)", summary, ok)
+	}
+}
+
+func TestParserPruneSummaryCacheRemovesExpiredEntries(t *testing.T) {
+	db := newSummaryCacheTestDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC()
+
+	parser := newSummaryCacheTestParser(db, &stubSummarizer{})
+	parser.storeSummary(ctx, "fresh", "fresh summary", now.Add(time.Hour), now)
+	parser.storeSummary(ctx, "expired", "expired summary", now.Add(time.Millisecond), now)
+
+	time.Sleep(10 * time.Millisecond)
+
+	removed, err := parser.PruneSummaryCache(ctx)
+	if err != nil {
+		t.Fatalf("prune summary cache: %v", err)
+	}
+
+	if removed != 1 {
+		t.Fatalf("expected 1 removed entry, got %d", removed)
+	}
+
+	if _, err = db.GetSummaryCacheEntry(ctx, "fresh", time.Now().UTC()); err != nil {
+		t.Fatalf("expected fresh entry to remain: %v", err)
+	}
+}
@@ -4,10 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"net/url"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"telekilogram/internal/domain"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
@@ -30,6 +34,24 @@ type channelItem struct {
 	URL       string
 	text      string
 	published time.Time
+	meta      domain.TelegramPostMeta
+}
+
+type telegramMediaSelector struct {
+	selector string
+	kind     domain.MediaKind
+}
+
+// telegramMediaSelectors maps preview page elements to media kinds, checked in
+// order, so a video with a photo thumbnail is a video.
+func telegramMediaSelectors() []telegramMediaSelector {
+	return []telegramMediaSelector{
+		{".tgme_widget_message_poll", domain.MediaKindPoll},
+		{".tgme_widget_message_voice, .tgme_widget_message_voice_player", domain.MediaKindVoice},
+		{".tgme_widget_message_video_player, .tgme_widget_message_roundvideo_player", domain.MediaKindVideo},
+		{".tgme_widget_message_document", domain.MediaKindDocument},
+		{".tgme_widget_message_photo_wrap, .tgme_widget_message_photo", domain.MediaKindPhoto},
+	}
 }
 
 func TelegramMessageCanonicalURL(raw string) string {
@@ -58,6 +80,12 @@ func TelegramChannelCanonicalURL(slug string) string {
 	return fmt.Sprintf("https://%s/s/%s", telegramHost, slug)
 }
 
+// IsTelegramChannelURL reports whether raw points to a public Telegram channel.
+func IsTelegramChannelURL(raw string) bool {
+	ok, _ := isTelegramChannelURL(raw)
+	return ok
+}
+
 func isTelegramChannelURL(raw string) (bool, string) {
 	raw = strings.TrimSpace(raw)
 	u, err := url.Parse(raw)
@@ -131,7 +159,7 @@ func (f *Fetcher) fetchTelegramChannelTitle(
 	}()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("do request: unexpected status: %d", resp.StatusCode)
+		return "", fmt.Errorf("do request: %w", &statusError{statusCode: resp.StatusCode})
 	}
 
 	doc, err := goquery.NewDocumentFromReader(resp.Body)
@@ -146,45 +174,136 @@ func (f *Fetcher) fetchTelegramChannelTitle(
 	return strings.TrimSpace(doc.Find(".tgme_channel_info_header_title > span").Text()), nil
 }
 
+// fetchTelegramChannelPosts reads the channel preview page and follows
+// ?before=<id> to older pages until a page reaches cutoffTime, up to
+// TELEGRAM_MAX_PAGES pages with TELEGRAM_PAGE_DELAY between requests. Items are
+// returned oldest first. A failed older page ends the walk with the items read
+// so far, as only the first page decides whether the channel is reachable.
 func (p *Parser) fetchTelegramChannelPosts(
 	ctx context.Context,
 	slug string,
+	cutoffTime time.Time,
 ) ([]channelItem, string, error) {
 	canonicalURL := TelegramChannelCanonicalURL(slug)
 	if canonicalURL == "" {
 		return nil, "", errors.New("slug is empty")
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, canonicalURL, nil)
+	doc, err := p.fetchTelegramChannelPage(ctx, canonicalURL, slug)
 	if err != nil {
-		return nil, "", fmt.Errorf("create request: %w", err)
+		return nil, "", err
+	}
+
+	var title string
+
+	if content, ok := doc.Find("meta[property='og:title']").Attr("content"); ok {
+		title = strings.TrimSpace(content)
+	}
+
+	if title == "" {
+		title = strings.TrimSpace(doc.Find(".tgme_channel_info_header_title").Text())
+	}
+
+	items, err := channelPageItems(doc)
+	if err != nil {
+		return items, title, err
+	}
+
+	seen := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		seen[item.URL] = struct{}{}
+	}
+
+	for page := 2; page <= p.telegramCfg.MaxPages; page++ {
+		before, ok := olderTelegramPageCursor(items, cutoffTime)
+		if !ok {
+			break
+		}
+
+		if err = sleepContext(ctx, p.telegramCfg.PageDelay); err != nil {
+			return items, title, nil
+		}
+
+		pageURL := fmt.Sprintf("%s?before=%d", canonicalURL, before)
+
+		doc, err = p.fetchTelegramChannelPage(ctx, pageURL, slug)
+		if err != nil {
+			p.log.WarnContext(ctx, "Failed to fetch older Telegram channel page",
+				"error", err,
+				"pageURL", pageURL,
+				"slug", slug,
+				"page", page)
+			break
+		}
+
+		pageItems, pageErr := channelPageItems(doc)
+		if pageErr != nil {
+			p.log.WarnContext(ctx, "Failed to process older Telegram channel page",
+				"error", pageErr,
+				"pageURL", pageURL,
+				"slug", slug,
+				"page", page)
+		}
+
+		var older []channelItem
+		for _, item := range pageItems {
+			if _, dup := seen[item.URL]; dup {
+				continue
+			}
+
+			seen[item.URL] = struct{}{}
+			older = append(older, item)
+		}
+
+		if len(older) == 0 {
+			break
+		}
+
+		items = slices.Concat(older, items)
+	}
+
+	return items, title, nil
+}
+
+func (p *Parser) fetchTelegramChannelPage(
+	ctx context.Context,
+	pageURL string,
+	slug string,
+) (*goquery.Document, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
 	}
 
 	req.Header.Set("User-Agent", p.telegramCfg.UserAgent)
 
 	resp, err := p.telegramClient.Do(req)
 	if err != nil {
-		return nil, "", fmt.Errorf("do request: %w", err)
+		return nil, fmt.Errorf("do request: %w", err)
 	}
 	defer func() {
 		if err = resp.Body.Close(); err != nil {
 			p.log.ErrorContext(ctx, "Failed to close response body",
 				"error", err,
-				"canonicalURL", canonicalURL,
-				"operation", "fetchTelegramChannelPosts",
+				"pageURL", pageURL,
+				"operation", "fetchTelegramChannelPage",
 				"slug", slug)
 		}
 	}()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, "", fmt.Errorf("do request: unexpected status: %d", resp.StatusCode)
+		return nil, fmt.Errorf("do request: %w", &statusError{statusCode: resp.StatusCode})
 	}
 
 	doc, err := goquery.NewDocumentFromReader(resp.Body)
 	if err != nil {
-		return nil, "", fmt.Errorf("create document from reader: %w", err)
+		return nil, fmt.Errorf("create document from reader: %w", err)
 	}
 
+	return doc, nil
+}
+
+func channelPageItems(doc *goquery.Document) ([]channelItem, error) {
 	var items []channelItem
 	var errs []error
 
@@ -198,17 +317,69 @@ func (p *Parser) fetchTelegramChannelPosts(
 		items = append(items, item)
 	})
 
-	var title string
+	return items, errors.Join(errs...)
+}
 
-	if content, ok := doc.Find("meta[property='og:title']").Attr("content"); ok {
-		title = strings.TrimSpace(content)
+// olderTelegramPageCursor returns the ?before= value of the page preceding
+// items, or false when the oldest item already reaches cutoffTime or there is
+// nothing older.
+func olderTelegramPageCursor(items []channelItem, cutoffTime time.Time) (int64, bool) {
+	var oldestID int64
+	var oldestPublished time.Time
+
+	for _, item := range items {
+		id, ok := telegramMessageID(item.URL)
+		if !ok {
+			continue
+		}
+
+		if oldestID == 0 || id < oldestID {
+			oldestID = id
+			oldestPublished = item.published
+		}
 	}
 
-	if title == "" {
-		title = strings.TrimSpace(doc.Find(".tgme_channel_info_header_title").Text())
+	if oldestID <= 1 || !oldestPublished.After(cutoffTime) {
+		return 0, false
 	}
 
-	return items, title, errors.Join(errs...)
+	return oldestID, true
+}
+
+// telegramMessageID returns the message ID of a https://t.me/<slug>/<id> URL.
+func telegramMessageID(messageURL string) (int64, bool) {
+	u, err := url.Parse(messageURL)
+	if err != nil {
+		return 0, false
+	}
+
+	_, rawID, found := strings.Cut(strings.Trim(u.Path, "/"), "/")
+	if !found {
+		return 0, false
+	}
+
+	id, err := strconv.ParseInt(rawID, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+
+	return id, true
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
 }
 
 func processFoundDocItem(s *goquery.Selection) (channelItem, error) {
@@ -221,7 +392,7 @@ func processFoundDocItem(s *goquery.Selection) (channelItem, error) {
 
 	var textBuilder strings.Builder
 	message := s.ParentsFiltered(".tgme_widget_message").First()
-	message.Find(".tgme_widget_message_text, .tgme_widget_message_caption").Each(
+	message.Find(".tgme_widget_message_text, .tgme_widget_message_caption, .tgme_widget_message_poll_question").Each(
 		func(_ int, inner *goquery.Selection) {
 			inner.Find("br").Each(func(_ int, br *goquery.Selection) {
 				br.ReplaceWithHtml("\n")
@@ -237,6 +408,7 @@ func processFoundDocItem(s *goquery.Selection) (channelItem, error) {
 		},
 	)
 	text := strings.TrimSpace(textBuilder.String())
+	meta := channelMessageMeta(message)
 
 	var t time.Time
 	datetime := strings.TrimSpace(s.Find("time").AttrOr("datetime", ""))
@@ -253,7 +425,68 @@ func processFoundDocItem(s *goquery.Selection) (channelItem, error) {
 		t = time.Now().UTC()
 	}
 
-	return channelItem{URL: href, text: text, published: t}, nil
+	return channelItem{URL: href, text: text, published: t, meta: meta}, nil
+}
+
+func channelMessageMeta(message *goquery.Selection) domain.TelegramPostMeta {
+	var meta domain.TelegramPostMeta
+
+	for _, media := range telegramMediaSelectors() {
+		if message.Find(media.selector).Length() > 0 {
+			meta.MediaKind = media.kind
+			break
+		}
+	}
+
+	meta.Views = parseTelegramViews(message.Find(".tgme_widget_message_views").First().Text())
+	meta.ForwardedFrom = strings.TrimSpace(message.Find(".tgme_widget_message_forwarded_from_name").First().Text())
+	meta.ReplyTo = strings.Join(strings.Fields(
+		message.Find(".tgme_widget_message_reply .tgme_widget_message_metatext").First().Text(),
+	), " ")
+	meta.LinkPreviewTitle = strings.TrimSpace(
+		message.Find(".tgme_widget_message_link_preview .link_preview_title").First().Text(),
+	)
+
+	message.Find(".tgme_widget_message_text a[href], a.tgme_widget_message_link_preview[href]").Each(
+		func(_ int, link *goquery.Selection) {
+			href := strings.TrimSpace(link.AttrOr("href", ""))
+			if strings.HasPrefix(href, "http") && !slices.Contains(meta.Links, href) {
+				meta.Links = append(meta.Links, href)
+			}
+		},
+	)
+
+	// Edited posts show "edited" before the date in the footer.
+	metaText := message.Find(".tgme_widget_message_meta").First().Clone()
+	metaText.Find(".tgme_widget_message_views, .copyonly").Remove()
+	meta.Edited = strings.Contains(strings.ToLower(metaText.Text()), "edited")
+
+	return meta
+}
+
+// parseTelegramViews parses view counters like 987, 1.2K, or 3M.
+func parseTelegramViews(raw string) int64 {
+	raw = strings.ToUpper(strings.TrimSpace(raw))
+	if raw == "" {
+		return 0
+	}
+
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(raw, "K"):
+		multiplier = 1e3
+		raw = strings.TrimSuffix(raw, "K")
+	case strings.HasSuffix(raw, "M"):
+		multiplier = 1e6
+		raw = strings.TrimSuffix(raw, "M")
+	}
+
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil || value < 0 {
+		return 0
+	}
+
+	return int64(math.Round(value * multiplier))
 }
 
 func findTelegramChannelURLCandidates(text string) []string {
@@ -1,8 +1,22 @@
 package feed
 
 import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
 	"slices"
+	"strings"
+	"sync"
+	"telekilogram/internal/config"
+	"telekilogram/internal/domain"
 	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
 )
 
 func TestFindTelegramChannelURLCandidates(t *testing.T) {
@@ -157,3 +171,260 @@ func TestFindTelegramChannelURLCandidatesMaxLengthName(t *testing.T) {
 		t.Fatalf("32-char slug should be accepted: got %q want %q", got, want)
 	}
 }
+
+// telegramFixtureServer serves t.me/s/<slug> pages from testdata/telegram:
+// page_latest.html without ?before= and page_before_<id>.html with it.
+type telegramFixtureServer struct {
+	mu      sync.Mutex
+	queries []string
+	failing map[string]bool
+}
+
+func (s *telegramFixtureServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.queries = append(s.queries, r.URL.RawQuery)
+	failing := s.failing[r.URL.RawQuery]
+	s.mu.Unlock()
+
+	if failing {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	name := "page_latest.html"
+	if before := r.URL.Query().Get("before"); before != "" {
+		name = "page_before_" + before + ".html"
+	}
+
+	body, err := os.ReadFile(filepath.Join("testdata", "telegram", name))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	_, _ = w.Write(body)
+}
+
+func (s *telegramFixtureServer) requestedQueries() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.queries)
+}
+
+// rewriteTransport sends every request to target, keeping path and query.
+type rewriteTransport struct {
+	target *url.URL
+}
+
+func (rt rewriteTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.URL.Scheme = rt.target.Scheme
+	r.URL.Host = rt.target.Host
+	r.Host = rt.target.Host
+
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func newTelegramFixtureParser(t *testing.T, fixtures *telegramFixtureServer, maxPages int) *Parser {
+	t.Helper()
+
+	server := httptest.NewServer(fixtures)
+	t.Cleanup(server.Close)
+
+	target, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("parse server URL: %v", err)
+	}
+
+	client := &http.Client{Transport: rewriteTransport{target: target}, Timeout: 5 * time.Second}
+
+	return NewParser(nil, nil, nil, nil, nil, client, config.FeedConfig{}, config.TelegramConfig{
+		UserAgent: "test-agent",
+		MaxPages:  maxPages,
+	}, slog.Default())
+}
+
+func channelItemIDs(t *testing.T, items []channelItem) []int64 {
+	t.Helper()
+
+	ids := make([]int64, 0, len(items))
+	for _, item := range items {
+		id, ok := telegramMessageID(item.URL)
+		if !ok {
+			t.Fatalf("no message ID in %q", item.URL)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+func TestFetchTelegramChannelPostsFollowsPagesUntilCutoff(t *testing.T) {
+	fixtures := &telegramFixtureServer{}
+	p := newTelegramFixtureParser(t, fixtures, 10)
+
+	cutoff := time.Date(2025, 1, 10, 6, 0, 0, 0, time.UTC)
+
+	items, title, err := p.fetchTelegramChannelPosts(t.Context(), "example_channel", cutoff)
+	if err != nil {
+		t.Fatalf("fetchTelegramChannelPosts() error = %v", err)
+	}
+
+	if title != "Example Channel" {
+		t.Fatalf("expected channel title, got %q", title)
+	}
+
+	if got, want := channelItemIDs(t, items), []int64{35, 36, 37, 38, 39, 40, 41, 42, 43}; !slices.Equal(got, want) {
+		t.Fatalf("expected items %v oldest first, got %v", want, got)
+	}
+
+	if !strings.Contains(items[0].text, "Second line of post 35") {
+		t.Fatalf("expected text of older page, got %q", items[0].text)
+	}
+
+	if got, want := fixtures.requestedQueries(), []string{"", "before=41", "before=38"}; !slices.Equal(got, want) {
+		t.Fatalf("expected requests %q, got %q", want, got)
+	}
+}
+
+func TestFetchTelegramChannelPostsStopsAtFirstPageWithinCutoff(t *testing.T) {
+	fixtures := &telegramFixtureServer{}
+	p := newTelegramFixtureParser(t, fixtures, 10)
+
+	cutoff := time.Date(2025, 1, 10, 10, 30, 0, 0, time.UTC)
+
+	items, _, err := p.fetchTelegramChannelPosts(t.Context(), "example_channel", cutoff)
+	if err != nil {
+		t.Fatalf("fetchTelegramChannelPosts() error = %v", err)
+	}
+
+	if got, want := channelItemIDs(t, items), []int64{41, 42, 43}; !slices.Equal(got, want) {
+		t.Fatalf("expected items %v, got %v", want, got)
+	}
+
+	if got := fixtures.requestedQueries(); len(got) != 1 {
+		t.Fatalf("expected a single request, got %q", got)
+	}
+}
+
+func TestFetchTelegramChannelPostsRespectsMaxPages(t *testing.T) {
+	fixtures := &telegramFixtureServer{}
+	p := newTelegramFixtureParser(t, fixtures, 2)
+
+	items, _, err := p.fetchTelegramChannelPosts(t.Context(), "example_channel", time.Time{})
+	if err != nil {
+		t.Fatalf("fetchTelegramChannelPosts() error = %v", err)
+	}
+
+	if got, want := channelItemIDs(t, items), []int64{38, 39, 40, 41, 42, 43}; !slices.Equal(got, want) {
+		t.Fatalf("expected items %v, got %v", want, got)
+	}
+
+	if got, want := fixtures.requestedQueries(), []string{"", "before=41"}; !slices.Equal(got, want) {
+		t.Fatalf("expected requests %q, got %q", want, got)
+	}
+}
+
+func TestFetchTelegramChannelPostsKeepsItemsWhenOlderPageFails(t *testing.T) {
+	fixtures := &telegramFixtureServer{failing: map[string]bool{"before=41": true}}
+	p := newTelegramFixtureParser(t, fixtures, 10)
+
+	items, _, err := p.fetchTelegramChannelPosts(t.Context(), "example_channel", time.Time{})
+	if err != nil {
+		t.Fatalf("fetchTelegramChannelPosts() error = %v", err)
+	}
+
+	if got, want := channelItemIDs(t, items), []int64{41, 42, 43}; !slices.Equal(got, want) {
+		t.Fatalf("expected items %v, got %v", want, got)
+	}
+}
+
+func TestFetchTelegramChannelPostsFailsWhenFirstPageFails(t *testing.T) {
+	fixtures := &telegramFixtureServer{failing: map[string]bool{"": true}}
+	p := newTelegramFixtureParser(t, fixtures, 10)
+
+	if _, _, err := p.fetchTelegramChannelPosts(t.Context(), "example_channel", time.Time{}); err == nil {
+		t.Fatal("expected error when the first page fails")
+	}
+}
+
+func TestFetchTelegramChannelPostsWaitsBetweenPages(t *testing.T) {
+	fixtures := &telegramFixtureServer{}
+	p := newTelegramFixtureParser(t, fixtures, 3)
+	p.telegramCfg.PageDelay = 50 * time.Millisecond
+
+	start := time.Now()
+	if _, _, err := p.fetchTelegramChannelPosts(t.Context(), "example_channel", time.Time{}); err != nil {
+		t.Fatalf("fetchTelegramChannelPosts() error = %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Fatalf("expected two page delays, took %s", elapsed)
+	}
+}
+
+func TestChannelPageItemsExtractsPostMeta(t *testing.T) {
+	body, err := os.ReadFile(filepath.Join("testdata", "telegram", "rich_posts.html"))
+	if err != nil {
+		t.Fatalf("read fixture: %v", err)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(body)))
+	if err != nil {
+		t.Fatalf("parse fixture: %v", err)
+	}
+
+	items, err := channelPageItems(doc)
+	if err != nil {
+		t.Fatalf("channelPageItems() error = %v", err)
+	}
+
+	want := []struct {
+		text string
+		meta domain.TelegramPostMeta
+	}{
+		{meta: domain.TelegramPostMeta{MediaKind: domain.MediaKindPhoto, Views: 1200}},
+		{text: "Launch recap video: example.com/launch", meta: domain.TelegramPostMeta{
+			MediaKind:        domain.MediaKindVideo,
+			Views:            15,
+			ForwardedFrom:    "Other Channel",
+			ReplyTo:          "Earlier announcement",
+			LinkPreviewTitle: "Launch day",
+			Edited:           true,
+			Links:            []string{"https://example.com/launch?utm_source=tg", "https://example.com/launch"},
+		}},
+		{text: "Which release next?", meta: domain.TelegramPostMeta{MediaKind: domain.MediaKindPoll, Views: 3_000_000}},
+		{meta: domain.TelegramPostMeta{MediaKind: domain.MediaKindDocument}},
+		{meta: domain.TelegramPostMeta{MediaKind: domain.MediaKindVoice}},
+	}
+
+	if len(items) != len(want) {
+		t.Fatalf("expected %d items, got %d", len(want), len(items))
+	}
+
+	for i, w := range want {
+		if items[i].text != w.text {
+			t.Errorf("item %d text = %q, want %q", i, items[i].text, w.text)
+		}
+		if !reflect.DeepEqual(items[i].meta, w.meta) {
+			t.Errorf("item %d meta = %+v, want %+v", i, items[i].meta, w.meta)
+		}
+	}
+}
+
+func TestParseTelegramViews(t *testing.T) {
+	tests := map[string]int64{
+		"":      0,
+		"987":   987,
+		"1.2K":  1200,
+		"15.3k": 15300,
+		"3M":    3_000_000,
+		"n/a":   0,
+	}
+
+	for raw, want := range tests {
+		if got := parseTelegramViews(raw); got != want {
+			t.Errorf("parseTelegramViews(%q) = %d, want %d", raw, got, want)
+		}
+	}
+}
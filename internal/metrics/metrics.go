@@ -0,0 +1,246 @@
+// Package metrics exposes Prometheus metrics of feed fetches, summaries, and
+// Telegram sends. All Metrics methods are no-ops on a nil receiver, so
+// components can be created without metrics in tests.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "telekilogram"
+
+// Source types of feed fetches.
+const (
+	SourceTypeRSS      = "rss"
+	SourceTypeTelegram = "telegram"
+)
+
+// Summary cache lookup results.
+const (
+	CacheHitMemory   = "memory_hit"
+	CacheHitDatabase = "database_hit"
+	CacheMiss        = "miss"
+)
+
+// Reasons to fall back to the truncated text instead of a summary.
+const (
+	FallbackNoSummarizer = "no_summarizer"
+	FallbackError        = "error"
+	FallbackEmpty        = "empty"
+)
+
+// Scheduler jobs.
+const (
+	JobHourlyDigest = "hourly_digest"
+	JobRealtime     = "realtime"
+)
+
+type Metrics struct {
+	registry *prometheus.Registry
+
+	feedFetches       *prometheus.CounterVec
+	feedFetchErrors   *prometheus.CounterVec
+	feedFetchDuration *prometheus.HistogramVec
+
+	summarizerCalls    *prometheus.CounterVec
+	summarizerFailures *prometheus.CounterVec
+	summarizerTokens   *prometheus.CounterVec
+	summaryFallbacks   *prometheus.CounterVec
+	summaryCache       *prometheus.CounterVec
+
+	rateLimiterQueueLength prometheus.Gauge
+	rateLimiterWait        prometheus.Histogram
+	telegramSendErrors     *prometheus.CounterVec
+
+	schedulerRunDuration *prometheus.HistogramVec
+	schedulerUsersServed *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+
+		feedFetches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "feed_fetches_total",
+			Help:      "Feed fetches by source type.",
+		}, []string{"source_type"}),
+		feedFetchErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "feed_fetch_errors_total",
+			Help:      "Failed feed fetches by source type.",
+		}, []string{"source_type"}),
+		feedFetchDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "feed_fetch_duration_seconds",
+			Help:      "Feed fetch and parse latency by source type.",
+			Buckets:   prometheus.ExponentialBuckets(0.05, 2, 10),
+		}, []string{"source_type"}),
+
+		summarizerCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "summarizer_calls_total",
+			Help:      "Summarizer calls by provider.",
+		}, []string{"provider"}),
+		summarizerFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "summarizer_failures_total",
+			Help:      "Failed summarizer calls by provider.",
+		}, []string{"provider"}),
+		summarizerTokens: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "summarizer_tokens_total",
+			Help:      "Tokens used by summarizer requests by provider and kind (input or output).",
+		}, []string{"provider", "kind"}),
+		summaryFallbacks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "summary_fallbacks_total",
+			Help:      "Summaries replaced with truncated text by reason.",
+		}, []string{"reason"}),
+		summaryCache: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "summary_cache_lookups_total",
+			Help:      "Summary cache lookups by result (memory_hit, database_hit, or miss).",
+		}, []string{"result"}),
+
+		rateLimiterQueueLength: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "rate_limiter_queue_length",
+			Help:      "Telegram requests waiting in the rate limiter queue.",
+		}),
+		rateLimiterWait: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "rate_limiter_wait_seconds",
+			Help:      "Time Telegram requests spend in the rate limiter before they are sent.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 12),
+		}),
+		telegramSendErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "telegram_send_errors_total",
+			Help:      "Failed Telegram API requests by method.",
+		}, []string{"method"}),
+
+		schedulerRunDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "scheduler_run_duration_seconds",
+			Help:      "Scheduler run duration by job.",
+			Buckets:   prometheus.ExponentialBuckets(0.5, 2, 12),
+		}, []string{"job"}),
+		schedulerUsersServed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "scheduler_users_served_total",
+			Help:      "Users who were sent posts by scheduler job.",
+		}, []string{"job"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.feedFetches,
+		m.feedFetchErrors,
+		m.feedFetchDuration,
+		m.summarizerCalls,
+		m.summarizerFailures,
+		m.summarizerTokens,
+		m.summaryFallbacks,
+		m.summaryCache,
+		m.rateLimiterQueueLength,
+		m.rateLimiterWait,
+		m.telegramSendErrors,
+		m.schedulerRunDuration,
+		m.schedulerUsersServed,
+	)
+
+	return m
+}
+
+// Handler serves the registered metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *Metrics) ObserveFeedFetch(sourceType string, duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+
+	m.feedFetches.WithLabelValues(sourceType).Inc()
+	m.feedFetchDuration.WithLabelValues(sourceType).Observe(duration.Seconds())
+	if err != nil {
+		m.feedFetchErrors.WithLabelValues(sourceType).Inc()
+	}
+}
+
+func (m *Metrics) ObserveSummarizerCall(provider string, err error) {
+	if m == nil {
+		return
+	}
+
+	m.summarizerCalls.WithLabelValues(provider).Inc()
+	if err != nil {
+		m.summarizerFailures.WithLabelValues(provider).Inc()
+	}
+}
+
+func (m *Metrics) AddSummarizerTokens(provider string, input int64, output int64) {
+	if m == nil {
+		return
+	}
+
+	m.summarizerTokens.WithLabelValues(provider, "input").Add(float64(max(input, 0)))
+	m.summarizerTokens.WithLabelValues(provider, "output").Add(float64(max(output, 0)))
+}
+
+func (m *Metrics) ObserveSummaryFallback(reason string) {
+	if m == nil {
+		return
+	}
+
+	m.summaryFallbacks.WithLabelValues(reason).Inc()
+}
+
+func (m *Metrics) ObserveSummaryCacheLookup(result string) {
+	if m == nil {
+		return
+	}
+
+	m.summaryCache.WithLabelValues(result).Inc()
+}
+
+func (m *Metrics) SetRateLimiterQueueLength(length int) {
+	if m == nil {
+		return
+	}
+
+	m.rateLimiterQueueLength.Set(float64(length))
+}
+
+func (m *Metrics) ObserveRateLimiterWait(wait time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.rateLimiterWait.Observe(wait.Seconds())
+}
+
+func (m *Metrics) ObserveTelegramSendError(method string) {
+	if m == nil {
+		return
+	}
+
+	m.telegramSendErrors.WithLabelValues(method).Inc()
+}
+
+func (m *Metrics) ObserveSchedulerRun(job string, duration time.Duration, usersServed int) {
+	if m == nil {
+		return
+	}
+
+	m.schedulerRunDuration.WithLabelValues(job).Observe(duration.Seconds())
+	m.schedulerUsersServed.WithLabelValues(job).Add(float64(usersServed))
+}
@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestNilMetricsAreNoOps(t *testing.T) {
+	var m *Metrics
+
+	m.ObserveFeedFetch(SourceTypeRSS, time.Second, errors.New("boom"))
+	m.ObserveSummarizerCall("openai", nil)
+	m.AddSummarizerTokens("openai", 1, 2)
+	m.ObserveSummaryFallback(FallbackError)
+	m.ObserveSummaryCacheLookup(CacheMiss)
+	m.SetRateLimiterQueueLength(1)
+	m.ObserveRateLimiterWait(time.Second)
+	m.ObserveTelegramSendError("sendMessage")
+	m.ObserveSchedulerRun(JobHourlyDigest, time.Second, 1)
+}
+
+func TestObserveFeedFetchCountsErrorsPerSourceType(t *testing.T) {
+	m := New()
+
+	m.ObserveFeedFetch(SourceTypeRSS, time.Second, nil)
+	m.ObserveFeedFetch(SourceTypeRSS, time.Second, errors.New("boom"))
+	m.ObserveFeedFetch(SourceTypeTelegram, time.Second, nil)
+
+	if got := testutil.ToFloat64(m.feedFetches.WithLabelValues(SourceTypeRSS)); got != 2 {
+		t.Fatalf("expected 2 RSS fetches, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.feedFetchErrors.WithLabelValues(SourceTypeRSS)); got != 1 {
+		t.Fatalf("expected 1 RSS fetch error, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.feedFetchErrors.WithLabelValues(SourceTypeTelegram)); got != 0 {
+		t.Fatalf("expected no Telegram fetch errors, got %v", got)
+	}
+}
+
+func TestHandlerExposesMetrics(t *testing.T) {
+	m := New()
+	m.AddSummarizerTokens("ollama", 10, 5)
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequestWithContext(t.Context(), http.MethodGet, Path, nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	want := `telekilogram_summarizer_tokens_total{kind="input",provider="ollama"} 10`
+	if !strings.Contains(rec.Body.String(), want) {
+		t.Fatalf("expected %q in metrics output", want)
+	}
+}
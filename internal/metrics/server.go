@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	Path = "/metrics"
+
+	serverReadHeaderTimeout = 10 * time.Second
+	serverShutdownTimeout   = 5 * time.Second
+)
+
+// Server serves Path on its own address, apart from the bot webhook.
+type Server struct {
+	addr    string
+	metrics *Metrics
+	server  *http.Server
+	log     *slog.Logger
+}
+
+func NewServer(addr string, m *Metrics, log *slog.Logger) *Server {
+	return &Server{
+		addr:    addr,
+		metrics: m,
+		log:     log,
+	}
+}
+
+// Start opens the listener and serves metrics in the background until Stop.
+func (s *Server) Start(ctx context.Context) error {
+	listener, err := (&net.ListenConfig{}).Listen(ctx, "tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("listen: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(Path, s.metrics.Handler())
+
+	s.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: serverReadHeaderTimeout,
+	}
+
+	go func() {
+		if serveErr := s.server.Serve(listener); serveErr != nil && !errors.Is(serveErr, http.ErrServerClosed) {
+			s.log.ErrorContext(ctx, "Metrics server is stopped with error",
+				"error", serveErr,
+				"listenAddr", s.addr)
+		}
+	}()
+
+	return nil
+}
+
+func (s *Server) Stop() error {
+	if s.server == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), serverShutdownTimeout)
+	defer cancel()
+
+	if err := s.server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("shutdown server: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package ratelimiter
+
+import "context"
+
+// Priority picks the queue lane of a request. Interactive requests go ahead of
+// background ones, except after cfg.MaxInteractiveStreak interactive requests
+// in a row, so background requests still go out.
+type Priority int
+
+const (
+	// PriorityInteractive is the default, for replies to commands and callbacks.
+	PriorityInteractive Priority = iota
+	// PriorityBackground is for scheduled digests and typing actions.
+	PriorityBackground
+)
+
+type priorityContextKey struct{}
+
+// WithPriority returns a context that makes SendMessage and SendDocument use
+// priority p.
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityContextKey{}, p)
+}
+
+func priorityFromContext(ctx context.Context) Priority {
+	if ctx == nil {
+		return PriorityInteractive
+	}
+
+	if p, ok := ctx.Value(priorityContextKey{}).(Priority); ok {
+		return p
+	}
+
+	return PriorityInteractive
+}
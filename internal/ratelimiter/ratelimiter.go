@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"sync"
 	"telekilogram/internal/config"
+	"telekilogram/internal/metrics"
 	"time"
 
 	"github.com/go-telegram/bot"
@@ -14,11 +15,13 @@ import (
 )
 
 type request struct {
-	chatID   int64
-	ctx      context.Context
-	label    string
-	run      func(context.Context) response
-	response chan response
+	chatID     int64
+	ctx        context.Context
+	label      string
+	run        func(context.Context) response
+	response   chan response
+	enqueuedAt time.Time
+	priority   Priority
 }
 
 type response struct {
@@ -27,28 +30,41 @@ type response struct {
 	err     error
 }
 
+// RateLimiter sends Telegram requests from two queues, one per Priority: queue
+// holds interactive requests and backgroundQueue the rest.
 type RateLimiter struct {
-	api      *bot.Bot
-	queue    chan request
-	lastSent map[int64]time.Time
-	mu       sync.Mutex
-	ctx      context.Context
-	cancel   context.CancelFunc
-	cfg      config.RateLimiterConfig
-	log      *slog.Logger
+	api             *bot.Bot
+	queue           chan request
+	backgroundQueue chan request
+	lastSent        map[int64]time.Time
+	mu              sync.Mutex
+	ctx             context.Context
+	cancel          context.CancelFunc
+	cfg             config.RateLimiterConfig
+	metrics         *metrics.Metrics
+	log             *slog.Logger
+
+	// global, pausedUntil, and interactiveStreak are only used by the queue goroutine.
+	global            *tokenBucket
+	pausedUntil       time.Time
+	interactiveStreak int
 }
 
-func New(api *bot.Bot, cfg config.RateLimiterConfig, log *slog.Logger) *RateLimiter {
+func New(api *bot.Bot, cfg config.RateLimiterConfig, m *metrics.Metrics, log *slog.Logger) *RateLimiter {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	rl := &RateLimiter{
-		api:      api,
-		queue:    make(chan request, cfg.QueueSize),
-		lastSent: make(map[int64]time.Time),
-		ctx:      ctx,
-		cancel:   cancel,
-		cfg:      cfg,
-		log:      log,
+		api:             api,
+		queue:           make(chan request, cfg.QueueSize),
+		backgroundQueue: make(chan request, cfg.QueueSize),
+		lastSent:        make(map[int64]time.Time),
+		ctx:             ctx,
+		cancel:          cancel,
+		cfg:             cfg,
+		metrics:         m,
+		log:             log,
+
+		global: newTokenBucket(cfg.GlobalRate, cfg.GlobalBurst),
 	}
 
 	go rl.processQueue()
@@ -70,9 +86,10 @@ func (rl *RateLimiter) SendMessage(
 	}
 
 	resp, err := rl.enqueue(ctx, request{
-		chatID: chatID,
-		ctx:    ctx,
-		label:  "sendMessage",
+		chatID:   chatID,
+		ctx:      ctx,
+		label:    "sendMessage",
+		priority: priorityFromContext(ctx),
 		run: func(ctx context.Context) response {
 			message, sendErr := rl.api.SendMessage(ctx, params)
 			return response{
@@ -88,6 +105,39 @@ func (rl *RateLimiter) SendMessage(
 	return resp.message, nil
 }
 
+func (rl *RateLimiter) SendDocument(
+	ctx context.Context,
+	params *bot.SendDocumentParams,
+) (*models.Message, error) {
+	if params == nil {
+		return nil, errors.New("send document params are nil")
+	}
+
+	chatID, err := chatIDFromAny(params.ChatID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := rl.enqueue(ctx, request{
+		chatID:   chatID,
+		ctx:      ctx,
+		label:    "sendDocument",
+		priority: priorityFromContext(ctx),
+		run: func(ctx context.Context) response {
+			message, sendErr := rl.api.SendDocument(ctx, params)
+			return response{
+				message: message,
+				err:     sendErr,
+			}
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.message, nil
+}
+
 func (rl *RateLimiter) AnswerCallbackQuery(
 	ctx context.Context,
 	params *bot.AnswerCallbackQueryParams,
@@ -96,7 +146,12 @@ func (rl *RateLimiter) AnswerCallbackQuery(
 		return false, errors.New("answer callback query params are nil")
 	}
 
-	return rl.api.AnswerCallbackQuery(ctx, params)
+	ok, err := rl.api.AnswerCallbackQuery(ctx, params)
+	if err != nil {
+		rl.metrics.ObserveTelegramSendError("answerCallbackQuery")
+	}
+
+	return ok, err
 }
 
 func (rl *RateLimiter) SendChatAction(
@@ -113,9 +168,10 @@ func (rl *RateLimiter) SendChatAction(
 	}
 
 	resp, err := rl.enqueue(ctx, request{
-		chatID: chatID,
-		ctx:    ctx,
-		label:  "sendChatAction",
+		chatID:   chatID,
+		ctx:      ctx,
+		label:    "sendChatAction",
+		priority: PriorityBackground,
 		run: func(ctx context.Context) response {
 			ok, sendErr := rl.api.SendChatAction(ctx, params)
 			return response{
@@ -137,9 +193,16 @@ func (rl *RateLimiter) Stop() {
 
 func (rl *RateLimiter) enqueue(ctx context.Context, req request) (response, error) {
 	req.response = make(chan response, 1)
+	req.enqueuedAt = time.Now()
+
+	queue := rl.queue
+	if req.priority == PriorityBackground {
+		queue = rl.backgroundQueue
+	}
 
 	select {
-	case rl.queue <- req:
+	case queue <- req:
+		rl.metrics.SetRateLimiterQueueLength(rl.queueLen())
 	case <-ctx.Done():
 		return response{}, ctx.Err()
 	case <-rl.ctx.Done():
@@ -158,42 +221,106 @@ func (rl *RateLimiter) enqueue(ctx context.Context, req request) (response, erro
 
 func (rl *RateLimiter) processQueue() {
 	for {
-		select {
-		case req := <-rl.queue:
-			rl.handleRequest(req)
-		case <-rl.ctx.Done():
+		req, ok := rl.nextRequest()
+		if !ok {
 			close(rl.queue)
+			close(rl.backgroundQueue)
 
-			for req := range rl.queue {
-				req.response <- response{
-					err: rl.ctx.Err(),
-				}
+			for req = range rl.queue {
+				req.response <- response{err: rl.ctx.Err()}
+			}
+			for req = range rl.backgroundQueue {
+				req.response <- response{err: rl.ctx.Err()}
 			}
 
 			return
 		}
+
+		rl.metrics.SetRateLimiterQueueLength(rl.queueLen())
+		rl.handleRequest(req)
+	}
+}
+
+// nextRequest waits for the next request, preferring interactive ones unless
+// cfg.MaxInteractiveStreak of them went in a row while background ones waited.
+// It returns false once the rate limiter is stopped.
+func (rl *RateLimiter) nextRequest() (request, bool) {
+	if rl.cfg.MaxInteractiveStreak > 0 && rl.interactiveStreak >= rl.cfg.MaxInteractiveStreak {
+		select {
+		case req := <-rl.backgroundQueue:
+			rl.interactiveStreak = 0
+			return req, true
+		default:
+		}
+	}
+
+	select {
+	case req := <-rl.queue:
+		rl.interactiveStreak++
+		return req, true
+	default:
 	}
+
+	select {
+	case req := <-rl.queue:
+		rl.interactiveStreak++
+		return req, true
+	case req := <-rl.backgroundQueue:
+		rl.interactiveStreak = 0
+		return req, true
+	case <-rl.ctx.Done():
+		return request{}, false
+	}
+}
+
+func (rl *RateLimiter) queueLen() int {
+	return len(rl.queue) + len(rl.backgroundQueue)
 }
 
+// handleRequest runs a request once the chat rate, the global bucket, and a
+// pause after 429 Too Many Requests allow it, retrying it up to
+// cfg.MaxRetries times after each 429.
 func (rl *RateLimiter) handleRequest(req request) {
 	if err := requestContextError(req.ctx); err != nil {
 		req.response <- response{err: err}
 		return
 	}
 
-	if req.chatID != 0 {
-		rl.waitForTurn(req)
-		if err := requestContextError(req.ctx); err != nil {
+	var resp response
+	for attempt := 0; ; attempt++ {
+		if err := rl.waitToSend(req); err != nil {
 			req.response <- response{err: err}
 			return
 		}
-		if rl.ctx.Err() != nil {
-			req.response <- response{err: rl.ctx.Err()}
-			return
+
+		if attempt == 0 {
+			rl.metrics.ObserveRateLimiterWait(time.Since(req.enqueuedAt))
 		}
+
+		resp = req.run(req.ctx)
+
+		retryAfter, ok := retryAfterFromError(resp.err)
+		if !ok {
+			break
+		}
+
+		// Telegram rejects every send until retry_after passes, so the whole queue waits.
+		rl.pausedUntil = time.Now().Add(retryAfter)
+
+		if attempt >= rl.cfg.MaxRetries {
+			break
+		}
+
+		rl.log.WarnContext(rl.ctx, "Telegram rate limit is hit so request will be retried",
+			"chatID", req.chatID,
+			"operation", req.label,
+			"retryAfter", retryAfter,
+			"attempt", attempt+1)
 	}
 
-	resp := req.run(req.ctx)
+	if resp.err != nil {
+		rl.metrics.ObserveTelegramSendError(req.label)
+	}
 
 	if req.chatID != 0 && requestContextError(req.ctx) == nil {
 		rl.mu.Lock()
@@ -204,6 +331,29 @@ func (rl *RateLimiter) handleRequest(req request) {
 	req.response <- resp
 }
 
+func (rl *RateLimiter) waitToSend(req request) error {
+	if req.chatID != 0 {
+		rl.waitForTurn(req)
+	}
+
+	if pause := time.Until(rl.pausedUntil); pause > 0 {
+		rl.log.DebugContext(rl.ctx, "Queue is paused after Telegram rate limit",
+			"chatID", req.chatID,
+			"pause", pause,
+			"operation", req.label)
+
+		rl.sleep(req, pause)
+	}
+
+	rl.sleep(req, rl.global.reserve(time.Now()))
+
+	if err := requestContextError(req.ctx); err != nil {
+		return err
+	}
+
+	return rl.ctx.Err()
+}
+
 func (rl *RateLimiter) waitForTurn(req request) {
 	rl.mu.Lock()
 	lastSent, exists := rl.lastSent[req.chatID]
@@ -222,15 +372,44 @@ func (rl *RateLimiter) waitForTurn(req request) {
 		"chatID", req.chatID,
 		"delay", delay,
 		"operation", req.label,
-		"queueLen", len(rl.queue))
+		"queueLen", rl.queueLen())
+
+	rl.sleep(req, delay)
+}
+
+// sleep waits for d, or less when the request or the rate limiter is done.
+func (rl *RateLimiter) sleep(req request, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
 
 	select {
-	case <-time.After(delay):
-	case <-req.ctx.Done():
+	case <-timer.C:
+	case <-requestDone(req.ctx):
 	case <-rl.ctx.Done():
 	}
 }
 
+// retryAfterFromError returns retry_after of a 429 Too Many Requests error.
+func retryAfterFromError(err error) (time.Duration, bool) {
+	var tooManyRequests *bot.TooManyRequestsError
+	if !errors.As(err, &tooManyRequests) {
+		return 0, false
+	}
+
+	return time.Duration(tooManyRequests.RetryAfter) * time.Second, true
+}
+
+func requestDone(ctx context.Context) <-chan struct{} {
+	if ctx == nil {
+		return nil
+	}
+	return ctx.Done()
+}
+
 func requestContextError(ctx context.Context) error {
 	if ctx == nil {
 		return nil
@@ -3,6 +3,13 @@ package ratelimiter
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
 	"telekilogram/internal/config"
 	"testing"
 	"time"
@@ -183,3 +190,201 @@ func TestGetRate(t *testing.T) {
 		})
 	}
 }
+
+// fakeBotAPI answers sendMessage with the queued responses in order and with
+// the last one after that.
+type fakeBotAPI struct {
+	mu        sync.Mutex
+	responses []string
+	calls     []time.Time
+}
+
+func (f *fakeBotAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !strings.HasSuffix(r.URL.Path, "/sendMessage") {
+		http.NotFound(w, r)
+		return
+	}
+
+	body := f.responses[min(len(f.calls), len(f.responses)-1)]
+	f.calls = append(f.calls, time.Now())
+
+	if strings.Contains(body, `"error_code":429`) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}
+	_, _ = w.Write([]byte(body))
+}
+
+func (f *fakeBotAPI) callTimes() []time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.calls)
+}
+
+func tooManyRequestsBody(retryAfter int) string {
+	return fmt.Sprintf(
+		`{"ok":false,"error_code":429,"description":"Too Many Requests: retry after %d",`+
+			`"parameters":{"retry_after":%d}}`,
+		retryAfter,
+		retryAfter,
+	)
+}
+
+const sentMessageBody = `{"ok":true,"result":{"message_id":7,"date":0,"chat":{"id":1,"type":"private"}}}`
+
+func newFakeAPIRateLimiter(t *testing.T, fake *fakeBotAPI, cfg config.RateLimiterConfig) *RateLimiter {
+	t.Helper()
+
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	api, err := bot.New("123:token", bot.WithSkipGetMe(), bot.WithServerURL(srv.URL))
+	if err != nil {
+		t.Fatalf("new bot API: %v", err)
+	}
+
+	if cfg.QueueSize == 0 {
+		cfg.QueueSize = 10
+	}
+
+	rl := New(api, cfg, nil, slog.Default())
+	t.Cleanup(rl.Stop)
+
+	return rl
+}
+
+func TestSendMessageRetriesAfterTooManyRequests(t *testing.T) {
+	fake := &fakeBotAPI{responses: []string{tooManyRequestsBody(1), sentMessageBody}}
+	rl := newFakeAPIRateLimiter(t, fake, config.RateLimiterConfig{MaxRetries: 3})
+
+	message, err := rl.SendMessage(t.Context(), &bot.SendMessageParams{ChatID: int64(1), Text: "digest"})
+	if err != nil {
+		t.Fatalf("SendMessage() error = %v", err)
+	}
+	if message == nil || message.ID != 7 {
+		t.Fatalf("expected sent message, got %+v", message)
+	}
+
+	calls := fake.callTimes()
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 API calls, got %d", len(calls))
+	}
+	if gap := calls[1].Sub(calls[0]); gap < time.Second {
+		t.Fatalf("expected retry after at least 1s, got %v", gap)
+	}
+}
+
+func TestSendMessageGivesUpAfterMaxRetries(t *testing.T) {
+	fake := &fakeBotAPI{responses: []string{tooManyRequestsBody(0)}}
+	rl := newFakeAPIRateLimiter(t, fake, config.RateLimiterConfig{MaxRetries: 2})
+
+	_, err := rl.SendMessage(t.Context(), &bot.SendMessageParams{ChatID: int64(1), Text: "digest"})
+	if !bot.IsTooManyRequestsError(err) {
+		t.Fatalf("expected too many requests error, got %v", err)
+	}
+
+	if got := len(fake.callTimes()); got != 3 {
+		t.Fatalf("expected 3 API calls, got %d", got)
+	}
+}
+
+func TestSendMessageUsesGlobalBucket(t *testing.T) {
+	fake := &fakeBotAPI{responses: []string{sentMessageBody}}
+	rl := newFakeAPIRateLimiter(t, fake, config.RateLimiterConfig{GlobalRate: 20, GlobalBurst: 1})
+
+	// Different chats skip the per-chat rate, so only the global bucket spaces them.
+	for chatID := range int64(3) {
+		params := &bot.SendMessageParams{ChatID: chatID + 1, Text: "digest"}
+		if _, err := rl.SendMessage(t.Context(), params); err != nil {
+			t.Fatalf("SendMessage() error = %v", err)
+		}
+	}
+
+	calls := fake.callTimes()
+	if gap := calls[2].Sub(calls[0]); gap < 90*time.Millisecond {
+		t.Fatalf("expected sends spaced by the global rate, got %v for 3 sends", gap)
+	}
+}
+
+func TestTokenBucketReserve(t *testing.T) {
+	bucket := newTokenBucket(10, 2)
+	now := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	want := []time.Duration{0, 0, 100 * time.Millisecond, 200 * time.Millisecond}
+	for i, w := range want {
+		if got := bucket.reserve(now); got != w {
+			t.Fatalf("reserve #%d = %v, want %v", i+1, got, w)
+		}
+	}
+
+	if got := bucket.reserve(now.Add(time.Second)); got != 0 {
+		t.Fatalf("expected refilled bucket after 1s, got wait %v", got)
+	}
+
+	if newTokenBucket(0, 10).reserve(now) != 0 {
+		t.Fatal("expected no limit with zero rate")
+	}
+}
+
+func TestWithPriority(t *testing.T) {
+	if got := priorityFromContext(context.Background()); got != PriorityInteractive {
+		t.Fatalf("expected interactive priority by default, got %v", got)
+	}
+
+	ctx := WithPriority(context.Background(), PriorityBackground)
+	if got := priorityFromContext(ctx); got != PriorityBackground {
+		t.Fatalf("expected background priority from context, got %v", got)
+	}
+}
+
+func TestNextRequestPrefersInteractiveWithoutStarvingBackground(t *testing.T) {
+	rl := &RateLimiter{
+		queue:           make(chan request, 10),
+		backgroundQueue: make(chan request, 10),
+		ctx:             context.Background(),
+		cfg:             config.RateLimiterConfig{MaxInteractiveStreak: 2},
+	}
+
+	for i := range 2 {
+		rl.backgroundQueue <- request{label: fmt.Sprintf("digest-%d", i), priority: PriorityBackground}
+	}
+	for i := range 5 {
+		rl.queue <- request{label: fmt.Sprintf("reply-%d", i)}
+	}
+
+	want := []string{"reply-0", "reply-1", "digest-0", "reply-2", "reply-3", "digest-1", "reply-4"}
+	for i, w := range want {
+		req, ok := rl.nextRequest()
+		if !ok {
+			t.Fatalf("nextRequest() #%d returned no request", i+1)
+		}
+		if req.label != w {
+			t.Fatalf("nextRequest() #%d = %s, want %s", i+1, req.label, w)
+		}
+	}
+}
+
+func TestSendChatActionUsesBackgroundQueue(t *testing.T) {
+	rl := &RateLimiter{
+		queue:           make(chan request, 1),
+		backgroundQueue: make(chan request, 1),
+		ctx:             context.Background(),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = rl.SendChatAction(ctx, &bot.SendChatActionParams{ChatID: int64(1), Action: models.ChatActionTyping})
+	}()
+
+	req := <-rl.backgroundQueue
+	if req.label != "sendChatAction" {
+		t.Fatalf("expected chat action in background queue, got %s", req.label)
+	}
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,46 @@
+package ratelimiter
+
+import "time"
+
+// tokenBucket caps sends across all chats at rate per second with bursts of up
+// to burst. It's only used by the queue goroutine, so it has no lock.
+type tokenBucket struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+
+	capacity := float64(max(burst, 1))
+
+	return &tokenBucket{
+		rate:   rate,
+		burst:  capacity,
+		tokens: capacity,
+	}
+}
+
+// reserve takes a token and returns how long to wait until it's available.
+// Tokens can go below zero, so waits of queued sends add up.
+func (b *tokenBucket) reserve(now time.Time) time.Duration {
+	if b == nil {
+		return 0
+	}
+
+	if !b.last.IsZero() {
+		b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	}
+	b.last = now
+
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
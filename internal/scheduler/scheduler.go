@@ -3,10 +3,14 @@ package scheduler
 import (
 	"context"
 	"log/slog"
+	"slices"
+	"sync"
 	"telekilogram/internal/bot"
 	"telekilogram/internal/config"
 	"telekilogram/internal/domain"
 	"telekilogram/internal/feed"
+	"telekilogram/internal/metrics"
+	"telekilogram/internal/ratelimiter"
 	"time"
 
 	"github.com/robfig/cron/v3"
@@ -23,14 +27,33 @@ type Scheduler struct {
 	cron    *cron.Cron
 	bot     *bot.Bot
 	fetcher *feed.Fetcher
+	metrics *metrics.Metrics
 	cfg     config.SchedulerConfig
 	log     *slog.Logger
+
+	// hourMu keeps the startup catch-up and hourly ticks from overlapping.
+	hourMu sync.Mutex
+	// realtimeMu skips a real-time poll while the previous one still runs.
+	realtimeMu sync.Mutex
+	// outboxMu skips an outbox drain while the previous one still runs.
+	outboxMu sync.Mutex
+}
+
+// OutboxSpec returns the cron spec of the outbox drain.
+func OutboxSpec(interval time.Duration) string {
+	return "@every " + interval.String()
+}
+
+// RealtimeFeedsSpec returns the cron spec of the real-time feed poll.
+func RealtimeFeedsSpec(interval time.Duration) string {
+	return "@every " + interval.String()
 }
 
 func New(
 	ctx context.Context,
 	bot *bot.Bot,
 	fetcher *feed.Fetcher,
+	m *metrics.Metrics,
 	cfg config.SchedulerConfig,
 	log *slog.Logger,
 ) *Scheduler {
@@ -41,6 +64,7 @@ func New(
 		cron:    c,
 		bot:     bot,
 		fetcher: fetcher,
+		metrics: m,
 		cfg:     cfg,
 		log:     log,
 	}
@@ -51,8 +75,22 @@ func (s *Scheduler) Start() error {
 		return err
 	}
 
+	if _, err := s.cron.AddFunc(RealtimeFeedsSpec(s.cfg.RealtimePollInterval), s.checkRealtimeFeeds); err != nil {
+		return err
+	}
+
+	if _, err := s.cron.AddFunc(OutboxSpec(s.cfg.OutboxPollInterval), s.drainOutbox); err != nil {
+		return err
+	}
+
 	s.cron.Start()
 
+	// Digest slots missed while the bot was down are caught up right away.
+	go s.checkHourFeeds()
+
+	// Messages left pending by the previous run are sent right away.
+	go s.drainOutbox()
+
 	return nil
 }
 
@@ -61,9 +99,19 @@ func (s *Scheduler) Stop() {
 }
 
 func (s *Scheduler) checkHourFeeds() {
+	s.hourMu.Lock()
+	defer s.hourMu.Unlock()
+
+	start := time.Now()
+	usersServed := 0
+	defer func() { s.metrics.ObserveSchedulerRun(metrics.JobHourlyDigest, time.Since(start), usersServed) }()
+
 	ctx, cancel := context.WithTimeout(s.ctx, s.cfg.CheckHourFeedsTimeout)
 	defer cancel()
 
+	// Digests wait behind replies to users, who are waiting for them.
+	ctx = ratelimiter.WithPriority(ctx, ratelimiter.PriorityBackground)
+
 	select {
 	case <-ctx.Done():
 		s.log.InfoContext(ctx, "Scheduler context is done",
@@ -73,36 +121,244 @@ func (s *Scheduler) checkHourFeeds() {
 	default:
 	}
 
-	hourUTC := int64(time.Now().UTC().Hour())
+	now := time.Now()
+	hourUTC := now.UTC().Hour()
 
-	userPosts, err := s.fetcher.FetchHourFeeds(ctx, hourUTC)
+	slots, err := s.fetcher.DueDigestSlots(ctx, now, s.cfg.CatchUpMaxLookback)
 	if err != nil {
-		s.log.ErrorContext(ctx, "Failed to fetch hour feeds",
+		s.log.ErrorContext(ctx, "Failed to get due digest slots",
+			"error", err,
+			"hourUTC", hourUTC)
+
+		slots = []time.Time{now.UTC().Truncate(time.Hour)}
+	}
+
+	for _, slot := range slots {
+		served, ok := s.runDigestSlot(ctx, slot, now)
+		usersServed += served
+
+		if !ok {
+			return
+		}
+	}
+
+	go s.drainOutbox()
+
+	if err = s.bot.NotifyUnhealthyFeeds(ctx); err != nil {
+		s.log.ErrorContext(ctx, "Failed to notify users about unhealthy feeds",
 			"error", err,
+			"hourUTC", hourUTC)
+	}
+
+	removed, err := s.fetcher.PruneSummaryCache(ctx)
+	if err != nil {
+		s.log.ErrorContext(ctx, "Failed to prune summary cache",
+			"error", err,
+			"hourUTC", hourUTC)
+	} else if removed > 0 {
+		s.log.InfoContext(ctx, "Summary cache is pruned",
+			"hourUTC", hourUTC,
+			"removed", removed)
+	}
+
+	removed, err = s.bot.PruneOutbox(ctx)
+	if err != nil {
+		s.log.ErrorContext(ctx, "Failed to prune outbox",
+			"error", err,
+			"hourUTC", hourUTC)
+	} else if removed > 0 {
+		s.log.InfoContext(ctx, "Outbox is pruned",
+			"hourUTC", hourUTC,
+			"removed", removed)
+	}
+
+	removed, err = s.fetcher.PruneDeliveredPosts(ctx)
+	if err != nil {
+		s.log.ErrorContext(ctx, "Failed to prune delivered posts",
+			"error", err,
+			"hourUTC", hourUTC)
+
+		return
+	}
+	if removed > 0 {
+		s.log.InfoContext(ctx, "Delivered posts are pruned",
 			"hourUTC", hourUTC,
+			"removed", removed)
+	}
+}
+
+// runDigestSlot queues the digests of the slot and records it as completed.
+// The slot of now covers posts up to now, and a missed slot, which is caught
+// up after downtime, covers posts up to its own time. It reports false when
+// ctx is done before the slot completes.
+func (s *Scheduler) runDigestSlot(ctx context.Context, slot time.Time, now time.Time) (int, bool) {
+	usersServed := 0
+
+	at, until := now, time.Time{}
+	if slot.Before(now.UTC().Truncate(time.Hour)) {
+		at, until = slot, slot
+
+		s.log.InfoContext(ctx, "Catching up missed digest slot",
+			"slotAt", slot)
+	}
+
+	userPosts, err := s.fetcher.FetchHourFeeds(ctx, at, until)
+	if err != nil {
+		s.log.ErrorContext(ctx, "Failed to fetch hour feeds",
+			"error", err,
+			"slotAt", slot,
 			"usersWithPosts", len(userPosts))
 	}
 
 	if ctx.Err() != nil {
 		s.log.InfoContext(ctx, "Scheduler context is done",
 			"error", ctx.Err(),
-			"hourUTC", hourUTC,
+			"slotAt", slot,
 			"operation", "checkHourFeeds")
+		return usersServed, false
+	}
+
+	for userID, up := range userPosts {
+		if err = s.bot.QueueNewPosts(ctx, userID, up); err != nil {
+			s.log.ErrorContext(ctx, "Failed to queue user posts",
+				"error", err,
+				"slotAt", slot,
+				"userID", userID,
+				"postCount", len(up.Posts),
+				"feedIDs", feedIDs(up.Posts))
+
+			continue
+		}
+
+		usersServed++
+
+		// Hidden and filtered posts are marked too, so they are counted in a single digest only.
+		delivered := slices.Concat(up.Posts, up.Hidden, up.Filtered)
+		if err = s.fetcher.MarkPostsDelivered(ctx, userID, delivered); err != nil {
+			s.log.ErrorContext(ctx, "Failed to mark user posts as delivered",
+				"error", err,
+				"slotAt", slot,
+				"userID", userID,
+				"postCount", len(delivered),
+				"feedIDs", feedIDs(delivered))
+		}
+
+		// The next digest covers posts since this slot, even when it was empty.
+		if err = s.fetcher.RecordDigestDelivery(ctx, userID, at); err != nil {
+			s.log.ErrorContext(ctx, "Failed to record digest delivery",
+				"error", err,
+				"slotAt", slot,
+				"userID", userID)
+		}
+	}
+
+	if err = s.fetcher.RecordDigestSlotRun(ctx, slot); err != nil {
+		s.log.ErrorContext(ctx, "Failed to record digest slot run",
+			"error", err,
+			"slotAt", slot)
+	}
+
+	return usersServed, true
+}
+
+func (s *Scheduler) checkRealtimeFeeds() {
+	if !s.realtimeMu.TryLock() {
+		s.log.WarnContext(s.ctx, "Previous realtime feeds check is still running",
+			"operation", "checkRealtimeFeeds")
+		return
+	}
+	defer s.realtimeMu.Unlock()
+
+	start := time.Now()
+	usersServed := 0
+	defer func() { s.metrics.ObserveSchedulerRun(metrics.JobRealtime, time.Since(start), usersServed) }()
+
+	ctx, cancel := context.WithTimeout(s.ctx, s.cfg.CheckRealtimeFeedsTimeout)
+	defer cancel()
+
+	ctx = ratelimiter.WithPriority(ctx, ratelimiter.PriorityBackground)
+
+	userPosts, err := s.fetcher.FetchRealtimeFeeds(ctx)
+	if err != nil {
+		s.log.ErrorContext(ctx, "Failed to fetch realtime feeds",
+			"error", err,
+			"usersWithPosts", len(userPosts))
+	}
+
+	if ctx.Err() != nil {
+		s.log.InfoContext(ctx, "Scheduler context is done",
+			"error", ctx.Err(),
+			"operation", "checkRealtimeFeeds")
 		return
 	}
 
-	for userID, posts := range userPosts {
-		if err = s.bot.SendNewPosts(ctx, userID, posts); err != nil {
-			s.log.ErrorContext(ctx, "Failed to send user posts",
+	for userID, up := range userPosts {
+		sent := false
+
+		// Posts are marked one by one, so a failed send is retried on the next poll.
+		for _, post := range up.Posts {
+			if err = s.bot.SendRealtimePost(ctx, userID, post); err != nil {
+				s.log.ErrorContext(ctx, "Failed to send realtime post",
+					"error", err,
+					"userID", userID,
+					"feedID", post.FeedID)
+
+				continue
+			}
+
+			sent = true
+
+			if err = s.fetcher.MarkPostsDelivered(ctx, userID, []domain.Post{post}); err != nil {
+				s.log.ErrorContext(ctx, "Failed to mark realtime post as delivered",
+					"error", err,
+					"userID", userID,
+					"feedID", post.FeedID)
+			}
+		}
+
+		if err = s.fetcher.MarkPostsDelivered(ctx, userID, up.Filtered); err != nil {
+			s.log.ErrorContext(ctx, "Failed to mark filtered realtime posts as delivered",
 				"error", err,
-				"hourUTC", hourUTC,
 				"userID", userID,
-				"postCount", len(posts),
-				"feedIDs", feedIDs(posts))
+				"postCount", len(up.Filtered),
+				"feedIDs", feedIDs(up.Filtered))
+		}
+
+		if sent {
+			usersServed++
 		}
 	}
 }
 
+// drainOutbox sends queued digest messages. Pending messages are kept in the
+// database, so a skipped drain only delays them until the next one.
+func (s *Scheduler) drainOutbox() {
+	if !s.outboxMu.TryLock() {
+		s.log.DebugContext(s.ctx, "Previous outbox drain is still running",
+			"operation", "drainOutbox")
+		return
+	}
+	defer s.outboxMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(s.ctx, s.cfg.DrainOutboxTimeout)
+	defer cancel()
+
+	ctx = ratelimiter.WithPriority(ctx, ratelimiter.PriorityBackground)
+
+	sent, err := s.bot.DrainOutbox(ctx)
+	if err != nil {
+		s.log.ErrorContext(ctx, "Failed to drain outbox",
+			"error", err,
+			"sent", sent)
+		return
+	}
+
+	if sent > 0 {
+		s.log.InfoContext(ctx, "Outbox is drained",
+			"sent", sent)
+	}
+}
+
 func feedIDs(posts []domain.Post) []int64 {
 	seen := make(map[int64]struct{})
 	var ids []int64
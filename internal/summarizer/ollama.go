@@ -0,0 +1,185 @@
+package summarizer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"telekilogram/internal/config"
+	"telekilogram/internal/metrics"
+)
+
+const (
+	DefaultOllamaBaseURL = "http://localhost:11434"
+
+	ollamaGeneratePath     = "/api/generate"
+	ollamaMaxErrorBodySize = 1024
+)
+
+// OllamaSummarizer calls the /api/generate endpoint of a local Ollama server.
+type OllamaSummarizer struct {
+	client  *http.Client
+	baseURL string
+	model   string
+	cfg     config.OpenAIConfig
+	metrics *metrics.Metrics
+}
+
+type ollamaGenerateRequest struct {
+	Model   string               `json:"model"`
+	System  string               `json:"system,omitempty"`
+	Prompt  string               `json:"prompt"`
+	Stream  bool                 `json:"stream"`
+	Options ollamaGenerateOption `json:"options"`
+}
+
+type ollamaGenerateOption struct {
+	NumPredict int64 `json:"num_predict"`
+}
+
+type ollamaGenerateResponse struct {
+	Response        string `json:"response"`
+	Done            bool   `json:"done"`
+	DoneReason      string `json:"done_reason"`
+	PromptEvalCount int64  `json:"prompt_eval_count"`
+	EvalCount       int64  `json:"eval_count"`
+	Error           string `json:"error"`
+}
+
+// NewOllamaSummarizer builds a new summarizer instance. An empty base URL
+// falls back to DefaultOllamaBaseURL, and the model is required.
+func NewOllamaSummarizer(
+	cfg config.OpenAIConfig,
+	summarizerCfg config.SummarizerConfig,
+	m *metrics.Metrics,
+) (*OllamaSummarizer, error) {
+	model := strings.TrimSpace(summarizerCfg.Model)
+	if model == "" {
+		return nil, errors.New("model is empty")
+	}
+
+	baseURL := strings.TrimSpace(summarizerCfg.BaseURL)
+	if baseURL == "" {
+		baseURL = DefaultOllamaBaseURL
+	}
+
+	return &OllamaSummarizer{
+		client:  &http.Client{Timeout: summarizerCfg.Timeout},
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		model:   model,
+		cfg:     cfg,
+		metrics: m,
+	}, nil
+}
+
+// Summarize produces a single summary suitable for a digest.
+func (s *OllamaSummarizer) Summarize(
+	ctx context.Context,
+	input Input,
+) (string, error) {
+	summary, err := s.summarize(ctx, input)
+	s.metrics.ObserveSummarizerCall(ProviderOllama, err)
+
+	return summary, err
+}
+
+func (s *OllamaSummarizer) summarize(
+	ctx context.Context,
+	input Input,
+) (string, error) {
+	prompt, err := userPrompt(input)
+	if err != nil {
+		return "", err
+	}
+
+	maxOutputTokens := s.cfg.BaseMaxOutputTokens
+	for {
+		resp, err := s.generate(ctx, ollamaGenerateRequest{
+			Model:   s.model,
+			System:  s.cfg.SystemPrompt,
+			Prompt:  prompt,
+			Options: ollamaGenerateOption{NumPredict: maxOutputTokens},
+		})
+		if err != nil {
+			return "", err
+		}
+
+		s.metrics.AddSummarizerTokens(ProviderOllama, resp.PromptEvalCount, resp.EvalCount)
+
+		if resp.DoneReason == "length" {
+			var ok bool
+			if maxOutputTokens, ok = nextMaxOutputTokens(maxOutputTokens, s.cfg); ok {
+				continue
+			}
+			return "", fmt.Errorf("response is incomplete (reason = length, maxOutputTokens = %d)", maxOutputTokens)
+		}
+
+		summary := strings.TrimSpace(resp.Response)
+		if summary == "" {
+			return "", fmt.Errorf("output text is missing (doneReason = %s)", resp.DoneReason)
+		}
+		return summary, nil
+	}
+}
+
+func (s *OllamaSummarizer) generate(
+	ctx context.Context,
+	body ollamaGenerateRequest,
+) (*ollamaGenerateResponse, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(
+		ctx,
+		http.MethodPost,
+		s.baseURL+ollamaGeneratePath,
+		bytes.NewReader(payload),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+
+	result, err := readGenerateResponse(resp)
+	if closeErr := resp.Body.Close(); closeErr != nil {
+		err = errors.Join(err, fmt.Errorf("close response body: %w", closeErr))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func readGenerateResponse(resp *http.Response) (*ollamaGenerateResponse, error) {
+	if resp.StatusCode != http.StatusOK {
+		errorBody, _ := io.ReadAll(io.LimitReader(resp.Body, ollamaMaxErrorBodySize))
+		return nil, fmt.Errorf(
+			"do request: unexpected status %d: %s",
+			resp.StatusCode,
+			strings.TrimSpace(string(errorBody)),
+		)
+	}
+
+	var result ollamaGenerateResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	if result.Error != "" {
+		return nil, fmt.Errorf("generate: %s", result.Error)
+	}
+
+	return &result, nil
+}
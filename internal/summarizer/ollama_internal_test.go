@@ -0,0 +1,114 @@
+package summarizer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"telekilogram/internal/config"
+	"testing"
+	"time"
+)
+
+func testOpenAIConfig() config.OpenAIConfig {
+	return config.OpenAIConfig{
+		BaseMaxOutputTokens:         64,
+		LimitMaxOutputTokens:        256,
+		MaxOutputTokensGrowthFactor: 2,
+		SystemPrompt:                "Summarize.",
+		AIModel:                     "default-model",
+	}
+}
+
+func TestOllamaSummarizerSummarize(t *testing.T) {
+	var requests []ollamaGenerateRequest
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != ollamaGeneratePath {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+
+		var req ollamaGenerateRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		requests = append(requests, req)
+
+		resp := ollamaGenerateResponse{Response: "  short summary \n", Done: true, DoneReason: "stop"}
+		if len(requests) == 1 {
+			resp = ollamaGenerateResponse{Response: "trunc", Done: true, DoneReason: "length"}
+		}
+
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	s, err := NewOllamaSummarizer(testOpenAIConfig(), config.SummarizerConfig{
+		BaseURL: srv.URL + "/",
+		Model:   "llama3.2",
+		Timeout: time.Second,
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewOllamaSummarizer() error = %v", err)
+	}
+
+	got, err := s.Summarize(context.Background(), Input{Text: "Long post", SourceURL: "https://t.me/s/durov/1"})
+	if err != nil {
+		t.Fatalf("Summarize() error = %v", err)
+	}
+	if got != "short summary" {
+		t.Fatalf("Summarize() = %q, want %q", got, "short summary")
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("server got %d requests, want 2", len(requests))
+	}
+
+	first := requests[0]
+	if first.Model != "llama3.2" || first.System != "Summarize." || first.Stream {
+		t.Fatalf("unexpected request: %+v", first)
+	}
+	if !strings.Contains(first.Prompt, "https://t.me/s/durov/1") || !strings.Contains(first.Prompt, "Long post") {
+		t.Fatalf("prompt misses input: %q", first.Prompt)
+	}
+	if first.Options.NumPredict != 64 || requests[1].Options.NumPredict != 128 {
+		t.Fatalf("num_predict = %d, %d, want 64, 128", first.Options.NumPredict, requests[1].Options.NumPredict)
+	}
+}
+
+func TestOllamaSummarizerReturnsServerError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, `{"error":"model not found"}`, http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	s, err := NewOllamaSummarizer(testOpenAIConfig(), config.SummarizerConfig{BaseURL: srv.URL, Model: "missing"}, nil)
+	if err != nil {
+		t.Fatalf("NewOllamaSummarizer() error = %v", err)
+	}
+
+	_, err = s.Summarize(context.Background(), Input{Text: "Post"})
+	if err == nil || !strings.Contains(err.Error(), "model not found") {
+		t.Fatalf("Summarize() error = %v, want model not found", err)
+	}
+}
+
+func TestNewOllamaSummarizerRequiresModel(t *testing.T) {
+	if _, err := NewOllamaSummarizer(testOpenAIConfig(), config.SummarizerConfig{}, nil); err == nil {
+		t.Fatalf("NewOllamaSummarizer() should fail without a model")
+	}
+}
+
+func TestSummarizeRejectsEmptyInput(t *testing.T) {
+	s, err := NewOllamaSummarizer(testOpenAIConfig(), config.SummarizerConfig{Model: "llama3.2"}, nil)
+	if err != nil {
+		t.Fatalf("NewOllamaSummarizer() error = %v", err)
+	}
+
+	if _, err = s.Summarize(context.Background(), Input{Text: " \n"}); err == nil {
+		t.Fatalf("Summarize() should fail for empty input")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 	"telekilogram/internal/config"
+	"telekilogram/internal/metrics"
 
 	"github.com/openai/openai-go/v3"
 	"github.com/openai/openai-go/v3/option"
@@ -14,15 +15,27 @@ import (
 
 // OpenAISummarizer calls OpenAI's Responses API to produce summaries.
 type OpenAISummarizer struct {
-	client openai.Client
-	cfg    config.OpenAIConfig
+	client  openai.Client
+	cfg     config.OpenAIConfig
+	metrics *metrics.Metrics
 }
 
-// NewOpenAISummarizer builds a new summarizer instance.
-func NewOpenAISummarizer(apiKey string, cfg config.OpenAIConfig) (*OpenAISummarizer, error) {
+// NewOpenAISummarizer builds a new summarizer instance. An empty baseURL
+// keeps the default OpenAI endpoint.
+func NewOpenAISummarizer(
+	apiKey string,
+	cfg config.OpenAIConfig,
+	summarizerCfg config.SummarizerConfig,
+	m *metrics.Metrics,
+) (*OpenAISummarizer, error) {
+	if strings.TrimSpace(apiKey) == "" {
+		return nil, errors.New("API key is empty")
+	}
+
 	return &OpenAISummarizer{
-		client: openai.NewClient(option.WithAPIKey(apiKey)),
-		cfg:    cfg,
+		client:  openai.NewClient(openAIClientOptions(apiKey, summarizerCfg)...),
+		cfg:     cfg,
+		metrics: m,
 	}, nil
 }
 
@@ -31,19 +44,20 @@ func (s *OpenAISummarizer) Summarize(
 	ctx context.Context,
 	input Input,
 ) (string, error) {
-	text := strings.TrimSpace(input.Text)
-	if text == "" {
-		return "", errors.New("input is empty")
-	}
+	summary, err := s.summarize(ctx, input)
+	s.metrics.ObserveSummarizerCall(ProviderOpenAI, err)
+
+	return summary, err
+}
 
-	userPromptBuilder := strings.Builder{}
-	if sourceURL := strings.TrimSpace(input.SourceURL); sourceURL != "" {
-		userPromptBuilder.WriteString("Source:\n")
-		userPromptBuilder.WriteString(sourceURL)
-		userPromptBuilder.WriteString("\n")
+func (s *OpenAISummarizer) summarize(
+	ctx context.Context,
+	input Input,
+) (string, error) {
+	prompt, err := userPrompt(input)
+	if err != nil {
+		return "", err
 	}
-	userPromptBuilder.WriteString("Content:\n")
-	userPromptBuilder.WriteString(text)
 
 	maxOutputTokens := s.cfg.BaseMaxOutputTokens
 	for {
@@ -56,20 +70,21 @@ func (s *OpenAISummarizer) Summarize(
 			},
 			Instructions: openai.String(s.cfg.SystemPrompt),
 			Input: responses.ResponseNewParamsInputUnion{
-				OfString: openai.String(userPromptBuilder.String()),
+				OfString: openai.String(prompt),
 			},
 		})
 		if err != nil {
 			return "", fmt.Errorf("do request: %w", err)
 		}
 
+		s.metrics.AddSummarizerTokens(ProviderOpenAI, resp.Usage.InputTokens, resp.Usage.OutputTokens)
+
 		if resp.Status == "incomplete" {
-			if resp.IncompleteDetails.Reason == "max_output_tokens" && maxOutputTokens < s.cfg.LimitMaxOutputTokens {
-				maxOutputTokens *= s.cfg.MaxOutputTokensGrowthFactor
-				if maxOutputTokens > s.cfg.LimitMaxOutputTokens {
-					maxOutputTokens = s.cfg.LimitMaxOutputTokens
+			if resp.IncompleteDetails.Reason == "max_output_tokens" {
+				var ok bool
+				if maxOutputTokens, ok = nextMaxOutputTokens(maxOutputTokens, s.cfg); ok {
+					continue
 				}
-				continue
 			}
 			return "", fmt.Errorf(
 				"response is incomplete (reason = %s, maxOutputTokens = %d)",
@@ -85,3 +100,19 @@ func (s *OpenAISummarizer) Summarize(
 		return summary, nil
 	}
 }
+
+func openAIClientOptions(apiKey string, cfg config.SummarizerConfig) []option.RequestOption {
+	var opts []option.RequestOption
+
+	if apiKey = strings.TrimSpace(apiKey); apiKey != "" {
+		opts = append(opts, option.WithAPIKey(apiKey))
+	}
+	if baseURL := strings.TrimSpace(cfg.BaseURL); baseURL != "" {
+		opts = append(opts, option.WithBaseURL(baseURL))
+	}
+	if cfg.Timeout > 0 {
+		opts = append(opts, option.WithRequestTimeout(cfg.Timeout))
+	}
+
+	return opts
+}
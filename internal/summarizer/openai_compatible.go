@@ -0,0 +1,103 @@
+package summarizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"telekilogram/internal/config"
+	"telekilogram/internal/metrics"
+
+	"github.com/openai/openai-go/v3"
+)
+
+// OpenAICompatibleSummarizer calls the Chat Completions API of a self-hosted
+// OpenAI-compatible server, as such servers rarely implement the Responses API.
+type OpenAICompatibleSummarizer struct {
+	client  openai.Client
+	model   string
+	cfg     config.OpenAIConfig
+	metrics *metrics.Metrics
+}
+
+// NewOpenAICompatibleSummarizer builds a new summarizer instance. The API key
+// is optional, and summarizerCfg.Model overrides cfg.AIModel when set.
+func NewOpenAICompatibleSummarizer(
+	apiKey string,
+	cfg config.OpenAIConfig,
+	summarizerCfg config.SummarizerConfig,
+	m *metrics.Metrics,
+) (*OpenAICompatibleSummarizer, error) {
+	if strings.TrimSpace(summarizerCfg.BaseURL) == "" {
+		return nil, errors.New("base URL is empty")
+	}
+
+	model := strings.TrimSpace(summarizerCfg.Model)
+	if model == "" {
+		model = cfg.AIModel
+	}
+
+	return &OpenAICompatibleSummarizer{
+		client:  openai.NewClient(openAIClientOptions(apiKey, summarizerCfg)...),
+		model:   model,
+		cfg:     cfg,
+		metrics: m,
+	}, nil
+}
+
+// Summarize produces a single summary suitable for a digest.
+func (s *OpenAICompatibleSummarizer) Summarize(
+	ctx context.Context,
+	input Input,
+) (string, error) {
+	summary, err := s.summarize(ctx, input)
+	s.metrics.ObserveSummarizerCall(ProviderOpenAICompatible, err)
+
+	return summary, err
+}
+
+func (s *OpenAICompatibleSummarizer) summarize(
+	ctx context.Context,
+	input Input,
+) (string, error) {
+	prompt, err := userPrompt(input)
+	if err != nil {
+		return "", err
+	}
+
+	maxOutputTokens := s.cfg.BaseMaxOutputTokens
+	for {
+		resp, err := s.client.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
+			Model: s.model,
+			Messages: []openai.ChatCompletionMessageParamUnion{
+				openai.SystemMessage(s.cfg.SystemPrompt),
+				openai.UserMessage(prompt),
+			},
+			MaxTokens: openai.Int(maxOutputTokens),
+		})
+		if err != nil {
+			return "", fmt.Errorf("do request: %w", err)
+		}
+
+		s.metrics.AddSummarizerTokens(ProviderOpenAICompatible, resp.Usage.PromptTokens, resp.Usage.CompletionTokens)
+
+		if len(resp.Choices) == 0 {
+			return "", errors.New("response has no choices")
+		}
+
+		choice := resp.Choices[0]
+		if choice.FinishReason == "length" {
+			var ok bool
+			if maxOutputTokens, ok = nextMaxOutputTokens(maxOutputTokens, s.cfg); ok {
+				continue
+			}
+			return "", fmt.Errorf("response is incomplete (reason = length, maxOutputTokens = %d)", maxOutputTokens)
+		}
+
+		summary := strings.TrimSpace(choice.Message.Content)
+		if summary == "" {
+			return "", fmt.Errorf("output text is missing (finishReason = %s)", choice.FinishReason)
+		}
+		return summary, nil
+	}
+}
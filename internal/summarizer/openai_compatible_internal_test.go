@@ -0,0 +1,129 @@
+package summarizer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"telekilogram/internal/config"
+	"testing"
+	"time"
+)
+
+type chatCompletionRequest struct {
+	Model     string `json:"model"`
+	MaxTokens int64  `json:"max_tokens"`
+	Messages  []struct {
+		Role    string `json:"role"`
+		Content string `json:"content"`
+	} `json:"messages"`
+}
+
+func chatCompletionResponse(content string, finishReason string) map[string]any {
+	return map[string]any{
+		"id":      "chatcmpl-1",
+		"object":  "chat.completion",
+		"created": 1,
+		"model":   "local-model",
+		"choices": []map[string]any{{
+			"index":         0,
+			"finish_reason": finishReason,
+			"message":       map[string]any{"role": "assistant", "content": content},
+		}},
+	}
+}
+
+func TestOpenAICompatibleSummarizerSummarize(t *testing.T) {
+	var requests []chatCompletionRequest
+	var authHeader string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+
+		authHeader = r.Header.Get("Authorization")
+
+		var req chatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		requests = append(requests, req)
+
+		resp := chatCompletionResponse(" local summary ", "stop")
+		if len(requests) == 1 {
+			resp = chatCompletionResponse("trunc", "length")
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer srv.Close()
+
+	s, err := NewOpenAICompatibleSummarizer("local-key", testOpenAIConfig(), config.SummarizerConfig{
+		BaseURL: srv.URL + "/v1/",
+		Model:   "local-model",
+		Timeout: time.Second,
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewOpenAICompatibleSummarizer() error = %v", err)
+	}
+
+	got, err := s.Summarize(context.Background(), Input{Text: "Long post"})
+	if err != nil {
+		t.Fatalf("Summarize() error = %v", err)
+	}
+	if got != "local summary" {
+		t.Fatalf("Summarize() = %q, want %q", got, "local summary")
+	}
+
+	if authHeader != "Bearer local-key" {
+		t.Fatalf("Authorization = %q", authHeader)
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("server got %d requests, want 2", len(requests))
+	}
+
+	first := requests[0]
+	if first.Model != "local-model" || len(first.Messages) != 2 || first.Messages[0].Content != "Summarize." {
+		t.Fatalf("unexpected request: %+v", first)
+	}
+	if first.MaxTokens != 64 || requests[1].MaxTokens != 128 {
+		t.Fatalf("max_tokens = %d, %d, want 64, 128", first.MaxTokens, requests[1].MaxTokens)
+	}
+}
+
+func TestOpenAICompatibleSummarizerStopsAtTokenLimit(t *testing.T) {
+	requestCount := 0
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requestCount++
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(chatCompletionResponse("trunc", "length"))
+	}))
+	defer srv.Close()
+
+	s, err := NewOpenAICompatibleSummarizer("", testOpenAIConfig(), config.SummarizerConfig{BaseURL: srv.URL}, nil)
+	if err != nil {
+		t.Fatalf("NewOpenAICompatibleSummarizer() error = %v", err)
+	}
+
+	if _, err = s.Summarize(context.Background(), Input{Text: "Post"}); err == nil {
+		t.Fatalf("Summarize() should fail when the output never fits")
+	}
+
+	// 64, 128, and 256 tokens.
+	if requestCount != 3 {
+		t.Fatalf("server got %d requests, want 3", requestCount)
+	}
+}
+
+func TestNewOpenAICompatibleSummarizerRequiresBaseURL(t *testing.T) {
+	if _, err := NewOpenAICompatibleSummarizer("", testOpenAIConfig(), config.SummarizerConfig{}, nil); err == nil {
+		t.Fatalf("NewOpenAICompatibleSummarizer() should fail without a base URL")
+	}
+}
@@ -2,6 +2,16 @@ package summarizer
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"telekilogram/internal/config"
+)
+
+// Providers accepted by SUMMARIZER_PROVIDER.
+const (
+	ProviderOpenAI           = "openai"
+	ProviderOpenAICompatible = "openai_compatible"
+	ProviderOllama           = "ollama"
 )
 
 // Input describes the payload for a summary request.
@@ -16,3 +26,32 @@ type Input struct {
 type Summarizer interface {
 	Summarize(ctx context.Context, input Input) (string, error)
 }
+
+// userPrompt builds the prompt shared by all providers.
+func userPrompt(input Input) (string, error) {
+	text := strings.TrimSpace(input.Text)
+	if text == "" {
+		return "", errors.New("input is empty")
+	}
+
+	userPromptBuilder := strings.Builder{}
+	if sourceURL := strings.TrimSpace(input.SourceURL); sourceURL != "" {
+		userPromptBuilder.WriteString("Source:\n")
+		userPromptBuilder.WriteString(sourceURL)
+		userPromptBuilder.WriteString("\n")
+	}
+	userPromptBuilder.WriteString("Content:\n")
+	userPromptBuilder.WriteString(text)
+
+	return userPromptBuilder.String(), nil
+}
+
+// nextMaxOutputTokens grows a truncated request's output limit, returning
+// false once the limit can't grow anymore.
+func nextMaxOutputTokens(current int64, cfg config.OpenAIConfig) (int64, bool) {
+	if current >= cfg.LimitMaxOutputTokens {
+		return current, false
+	}
+
+	return min(current*cfg.MaxOutputTokensGrowthFactor, cfg.LimitMaxOutputTokens), true
+}